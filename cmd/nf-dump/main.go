@@ -6,32 +6,138 @@ Usage:
 
 Flags:
 		-addr string 	Listen address (default ":2055")
+		-tcp string 	Listen address for IPFIX over TCP (disabled by default)
+		-read string 	Dump the IPFIX messages from an IPFIX file (RFC 5655) and exit
+		-write string 	Archive received IPFIX messages to an IPFIX file (RFC 5655)
+		-nat 	Only dump NAT events from NetFlow version 9 and IPFIX records
+		-template-timeout duration 	Expire templates not refreshed within the timeout (default 30m0s)
+		-exporter-timeout duration 	Forget exporters that have been idle for the timeout (default 1h0m0s)
+		-state string 	Save templates, options and learned Information Elements to a state file, and restore them at startup
+		-pending-size int 	Buffer up to size bytes of sets that arrive before their template, per exporter (disabled by default)
+		-pending-age duration 	Drop buffered sets that wait longer than the age for their template (default 1m0s)
 */
 package main
 
 import (
 	"bytes"
+	"encoding/binary"
 	"flag"
+	"io"
 	"log"
 	"net"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/tehmaze/netflow"
 	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/nat"
 	"github.com/tehmaze/netflow/netflow1"
 	"github.com/tehmaze/netflow/netflow5"
 	"github.com/tehmaze/netflow/netflow6"
 	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
 	"github.com/tehmaze/netflow/netflow9"
 	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/sflow"
 )
 
 // Safe default
 var readSize = 2 << 16
 
+// Serializes the dumps of the UDP and TCP listeners
+var dumpMutex sync.Mutex
+
+// Archive of received IPFIX messages, if enabled
+var archive *ipfix.FileWriter
+
+// Only dump NAT events
+var natMode bool
+
 func main() {
 	listen := flag.String("addr", ":2055", "Listen address")
+	listenTCP := flag.String("tcp", "", "Listen address for IPFIX over TCP (disabled by default)")
+	read := flag.String("read", "", "Dump the IPFIX messages from an IPFIX file (RFC 5655) and exit")
+	write := flag.String("write", "", "Archive received IPFIX messages to an IPFIX file (RFC 5655)")
+	flag.BoolVar(&natMode, "nat", false, "Only dump NAT events from NetFlow version 9 and IPFIX records")
+	templateTimeout := flag.Duration("template-timeout", session.DefaultTemplateTimeout, "Expire templates not refreshed within the timeout")
+	exporterTimeout := flag.Duration("exporter-timeout", session.DefaultExporterTimeout, "Forget exporters that have been idle for the timeout")
+	state := flag.String("state", "", "Save templates, options and learned Information Elements to a state file, and restore them at startup")
+	pendingSize := flag.Int("pending-size", 0, "Buffer up to size bytes of sets that arrive before their template, per exporter (disabled by default)")
+	pendingAge := flag.Duration("pending-age", session.DefaultPendingAge, "Drop buffered sets that wait longer than the age for their template")
 	flag.Parse()
 
+	if *read != "" {
+		dumpFile(*read)
+		return
+	}
+
+	if *write != "" {
+		f, err := os.Create(*write)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		archive = ipfix.NewFileWriter(f)
+	}
+
+	manager := session.NewManager()
+	manager.IdleTimeout = *exporterTimeout
+	manager.TemplateTimeout = *templateTimeout
+	manager.PendingSize = *pendingSize
+	manager.PendingAge = *pendingAge
+	manager.OnExporter = func(addr string) {
+		log.Println("new exporter", addr)
+	}
+	manager.OnTemplate = func(addr string, event session.TemplateEvent, domain uint32, t session.Template) {
+		log.Printf("exporter %s domain %d template %d %s\n", addr, domain, t.ID(), event)
+	}
+	manager.OnEvict = func(addr string) {
+		log.Println("evicted idle exporter", addr)
+	}
+	manager.Sweep(time.Minute)
+	if *state != "" {
+		loadState(*state, manager)
+		keepState(*state, manager, time.Minute)
+	}
+
+	if *listenTCP != "" {
+		collector := &ipfix.TCPCollector{
+			// Templates received over TCP are valid for the lifetime of the
+			// connection, they don't expire.
+			Session: func(remote net.Addr) session.Session {
+				s := manager.Session(remote.String(), ipfix.Version)
+				s.SetTemplateTimeout(0)
+				return s
+			},
+			Handler: func(remote net.Addr, data []byte, m *ipfix.Message) {
+				log.Printf("received %d bytes from %s\n", m.Header.Length, remote)
+				dumpMutex.Lock()
+				dumpIPFIX(m)
+				if archive != nil {
+					if _, err := archive.Write(data); err != nil {
+						log.Println("archive error:", err)
+					}
+				}
+				dumpMutex.Unlock()
+			},
+			ErrorHandler: func(remote net.Addr, err error) {
+				log.Printf("error reading from %s: %v\n", remote, err)
+			},
+			// Exporters with an open connection may be quiet for longer
+			// than the idle timeout, they are not evicted.
+			ConnectHandler: func(remote net.Addr) {
+				manager.Acquire(remote.String())
+			},
+			CloseHandler: func(remote net.Addr) {
+				manager.Release(remote.String())
+			},
+		}
+		go func() {
+			log.Fatal(collector.ListenAndServe(*listenTCP))
+		}()
+	}
+
 	var addr *net.UDPAddr
 	var err error
 	if addr, err = net.ResolveUDPAddr("udp", *listen); err != nil {
@@ -47,7 +153,6 @@ func main() {
 		log.Fatal(err)
 	}
 
-	decoders := make(map[string]*netflow.Decoder)
 	for {
 		buf := make([]byte, 8192)
 		var remote *net.UDPAddr
@@ -59,12 +164,9 @@ func main() {
 
 		log.Printf("received %d bytes from %s\n", octets, remote)
 
-		d, found := decoders[remote.String()]
-		if !found {
-			s := session.New()
-			d = netflow.NewDecoder(s)
-			decoders[remote.String()] = d
-		}
+		d := netflow.NewDecoder(manager.Session(remote.String(), version(buf[:octets])))
+		d.Netflow9PendingHandler = dumpPendingNetflow9
+		d.IPFIXPendingHandler = dumpPendingIPFIX
 
 		m, err := d.Read(bytes.NewBuffer(buf[:octets]))
 		if err != nil {
@@ -72,6 +174,7 @@ func main() {
 			continue
 		}
 
+		dumpMutex.Lock()
 		switch p := m.(type) {
 		case *netflow1.Packet:
 			netflow1.Dump(p)
@@ -85,11 +188,107 @@ func main() {
 		case *netflow7.Packet:
 			netflow7.Dump(p)
 
+		case *netflow8.Packet:
+			netflow8.Dump(p)
+
 		case *netflow9.Packet:
-			netflow9.Dump(p)
+			dumpNetflow9(p)
 
 		case *ipfix.Message:
-			ipfix.Dump(p)
+			dumpIPFIX(p)
+			if archive != nil {
+				if _, err := archive.Write(buf[:octets]); err != nil {
+					log.Println("archive error:", err)
+				}
+			}
+
+		case *sflow.Datagram:
+			sflow.Dump(p)
+		}
+		dumpMutex.Unlock()
+	}
+}
+
+// version returns the version of the NetFlow packet or sFlow datagram.
+func version(b []byte) uint16 {
+	if len(b) < 4 {
+		return 0
+	}
+	if v := binary.BigEndian.Uint16(b); v != 0 {
+		return v
+	}
+	// sFlow uses a 32 bit version word
+	return binary.BigEndian.Uint16(b[2:])
+}
+
+func dumpFile(name string) {
+	f, err := os.Open(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	r := ipfix.NewFileReader(f)
+	r.VerifyChecksum = true
+	for {
+		m, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			log.Fatal(err)
 		}
+		dumpIPFIX(m)
+	}
+	for _, details := range r.ExportSessions {
+		log.Println("export session:", details)
+	}
+}
+
+func dumpNetflow9(p *netflow9.Packet) {
+	if natMode {
+		nat.Dump(nat.FromNetflow9Packet(p))
+	} else {
+		netflow9.Dump(p)
+	}
+}
+
+func dumpIPFIX(m *ipfix.Message) {
+	if natMode {
+		nat.Dump(nat.FromIPFIXMessage(m))
+	} else {
+		ipfix.Dump(m)
+	}
+}
+
+// dumpPendingNetflow9 dumps a Data FlowSet that arrived before its template.
+func dumpPendingNetflow9(sourceID uint32, dfs netflow9.DataFlowSet, err error) {
+	if err != nil {
+		log.Printf("error decoding pending data set for template %d of source %d: %v\n", dfs.Header.ID, sourceID, err)
+	} else {
+		log.Printf("decoded pending data set for template %d of source %d\n", dfs.Header.ID, sourceID)
+	}
+	dumpMutex.Lock()
+	dumpNetflow9(&netflow9.Packet{
+		Header:       netflow9.PacketHeader{Version: netflow9.Version, SourceID: sourceID},
+		DataFlowSets: []netflow9.DataFlowSet{dfs},
+	})
+	dumpMutex.Unlock()
+}
+
+// dumpPendingIPFIX dumps a Data Set that arrived before its template.
+func dumpPendingIPFIX(domain uint32, ds ipfix.DataSet, err error) {
+	if err != nil {
+		log.Printf("error decoding pending data set for template %d of domain %d: %v\n", ds.Header.ID, domain, err)
+	} else {
+		log.Printf("decoded pending data set for template %d of domain %d\n", ds.Header.ID, domain)
+	}
+	m := &ipfix.Message{Header: ipfix.MessageHeader{Version: ipfix.Version, ObservationDomainID: domain}}
+	if _, ok := ds.Template.(*ipfix.OptionsTemplateRecord); ok {
+		m.OptionsDataSets = append(m.OptionsDataSets, ds)
+	} else {
+		m.DataSets = append(m.DataSets, ds)
 	}
+	dumpMutex.Lock()
+	dumpIPFIX(m)
+	dumpMutex.Unlock()
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/tehmaze/netflow"
+	"github.com/tehmaze/netflow/session"
+)
+
+// loadState restores the templates, options and learned Information Elements
+// from the state file, a missing state file is not an error.
+func loadState(name string, m *session.Manager) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	if err = netflow.ReadState(f, m); err != nil {
+		log.Fatalf("error reading state from %s: %v\n", name, err)
+	}
+	log.Printf("restored %d exporters from %s\n", len(m.Exporters()), name)
+}
+
+// saveState writes the session state to the state file, the state is
+// written to a temporary file first, so a crash doesn't leave a partial state.
+func saveState(name string, m *session.Manager) error {
+	temp := name + ".tmp"
+	f, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+	if err = netflow.WriteState(f, m); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp, name)
+}
+
+// keepState saves the state every interval and when the process is
+// interrupted or terminated.
+func keepState(name string, m *session.Manager, interval time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := saveState(name, m); err != nil {
+					log.Println("error saving state:", err)
+				}
+			case sig := <-signals:
+				if err := saveState(name, m); err != nil {
+					log.Println("error saving state:", err)
+				}
+				log.Fatalln("exiting on", sig)
+			}
+		}
+	}()
+}
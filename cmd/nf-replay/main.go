@@ -0,0 +1,196 @@
+/*
+Command nf-replay resends NetFlow packets from one or more PCAP files to a
+collector. Packets from multiple files are replayed in the order they were
+captured.
+
+Usage:
+		nf-replay [flags] [<file>[ .. <file>]]
+
+Flags:
+		-target string 	Collector address (default "127.0.0.1:2055")
+		-timing 	Preserve the original inter-packet timing
+		-speed float 	Speed factor for the original timing (default 1)
+		-loop 	Replay the files until interrupted
+		-source string 	Local address to send from, each original exporter is sent from its own local port
+		-now 	Rewrite the export timestamps in the packet headers to the current time
+*/
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// packet is a captured export packet.
+type packet struct {
+	Timestamp time.Time
+	Exporter  string
+	Payload   []byte
+}
+
+// replayer sends packets to the target.
+type replayer struct {
+	target  *net.UDPAddr
+	source  *net.UDPAddr
+	now     bool
+	conns   map[string]*net.UDPConn
+	packets int
+}
+
+func main() {
+	target := flag.String("target", "127.0.0.1:2055", "Collector address")
+	timing := flag.Bool("timing", false, "Preserve the original inter-packet timing")
+	speed := flag.Float64("speed", 1, "Speed factor for the original timing")
+	loop := flag.Bool("loop", false, "Replay the files until interrupted")
+	source := flag.String("source", "", "Local address to send from, each original exporter is sent from its own local port")
+	now := flag.Bool("now", false, "Rewrite the export timestamps in the packet headers to the current time")
+	flag.Parse()
+
+	if *speed <= 0 {
+		log.Fatalln("speed must be positive")
+	}
+
+	r := &replayer{now: *now, conns: make(map[string]*net.UDPConn)}
+	var err error
+	if r.target, err = net.ResolveUDPAddr("udp", *target); err != nil {
+		log.Fatalln(err)
+	}
+	if *source != "" {
+		if r.source, err = net.ResolveUDPAddr("udp", net.JoinHostPort(*source, "0")); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	var packets []packet
+	for _, arg := range flag.Args() {
+		log.Println("reading", arg)
+		p, err := readFile(arg)
+		if err != nil {
+			log.Printf("error reading %s: %v\n", arg, err)
+			continue
+		}
+		packets = append(packets, p...)
+	}
+	if len(packets) == 0 {
+		log.Fatalln("no packets to replay")
+	}
+
+	// Interleave the packets from all files in capture order, packets with the
+	// same timestamp keep their order
+	sort.SliceStable(packets, func(i, j int) bool {
+		return packets[i].Timestamp.Before(packets[j].Timestamp)
+	})
+
+	for {
+		r.replay(packets, *timing, *speed)
+		if !*loop {
+			break
+		}
+	}
+	log.Printf("sent %d packets\n", r.packets)
+}
+
+// replay sends the packets, if timing is enabled the original inter-packet
+// gaps are preserved, scaled by the speed factor.
+func (r *replayer) replay(packets []packet, timing bool, speed float64) {
+	start := time.Now()
+	for _, p := range packets {
+		if timing {
+			offset := time.Duration(float64(p.Timestamp.Sub(packets[0].Timestamp)) / speed)
+			if wait := offset - time.Since(start); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		c, err := r.conn(p.Exporter)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		payload := p.Payload
+		if r.now {
+			payload = rewriteTimestamp(payload, time.Now())
+		}
+		if _, err = c.WriteToUDP(payload, r.target); err != nil {
+			log.Println("send error:", err)
+			continue
+		}
+		r.packets++
+	}
+}
+
+// conn returns the socket for the exporter. Without a source address all
+// packets are sent from the same socket, otherwise each exporter gets its own
+// socket so the collector sees them as separate exporters.
+func (r *replayer) conn(exporter string) (*net.UDPConn, error) {
+	if r.source == nil {
+		exporter = ""
+	}
+	if c, ok := r.conns[exporter]; ok {
+		return c, nil
+	}
+	c, err := net.ListenUDP("udp", r.source)
+	if err != nil {
+		return nil, err
+	}
+	if exporter != "" {
+		log.Printf("sending packets from %s as %s\n", exporter, c.LocalAddr())
+	}
+	r.conns[exporter] = c
+	return c, nil
+}
+
+// readFile reads the UDP payloads from a PCAP or PCAP-NG file.
+func readFile(name string) ([]packet, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var source *gopacket.PacketSource
+	if r, err := pcapgo.NewReader(f); err == nil {
+		source = gopacket.NewPacketSource(r, r.LinkType())
+	} else {
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		r, err := pcapgo.NewNgReader(f, pcapgo.DefaultNgReaderOptions)
+		if err != nil {
+			return nil, err
+		}
+		source = gopacket.NewPacketSource(r, r.LinkType())
+	}
+
+	var packets []packet
+	for {
+		p, err := source.NextPacket()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return packets, err
+		}
+
+		udp, ok := p.TransportLayer().(*layers.UDP)
+		if !ok || p.NetworkLayer() == nil {
+			continue
+		}
+		exporter := net.JoinHostPort(p.NetworkLayer().NetworkFlow().Src().String(), strconv.Itoa(int(udp.SrcPort)))
+		packets = append(packets, packet{
+			Timestamp: p.Metadata().Timestamp,
+			Exporter:  exporter,
+			Payload:   udp.LayerPayload(),
+		})
+	}
+	return packets, nil
+}
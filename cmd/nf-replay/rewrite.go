@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// rewriteTimestamp returns a copy of the packet with the export timestamp in
+// the header set to t. The system uptime is left as is, so the flow times
+// relative to the uptime are preserved. sFlow datagrams and unknown versions
+// have no wall clock timestamp and are returned unmodified.
+func rewriteTimestamp(payload []byte, t time.Time) []byte {
+	if len(payload) < 2 {
+		return payload
+	}
+
+	b := make([]byte, len(payload))
+	copy(b, payload)
+	switch binary.BigEndian.Uint16(b) {
+	case 1, 5, 6, 7, 8:
+		// Version, count, uptime, seconds, nanoseconds
+		if len(b) >= 16 {
+			binary.BigEndian.PutUint32(b[8:], uint32(t.Unix()))
+			binary.BigEndian.PutUint32(b[12:], uint32(t.Nanosecond()))
+		}
+	case 9:
+		// Version, count, uptime, seconds
+		if len(b) >= 12 {
+			binary.BigEndian.PutUint32(b[8:], uint32(t.Unix()))
+		}
+	case 10:
+		// Version, length, export time
+		if len(b) >= 8 {
+			binary.BigEndian.PutUint32(b[4:], uint32(t.Unix()))
+		}
+	}
+	return b
+}
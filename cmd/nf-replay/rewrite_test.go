@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestRewriteTimestamp(t *testing.T) {
+	// 2020-09-13T12:26:40.5Z is 0x5f5e1000 seconds and 0x1dcd6500 nanoseconds
+	now := time.Unix(1600000000, 500000000)
+	for _, test := range []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{"netflow1", "00010001000003e8aaaaaaaabbbbbbbbcccc", "00010001000003e85f5e10001dcd6500cccc"},
+		{"netflow5", "00050001000003e8aaaaaaaabbbbbbbbcccc", "00050001000003e85f5e10001dcd6500cccc"},
+		{"netflow6", "00060001000003e8aaaaaaaabbbbbbbbcccc", "00060001000003e85f5e10001dcd6500cccc"},
+		{"netflow7", "00070001000003e8aaaaaaaabbbbbbbbcccc", "00070001000003e85f5e10001dcd6500cccc"},
+		{"netflow8", "00080001000003e8aaaaaaaabbbbbbbbcccc", "00080001000003e85f5e10001dcd6500cccc"},
+		{"netflow9", "00090001000003e8aaaaaaaabbbbbbbb", "00090001000003e85f5e1000bbbbbbbb"},
+		{"ipfix", "000a0010aaaaaaaabbbbbbbbcccccccc", "000a00105f5e1000bbbbbbbbcccccccc"},
+		{"sflow", "00000005000000010a000001", "00000005000000010a000001"},
+		{"unknown version", "00ff0001000003e8aaaaaaaabbbbbbbb", "00ff0001000003e8aaaaaaaabbbbbbbb"},
+		{"empty", "", ""},
+		{"version only", "00", "00"},
+		{"short netflow5", "00050001000003e8aaaaaaaabbbbbb", "00050001000003e8aaaaaaaabbbbbb"},
+		{"short netflow9", "00090001000003e8aaaaaa", "00090001000003e8aaaaaa"},
+		{"short ipfix", "000a0010aaaaaa", "000a0010aaaaaa"},
+	} {
+		payload, _ := hex.DecodeString(test.payload)
+		original := append([]byte(nil), payload...)
+		got := rewriteTimestamp(payload, now)
+		if want, _ := hex.DecodeString(test.want); !bytes.Equal(got, want) {
+			t.Errorf("%s: expected %x, got %x", test.name, want, got)
+		}
+		if !bytes.Equal(payload, original) {
+			t.Errorf("%s: expected payload not to be modified, got %x", test.name, payload)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package convert
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/translate"
+)
+
+// Netflow5TemplateID is the IPFIX template ID used for NetFlow version 5
+// records. NetFlow version 9 templates keep their template ID, so don't mix
+// both versions in the same Observation Domain if the exporter uses this ID.
+const Netflow5TemplateID uint16 = 256
+
+// Information Elements used in the converted records
+const (
+	ieOctetDeltaCount             uint16 = 1
+	iePacketDeltaCount            uint16 = 2
+	ieProtocolIdentifier          uint16 = 4
+	ieIPClassOfService            uint16 = 5
+	ieTCPControlBits              uint16 = 6
+	ieSourceTransportPort         uint16 = 7
+	ieSourceIPv4Address           uint16 = 8
+	ieSourceIPv4PrefixLength      uint16 = 9
+	ieIngressInterface            uint16 = 10
+	ieDestinationTransportPort    uint16 = 11
+	ieDestinationIPv4Address      uint16 = 12
+	ieDestinationIPv4PrefixLength uint16 = 13
+	ieEgressInterface             uint16 = 14
+	ieIPNextHopIPv4Address        uint16 = 15
+	ieBGPSourceAsNumber           uint16 = 16
+	ieBGPDestinationAsNumber      uint16 = 17
+	ieFlowEndSysUpTime            uint16 = 21
+	ieFlowStartSysUpTime          uint16 = 22
+	ieFlowStartMilliseconds       uint16 = 152
+	ieFlowEndMilliseconds         uint16 = 153
+)
+
+// netflow5Template describes the converted NetFlow version 5 records, the
+// counters and interfaces use reduced size encoding to match their size in
+// the NetFlow version 5 record.
+var netflow5Template = ipfix.TemplateRecord{
+	TemplateID: Netflow5TemplateID,
+	Fields: ipfix.FieldSpecifiers{
+		{InformationElementID: ieSourceIPv4Address, Length: 4},
+		{InformationElementID: ieDestinationIPv4Address, Length: 4},
+		{InformationElementID: ieIPNextHopIPv4Address, Length: 4},
+		{InformationElementID: ieIngressInterface, Length: 2},
+		{InformationElementID: ieEgressInterface, Length: 2},
+		{InformationElementID: iePacketDeltaCount, Length: 4},
+		{InformationElementID: ieOctetDeltaCount, Length: 4},
+		{InformationElementID: ieFlowStartMilliseconds, Length: 8},
+		{InformationElementID: ieFlowEndMilliseconds, Length: 8},
+		{InformationElementID: ieSourceTransportPort, Length: 2},
+		{InformationElementID: ieDestinationTransportPort, Length: 2},
+		{InformationElementID: ieTCPControlBits, Length: 1},
+		{InformationElementID: ieProtocolIdentifier, Length: 1},
+		{InformationElementID: ieIPClassOfService, Length: 1},
+		{InformationElementID: ieBGPSourceAsNumber, Length: 2},
+		{InformationElementID: ieBGPDestinationAsNumber, Length: 2},
+		{InformationElementID: ieSourceIPv4PrefixLength, Length: 1},
+		{InformationElementID: ieDestinationIPv4PrefixLength, Length: 1},
+	},
+}
+
+// templateKey identifies a generated template.
+type templateKey struct {
+	domain     uint32
+	templateID uint16
+}
+
+// Converter converts NetFlow version 5 and version 9 flows to IPFIX data
+// records, and adds them to the exporter. The generated templates are added
+// to the exporter when they're first used, or when a NetFlow version 9
+// template changes.
+type Converter struct {
+	Exporter *ipfix.Exporter
+
+	// Generated templates, by their description
+	templates map[templateKey]string
+}
+
+// New returns a converter that adds the converted records to the exporter.
+func New(e *ipfix.Exporter) *Converter {
+	return &Converter{
+		Exporter:  e,
+		templates: make(map[templateKey]string),
+	}
+}
+
+// addTemplate adds the template to the exporter, unless the same template was
+// added before.
+func (c *Converter) addTemplate(domain uint32, template *ipfix.TemplateRecord) error {
+	key := templateKey{domain, template.TemplateID}
+	description := template.Fields.String()
+	if c.templates[key] == description {
+		return nil
+	}
+	if err := c.Exporter.AddTemplate(domain, template); err != nil {
+		return err
+	}
+	c.templates[key] = description
+	return nil
+}
+
+// Netflow5 converts the records in the NetFlow version 5 packet, and adds them
+// to the Observation Domain.
+func (c *Converter) Netflow5(p *netflow5.Packet, domain uint32) error {
+	template := netflow5Template
+	if err := c.addTemplate(domain, &template); err != nil {
+		return err
+	}
+
+	uptime := uint32(p.Header.SysUptime / time.Millisecond)
+	for _, r := range p.Records {
+		err := c.Exporter.AddRecord(domain, Netflow5TemplateID,
+			r.SrcAddr,
+			r.DstAddr,
+			r.NextHop,
+			r.Input,
+			r.Output,
+			r.Packets,
+			r.Bytes,
+			absolute(p.Header.Unix, uptime, r.First),
+			absolute(p.Header.Unix, uptime, r.Last),
+			r.SrcPort,
+			r.DstPort,
+			r.TCPFlags,
+			r.Protocol,
+			r.ToS,
+			r.SrcAS,
+			r.DstAS,
+			r.SrcMask,
+			r.DstMask,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Netflow9 converts the data records in the NetFlow version 9 packet, and adds
+// them to the Observation Domain. The field types are mapped to Information
+// Elements, vendor specific field types are mapped to their enterprise
+// specific Information Elements. Field types that don't map to a valid
+// Information Element are left out. The flow start and end SysUpTime are
+// converted to absolute times. Options data records are not converted.
+func (c *Converter) Netflow9(p *netflow9.Packet, domain uint32) error {
+	export := time.Unix(int64(p.Header.UnixSecs), 0)
+	for _, dfs := range p.DataFlowSets {
+		for _, dr := range dfs.Records {
+			template, fields := netflow9Template(dr)
+			if len(fields) == 0 {
+				continue
+			}
+			if err := c.addTemplate(domain, template); err != nil {
+				return err
+			}
+
+			values := make([]interface{}, len(fields))
+			for i, f := range fields {
+				switch f.Type {
+				case ieFlowStartSysUpTime, ieFlowEndSysUpTime:
+					values[i] = absolute(export, p.Header.SysUpTime, uint32(unsigned(f.Bytes)))
+				default:
+					values[i] = f.Bytes
+				}
+			}
+			if err := c.Exporter.AddRecord(domain, template.TemplateID, values...); err != nil {
+				return fmt.Errorf("template id=%d: %v", dr.TemplateID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// netflow9Template generates the template for a NetFlow version 9 data record,
+// and returns the fields of the record that are converted. Fields are skipped
+// if their Information Element ID doesn't fit in the 15 bits of a Field
+// Specifier, such as unknown vendor specific field types.
+func netflow9Template(dr netflow9.DataRecord) (*ipfix.TemplateRecord, []netflow9.Field) {
+	var (
+		template = &ipfix.TemplateRecord{TemplateID: dr.TemplateID}
+		fields   []netflow9.Field
+	)
+	for _, f := range dr.Fields {
+		fs := ipfix.FieldSpecifier{Length: f.Length}
+		switch f.Type {
+		case ieFlowStartSysUpTime:
+			fs.InformationElementID, fs.Length = ieFlowStartMilliseconds, 8
+		case ieFlowEndSysUpTime:
+			fs.InformationElementID, fs.Length = ieFlowEndMilliseconds, 8
+		default:
+			key := translate.NetFlow9Key(f.Type)
+			if key.FieldID&ipfix.EnterpriseBit != 0 {
+				continue
+			}
+			fs.InformationElementID = key.FieldID
+			if key.EnterpriseID != 0 {
+				fs.EnterpriseBitSet = true
+				fs.EnterpriseNumber = key.EnterpriseID
+			}
+		}
+		template.Fields = append(template.Fields, fs)
+		fields = append(fields, f)
+	}
+	template.FieldCount = uint16(len(template.Fields))
+	return template, fields
+}
+
+// absolute converts a SysUpTime timestamp in milliseconds to the wall clock
+// time, using the export time and SysUpTime of the packet.
+func absolute(export time.Time, uptime, timestamp uint32) time.Time {
+	return export.Add(time.Duration(int32(timestamp-uptime)) * time.Millisecond)
+}
+
+// unsigned decodes an unsigned integer, invalid lengths decode as zero.
+func unsigned(b []byte) uint64 {
+	v, _ := translate.Unsigned(b)
+	return v
+}
@@ -0,0 +1,74 @@
+package convert
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/session"
+)
+
+// NetFlow version 9 packet with a template carrying a legacy NSEL field type
+// and an unknown vendor specific field type, followed by a data record.
+var netflow9Packet = []byte{
+	// Packet header, 2 flowsets
+	0x00, 0x09, 0x00, 0x02, 0x00, 0x01, 0x5f, 0x90,
+	0x5f, 0x5e, 0x10, 0x00, 0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x00, 0x01,
+	// Template flowset, template 256 with 3 fields
+	0x00, 0x00, 0x00, 0x14, 0x01, 0x00, 0x00, 0x03,
+	0x00, 0x08, 0x00, 0x04, // IPV4_SRC_ADDR
+	0x9c, 0x41, 0x00, 0x04, // NF_F_XLATE_SRC_ADDR_IPV4 (40001)
+	0x82, 0xdc, 0x00, 0x02, // unknown (33500)
+	// Data flowset for template 256, padded
+	0x01, 0x00, 0x00, 0x10,
+	192, 0, 2, 1,
+	198, 51, 100, 1,
+	0x12, 0x34,
+	0x00, 0x00,
+}
+
+func TestNetflow9(t *testing.T) {
+	p, err := netflow9.NewDecoder(nil, session.New()).Decode(netflow9Packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := ipfix.NewExporter(0)
+	if err = New(e).Netflow9(p, 1); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := e.Flush(time.Unix(int64(p.Header.UnixSecs), 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected one message, got %d", len(messages))
+	}
+
+	m, err := ipfix.NewDecoder(nil, session.New()).Decode(messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.DataSets) != 1 || len(m.DataSets[0].Records) != 1 {
+		t.Fatalf("expected one data record, got %+v", m.DataSets)
+	}
+	fields := m.DataSets[0].Records[0].Fields
+	if len(fields) != 2 {
+		t.Fatalf("expected the unknown field type to be skipped, got %d fields", len(fields))
+	}
+	for i, expect := range []struct {
+		name  string
+		value net.IP
+	}{
+		{"sourceIPv4Address", net.IP{192, 0, 2, 1}},
+		{"postNATSourceIPv4Address", net.IP{198, 51, 100, 1}},
+	} {
+		f := fields[i].Translated
+		if f == nil || f.Name != expect.name || !f.Value.(net.IP).Equal(expect.value) {
+			t.Fatalf("field %d: expected %s %s, got %+v", i, expect.name, expect.value, f)
+		}
+	}
+}
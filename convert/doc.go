@@ -0,0 +1,12 @@
+/*
+Package convert translates NetFlow version 5 and version 9 flows to IPFIX.
+
+About
+
+The flow records are converted to IPFIX data records using the matching IANA
+Information Elements, and added to an IPFIX exporter. The templates for the
+IPFIX data records are generated automatically, so a mediator can forward all
+flows as IPFIX. Timestamps relative to the system uptime of the exporter are
+converted to absolute flowStartMilliseconds and flowEndMilliseconds.
+*/
+package convert
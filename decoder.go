@@ -11,13 +11,21 @@ import (
 	"github.com/tehmaze/netflow/netflow5"
 	"github.com/tehmaze/netflow/netflow6"
 	"github.com/tehmaze/netflow/netflow7"
+	"github.com/tehmaze/netflow/netflow8"
 	"github.com/tehmaze/netflow/netflow9"
 	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/sflow"
 )
 
 // Decoder for NetFlow messages.
 type Decoder struct {
 	session.Session
+
+	// Netflow9PendingHandler and IPFIXPendingHandler, if set, are called with
+	// the sets that arrived before their template, see the PendingHandler of
+	// netflow9.Translate and ipfix.Translate.
+	Netflow9PendingHandler func(sourceID uint32, dfs netflow9.DataFlowSet, err error)
+	IPFIXPendingHandler    func(domain uint32, ds ipfix.DataSet, err error)
 }
 
 // Message generlized interface.
@@ -26,7 +34,7 @@ type Message interface {
 
 // NewDecoder sets up a decoder suitable for reading NetFlow packets.
 func NewDecoder(s session.Session) *Decoder {
-	return &Decoder{s}
+	return &Decoder{Session: s}
 }
 
 // Read a single Netflow message from the network. If an error is returned,
@@ -41,6 +49,20 @@ func (d *Decoder) Read(r io.Reader) (Message, error) {
 	buffer := bytes.NewBuffer(data[:])
 	mr := io.MultiReader(buffer, r)
 
+	// sFlow uses a 32 bit version word, where NetFlow uses a 16 bit version
+	// word, so a leading zero word indicates an sFlow datagram.
+	if version == 0 {
+		more := [2]byte{}
+		if _, err := r.Read(more[:]); err != nil {
+			return nil, err
+		}
+		buffer.Write(more[:])
+		if v := binary.BigEndian.Uint16(more[:]); uint32(v) != sflow.Version {
+			return nil, fmt.Errorf("netflow: unsupported sflow version %d", v)
+		}
+		return sflow.Read(mr)
+	}
+
 	switch version {
 	case netflow1.Version:
 		return netflow1.Read(mr)
@@ -54,11 +76,24 @@ func (d *Decoder) Read(r io.Reader) (Message, error) {
 	case netflow7.Version:
 		return netflow7.Read(mr)
 
+	case netflow8.Version:
+		return netflow8.Read(mr)
+
 	case netflow9.Version:
-		return netflow9.Read(mr, d.Session, nil)
+		var t *netflow9.Translate
+		if d.Session != nil {
+			t = netflow9.NewTranslate(d.Session)
+			t.PendingHandler = d.Netflow9PendingHandler
+		}
+		return netflow9.Read(mr, d.Session, t)
 
 	case ipfix.Version:
-		return ipfix.Read(mr, d.Session, nil)
+		var t *ipfix.Translate
+		if d.Session != nil {
+			t = ipfix.NewTranslate(d.Session)
+			t.PendingHandler = d.IPFIXPendingHandler
+		}
+		return ipfix.Read(mr, d.Session, t)
 
 	default:
 		return nil, fmt.Errorf("netflow: unsupported version %d", version)
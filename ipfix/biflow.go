@@ -0,0 +1,116 @@
+package ipfix
+
+import (
+	"github.com/tehmaze/netflow/translate"
+)
+
+// ReversePEN is the Private Enterprise Number used for the Reverse Information
+// Elements in Biflow records (RFC 5103 section 6.1).
+const ReversePEN uint32 = 29305
+
+// Flow key Information Elements shared by both directions of a Biflow, mapped
+// to their counterpart in the opposite direction.
+var biflowKeys = map[uint16]uint16{
+	4:  4,  // protocolIdentifier
+	7:  11, // sourceTransportPort
+	8:  12, // sourceIPv4Address
+	11: 7,  // destinationTransportPort
+	12: 8,  // destinationIPv4Address
+	27: 28, // sourceIPv6Address
+	28: 27, // destinationIPv6Address
+}
+
+// Translator for builtin Information Elements, used to look up reverse
+// Information Elements and the names of their forward counterparts.
+var biflowTranslate = translate.NewTranslate(nil)
+
+// Biflow is a view on a Data Record containing a Biflow (RFC 5103). The fields
+// are split in the flow key fields shared by both directions, and the fields
+// for the forward and reverse directions. Reverse Information Elements are
+// converted to their forward counterpart, so the same Information Element ID
+// is used for, say, the octet count in both directions.
+//
+// Non-reversible Information Elements (RFC 5103 section 6.1) are considered
+// shared, all other fields without a Reverse Information Element belong to the
+// forward direction.
+type Biflow struct {
+	Key     Fields
+	Forward Fields
+	Reverse Fields
+}
+
+// IsBiflow checks if the Data Record contains any Reverse Information Elements.
+func IsBiflow(dr *DataRecord) bool {
+	for _, f := range dr.Fields {
+		if f.Translated != nil && f.Translated.EnterpriseNumber == ReversePEN {
+			return true
+		}
+	}
+	return false
+}
+
+// NewBiflow splits a translated Data Record into its directions. If the Data
+// Record has no Reverse Information Elements, all non-shared fields are in the
+// forward direction.
+func NewBiflow(dr *DataRecord) *Biflow {
+	b := new(Biflow)
+	for _, f := range dr.Fields {
+		switch {
+		case f.Translated == nil:
+			b.Forward = append(b.Forward, f)
+
+		case f.Translated.EnterpriseNumber == ReversePEN:
+			b.Reverse = append(b.Reverse, biflowField(f, f.Translated.InformationElementID))
+
+		case f.Translated.EnterpriseNumber != 0:
+			b.Forward = append(b.Forward, f)
+
+		default:
+			id := f.Translated.InformationElementID
+			if _, ok := biflowKeys[id]; ok || !isReversible(id) {
+				b.Key = append(b.Key, f)
+			} else {
+				b.Forward = append(b.Forward, f)
+			}
+		}
+	}
+	return b
+}
+
+// Uniflows turns the Biflow into two Uniflow records. The forward record has
+// the key fields and the forward fields, the reverse record has the key fields
+// with source and destination swapped and the reverse fields. The records are
+// not described by a Template.
+func (b *Biflow) Uniflows() (forward, reverse DataRecord) {
+	forward.Fields = append(append(Fields{}, b.Key...), b.Forward...)
+
+	reverse.Fields = make(Fields, 0, len(b.Key)+len(b.Reverse))
+	for _, f := range b.Key {
+		if f.Translated != nil && f.Translated.EnterpriseNumber == 0 {
+			if id, ok := biflowKeys[f.Translated.InformationElementID]; ok {
+				f = biflowField(f, id)
+			}
+		}
+		reverse.Fields = append(reverse.Fields, f)
+	}
+	reverse.Fields = append(reverse.Fields, b.Reverse...)
+	return
+}
+
+// biflowField returns a copy of the field as the IANA Information Element id.
+func biflowField(f Field, id uint16) Field {
+	translated := *f.Translated
+	translated.EnterpriseNumber = 0
+	translated.InformationElementID = id
+	if element, ok := biflowTranslate.Key(translate.Key{EnterpriseID: 0, FieldID: id}); ok {
+		translated.Name = element.Name
+	}
+	f.Translated = &translated
+	return f
+}
+
+// isReversible checks if there is a Reverse Information Element for id.
+func isReversible(id uint16) bool {
+	_, ok := biflowTranslate.Key(translate.Key{EnterpriseID: ReversePEN, FieldID: id})
+	return ok
+}
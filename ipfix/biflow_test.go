@@ -0,0 +1,108 @@
+package ipfix
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+// testBiflowRecord returns a decoded Biflow record of a TCP connection from
+// 10.0.0.1:49152 to 192.0.2.1:443, with reverse octet and packet counts.
+func testBiflowRecord(t *testing.T) *DataRecord {
+	e := NewExporter(0)
+	if err := e.AddTemplate(1, &TemplateRecord{TemplateID: 300, Fields: FieldSpecifiers{
+		{InformationElementID: 8, Length: 4},  // sourceIPv4Address
+		{InformationElementID: 12, Length: 4}, // destinationIPv4Address
+		{InformationElementID: 7, Length: 2},  // sourceTransportPort
+		{InformationElementID: 11, Length: 2}, // destinationTransportPort
+		{InformationElementID: 4, Length: 1},  // protocolIdentifier
+		{InformationElementID: 1, Length: 8},  // octetDeltaCount
+		{InformationElementID: 2, Length: 8},  // packetDeltaCount
+		{EnterpriseBitSet: true, EnterpriseNumber: ReversePEN, InformationElementID: 1, Length: 8},
+		{EnterpriseBitSet: true, EnterpriseNumber: ReversePEN, InformationElementID: 2, Length: 8},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddRecord(1, 300, net.IP{10, 0, 0, 1}, net.IP{192, 0, 2, 1}, uint16(49152), uint16(443),
+		uint8(6), uint64(1500), uint64(10), uint64(64000), uint64(50)); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := e.Flush(time.Unix(1600000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewDecoder(nil, session.New()).Decode(messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &m.DataSets[0].Records[0]
+}
+
+// biflowValues maps the names of the translated fields to their values.
+func biflowValues(fields Fields) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, f := range fields {
+		values[f.Translated.Name] = f.Translated.Value
+	}
+	return values
+}
+
+func TestBiflow(t *testing.T) {
+	dr := testBiflowRecord(t)
+	if !IsBiflow(dr) {
+		t.Fatal("expected record with reverse elements to be a biflow")
+	}
+
+	b := NewBiflow(dr)
+	if len(b.Key) != 5 || len(b.Forward) != 2 || len(b.Reverse) != 2 {
+		t.Fatalf("unexpected biflow %+v", b)
+	}
+	for _, f := range b.Reverse {
+		if f.Translated.EnterpriseNumber != 0 {
+			t.Fatalf("expected reverse field as IANA element, got %+v", f.Translated)
+		}
+	}
+
+	forward, reverse := b.Uniflows()
+	values := biflowValues(forward.Fields)
+	if !values["sourceIPv4Address"].(net.IP).Equal(net.IP{10, 0, 0, 1}) ||
+		!values["destinationIPv4Address"].(net.IP).Equal(net.IP{192, 0, 2, 1}) ||
+		values["sourceTransportPort"] != uint16(49152) || values["destinationTransportPort"] != uint16(443) ||
+		values["protocolIdentifier"] != uint8(6) ||
+		values["octetDeltaCount"] != uint64(1500) || values["packetDeltaCount"] != uint64(10) {
+		t.Fatalf("unexpected forward uniflow %v", values)
+	}
+
+	// The reverse direction has source and destination swapped
+	values = biflowValues(reverse.Fields)
+	if len(reverse.Fields) != 7 ||
+		!values["sourceIPv4Address"].(net.IP).Equal(net.IP{192, 0, 2, 1}) ||
+		!values["destinationIPv4Address"].(net.IP).Equal(net.IP{10, 0, 0, 1}) ||
+		values["sourceTransportPort"] != uint16(443) || values["destinationTransportPort"] != uint16(49152) ||
+		values["protocolIdentifier"] != uint8(6) ||
+		values["octetDeltaCount"] != uint64(64000) || values["packetDeltaCount"] != uint64(50) {
+		t.Fatalf("unexpected reverse uniflow %v", values)
+	}
+
+	// Splitting does not modify the record
+	if f := dr.Fields[0].Translated; f.Name != "sourceIPv4Address" || f.EnterpriseNumber != 0 {
+		t.Fatalf("unexpected source field %+v", f)
+	}
+	if f := dr.Fields[7].Translated; f.EnterpriseNumber != ReversePEN || f.InformationElementID != 1 {
+		t.Fatalf("unexpected reverse field %+v", f)
+	}
+}
+
+func TestUniflow(t *testing.T) {
+	dr := testBiflowRecord(t)
+	dr.Fields = dr.Fields[:7]
+	if IsBiflow(dr) {
+		t.Fatal("expected record without reverse elements not to be a biflow")
+	}
+	b := NewBiflow(dr)
+	if len(b.Key) != 5 || len(b.Forward) != 2 || len(b.Reverse) != 0 {
+		t.Fatalf("unexpected biflow %+v", b)
+	}
+}
@@ -3,6 +3,9 @@ package ipfix
 import (
 	"encoding/hex"
 	"fmt"
+	"strings"
+
+	"github.com/tehmaze/netflow/translate"
 )
 
 func Dump(m *Message) {
@@ -14,20 +17,70 @@ func Dump(m *Message) {
 			fmt.Println(hex.Dump(ds.Bytes))
 			continue
 		}
-		fmt.Printf("    %d records:\n", len(ds.Records))
-		for i, dr := range ds.Records {
-			fmt.Printf("      record %d:\n", i)
-			for _, f := range dr.Fields {
-				if f.Translated != nil {
-					if f.Translated.Name != "" {
-						fmt.Printf("        %s: %v\n", f.Translated.Name, f.Translated.Value)
-					} else {
-						fmt.Printf("        %d.%d: %v\n", f.Translated.EnterpriseNumber, f.Translated.InformationElementID, f.Bytes)
-					}
-				} else {
-					fmt.Printf("        %v\n", f.Bytes)
-				}
+		dumpRecords(ds.Records, 4)
+	}
+}
+
+func dumpRecords(records []DataRecord, indent int) {
+	pad := strings.Repeat(" ", indent)
+	fmt.Printf("%s%d records:\n", pad, len(records))
+	for i, dr := range records {
+		fmt.Printf("%s  record %d:\n", pad, i)
+		dumpFields(dr.Fields, indent+4)
+		if dr.InnerFlow != nil {
+			fmt.Printf("%s    inner flow: %s\n", pad, dr.InnerFlow)
+		}
+	}
+}
+
+func dumpFields(fields Fields, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for _, f := range fields {
+		if f.Translated == nil {
+			fmt.Printf("%s%v\n", pad, f.Bytes)
+			continue
+		}
+
+		name := f.Translated.Name
+		if name == "" {
+			fmt.Printf("%s%d.%d: %v\n", pad, f.Translated.EnterpriseNumber, f.Translated.InformationElementID, f.Bytes)
+			continue
+		}
+
+		if f.Translated.Packet != nil {
+			fmt.Printf("%s%s: %s\n", pad, name, f.Translated.Packet)
+			continue
+		}
+
+		switch v := f.Translated.Value.(type) {
+		case *BasicList:
+			fmt.Printf("%s%s: %s list of %d.%d:\n", pad, name, SemanticName(v.Semantic), v.Field.EnterpriseNumber, v.Field.InformationElementID)
+			dumpFields(v.Elements, indent+2)
+		case *SubTemplateList:
+			fmt.Printf("%s%s: %s list:\n", pad, name, SemanticName(v.Semantic))
+			dumpSubTemplateList(v, indent+2)
+		case *SubTemplateMultiList:
+			fmt.Printf("%s%s: %s multi list of %d entries:\n", pad, name, SemanticName(v.Semantic), len(v.Entries))
+			for i := range v.Entries {
+				dumpSubTemplateList(&v.Entries[i], indent+2)
+			}
+		default:
+			key := translate.Key{EnterpriseID: f.Translated.EnterpriseNumber, FieldID: f.Translated.InformationElementID}
+			if enum, ok := translate.Description(key, f.Translated.Value, f.Bytes); ok {
+				fmt.Printf("%s%s: %v (%s)\n", pad, name, f.Translated.Value, enum)
+			} else {
+				fmt.Printf("%s%s: %v\n", pad, name, f.Translated.Value)
 			}
 		}
 	}
 }
+
+func dumpSubTemplateList(stl *SubTemplateList, indent int) {
+	pad := strings.Repeat(" ", indent)
+	fmt.Printf("%stemplate %d:\n", pad, stl.TemplateID)
+	if stl.Records == nil {
+		fmt.Printf("%s  %d raw bytes: %s\n", pad, len(stl.Bytes), hex.EncodeToString(stl.Bytes))
+		return
+	}
+	dumpRecords(stl.Records, indent+2)
+}
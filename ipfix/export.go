@@ -0,0 +1,321 @@
+package ipfix
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/translate"
+)
+
+// DefaultMTU is the default maximum size of exported messages, a message of
+// this size fits in a single UDP datagram on Ethernet.
+const DefaultMTU = 1472
+
+// Sizes of the Message Header and Set Header
+const (
+	exportMessageHeaderLen = 16
+	exportSetHeaderLen     = 4
+)
+
+// Exporter builds IPFIX Messages from data records with Go values. Templates
+// and records are kept per Observation Domain, and packed in messages that do
+// not exceed the MTU. Each message returned by Flush can be sent as a single
+// UDP datagram, or written to a TCP connection or IPFIX File.
+type Exporter struct {
+	// MTU is the maximum size of a message.
+	MTU int
+
+	// TemplateRefresh is the interval at which all templates are sent again,
+	// as required when exporting over UDP (RFC 7011 section 8.4). If zero,
+	// templates are only sent once.
+	TemplateRefresh time.Duration
+
+	domains   map[uint32]*exportDomain
+	translate *translate.Translate
+}
+
+// exportDomain is the exporting state of an Observation Domain.
+type exportDomain struct {
+	templates map[uint16]session.Template
+	order     []uint16
+	pending   []uint16
+	refreshed time.Time
+	sequence  uint32
+	records   []exportRecord
+}
+
+// exportRecord is an encoded record waiting to be exported, in the set with
+// the given ID.
+type exportRecord struct {
+	setID uint16
+	data  []byte
+}
+
+// NewExporter returns a new IPFIX exporter, if mtu is zero the DefaultMTU is
+// used.
+func NewExporter(mtu int) *Exporter {
+	if mtu == 0 {
+		mtu = DefaultMTU
+	}
+	return &Exporter{
+		MTU:       mtu,
+		domains:   make(map[uint32]*exportDomain),
+		translate: translate.NewTranslate(nil),
+	}
+}
+
+func (e *Exporter) domain(id uint32) *exportDomain {
+	d, ok := e.domains[id]
+	if !ok {
+		d = &exportDomain{templates: make(map[uint16]session.Template)}
+		e.domains[id] = d
+	}
+	return d
+}
+
+// AddTemplate adds a Template Record or Options Template Record to the
+// Observation Domain, the template is sent with the next message. Adding a
+// template with the ID of an existing template replaces it.
+func (e *Exporter) AddTemplate(domain uint32, template session.Template) error {
+	switch template.(type) {
+	case *TemplateRecord, *OptionsTemplateRecord:
+	default:
+		return fmt.Errorf("unsupported template type %T", template)
+	}
+	if template.ID() < 256 {
+		return errProtocol(fmt.Sprintf("template id=%d is reserved", template.ID()))
+	}
+
+	var data []byte
+	switch template := template.(type) {
+	case *TemplateRecord:
+		data = template.Bytes()
+	case *OptionsTemplateRecord:
+		data = template.Bytes()
+	}
+	if err := e.checkSize(template.ID(), data); err != nil {
+		return err
+	}
+
+	d := e.domain(domain)
+	if _, ok := d.templates[template.ID()]; !ok {
+		d.order = append(d.order, template.ID())
+	}
+	d.templates[template.ID()] = template
+	d.pending = append(d.pending, template.ID())
+	return nil
+}
+
+// Template returns the template with the ID in the Observation Domain.
+func (e *Exporter) Template(domain uint32, templateID uint16) (session.Template, bool) {
+	if d, ok := e.domains[domain]; ok {
+		template, ok := d.templates[templateID]
+		return template, ok
+	}
+	return nil, false
+}
+
+// AddRecord encodes a data record using the template in the Observation
+// Domain. There must be a value for each field in the template, for Options
+// Template Records the values of the scope fields come first. Values are
+// encoded according to the field type of their Information Element, a []byte
+// value is used as-is.
+func (e *Exporter) AddRecord(domain uint32, templateID uint16, values ...interface{}) error {
+	d := e.domain(domain)
+	template, ok := d.templates[templateID]
+	if !ok {
+		return errTemplateNotFound(templateID)
+	}
+
+	fields := template.GetFields()
+	if options, ok := template.(*OptionsTemplateRecord); ok {
+		fields = append(options.GetScopeFields(), fields...)
+	}
+	if len(values) != len(fields) {
+		return fmt.Errorf("template id=%d has %d fields, got %d values", templateID, len(fields), len(values))
+	}
+
+	var data []byte
+	for i, value := range values {
+		fs := fields[i].(FieldSpecifier)
+		b, err := e.marshalValue(value, fs)
+		if err != nil {
+			return fmt.Errorf("template id=%d field %d: %v", templateID, i, err)
+		}
+		data = append(data, Field{Bytes: b}.Marshal(fs)...)
+	}
+	if len(data) == 0 {
+		return errProtocol(fmt.Sprintf("template id=%d has no fields", templateID))
+	}
+	if err := e.checkSize(templateID, data); err != nil {
+		return err
+	}
+
+	d.records = append(d.records, exportRecord{setID: templateID, data: data})
+	return nil
+}
+
+// checkSize checks if a message with just the encoded record fits in the MTU,
+// so a single record can't hold up the records queued after it.
+func (e *Exporter) checkSize(templateID uint16, data []byte) error {
+	if exportMessageHeaderLen+exportSetHeaderLen+len(data) > e.MTU {
+		return errProtocol(fmt.Sprintf("record of %d bytes for template id=%d exceeds MTU %d", len(data), templateID, e.MTU))
+	}
+	return nil
+}
+
+func (e *Exporter) marshalValue(value interface{}, fs FieldSpecifier) ([]byte, error) {
+	if b, ok := value.([]byte); ok {
+		if fs.Length != VariableLength && len(b) != int(fs.Length) {
+			return nil, fmt.Errorf("value of %d bytes does not match field length %d", len(b), fs.Length)
+		}
+		return b, nil
+	}
+
+	ft := translate.OctetArray
+	if element, ok := e.translate.Key(translate.Key{EnterpriseID: fs.EnterpriseNumber, FieldID: fs.InformationElementID}); ok {
+		ft = element.Type
+	}
+	return translate.Marshal(value, ft, fs.Length)
+}
+
+// Flush packs the pending templates and records of all Observation Domains in
+// messages. The export time of the messages is set to now, which is also used
+// to decide if the templates are due for a refresh.
+func (e *Exporter) Flush(now time.Time) ([][]byte, error) {
+	ids := make([]uint32, 0, len(e.domains))
+	for id := range e.domains {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var messages [][]byte
+	for _, id := range ids {
+		m, err := e.flush(id, e.domains[id], now)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, m...)
+	}
+	return messages, nil
+}
+
+func (e *Exporter) flush(id uint32, d *exportDomain, now time.Time) ([][]byte, error) {
+	if e.TemplateRefresh > 0 && len(d.order) > 0 && now.Sub(d.refreshed) >= e.TemplateRefresh {
+		d.pending = append([]uint16(nil), d.order...)
+	}
+
+	var records []exportRecord
+	sent := make(map[uint16]bool)
+	for _, templateID := range d.pending {
+		template, ok := d.templates[templateID]
+		if !ok || sent[templateID] {
+			continue
+		}
+		sent[templateID] = true
+		switch template := template.(type) {
+		case *TemplateRecord:
+			records = append(records, exportRecord{setID: 2, data: template.Bytes()})
+		case *OptionsTemplateRecord:
+			records = append(records, exportRecord{setID: 3, data: template.Bytes()})
+		}
+	}
+	records = append(records, d.records...)
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	b := exportBuilder{mtu: e.MTU, domain: id, exportTime: uint32(now.Unix()), sequence: d.sequence}
+	for _, r := range records {
+		if err := b.add(r); err != nil {
+			return nil, err
+		}
+	}
+	b.closeMessage()
+
+	if len(d.pending) > 0 {
+		d.refreshed = now
+	}
+	d.pending = nil
+	d.records = nil
+	d.sequence = b.sequence
+	return b.messages, nil
+}
+
+// exportBuilder packs records in sets, and sets in messages.
+type exportBuilder struct {
+	mtu        int
+	domain     uint32
+	exportTime uint32
+	sequence   uint32
+
+	messages [][]byte
+	message  []byte
+	set      []byte
+	setID    uint16
+	count    uint32
+}
+
+func (b *exportBuilder) add(r exportRecord) error {
+	if exportMessageHeaderLen+exportSetHeaderLen+len(r.data) > b.mtu {
+		return errProtocol(fmt.Sprintf("record of %d bytes in set id=%d exceeds MTU %d", len(r.data), r.setID, b.mtu))
+	}
+
+	if b.set != nil && r.setID != b.setID {
+		b.closeSet()
+	}
+	size := len(r.data)
+	if b.set == nil {
+		size += exportSetHeaderLen
+	}
+	if b.message != nil && len(b.message)+len(b.set)+size > b.mtu {
+		b.closeMessage()
+	}
+
+	if b.message == nil {
+		b.message = make([]byte, exportMessageHeaderLen)
+	}
+	if b.set == nil {
+		b.set = make([]byte, exportSetHeaderLen)
+		b.setID = r.setID
+	}
+	b.set = append(b.set, r.data...)
+	if r.setID >= 256 {
+		b.count++
+	}
+	return nil
+}
+
+func (b *exportBuilder) closeSet() {
+	binary.BigEndian.PutUint16(b.set[0:], b.setID)
+	binary.BigEndian.PutUint16(b.set[2:], uint16(len(b.set)))
+	b.message = append(b.message, b.set...)
+	b.set = nil
+}
+
+func (b *exportBuilder) closeMessage() {
+	if b.set != nil {
+		b.closeSet()
+	}
+	if b.message == nil {
+		return
+	}
+
+	// The sequence number is the number of data records sent before this
+	// message in the Observation Domain (RFC 7011 section 3.1)
+	header := MessageHeader{
+		Version:             Version,
+		Length:              uint16(len(b.message)),
+		ExportTime:          b.exportTime,
+		SequenceNumber:      b.sequence,
+		ObservationDomainID: b.domain,
+	}
+	copy(b.message, header.Bytes())
+	b.messages = append(b.messages, b.message)
+	b.sequence += b.count
+	b.message = nil
+	b.count = 0
+}
@@ -0,0 +1,162 @@
+package ipfix
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+func testExporter(t *testing.T, mtu int) *Exporter {
+	e := NewExporter(mtu)
+	e.TemplateRefresh = time.Minute
+	if err := e.AddTemplate(7, &TemplateRecord{TemplateID: 300, Fields: FieldSpecifiers{
+		{InformationElementID: 8, Length: 4},      // sourceIPv4Address
+		{InformationElementID: 12, Length: 4},     // destinationIPv4Address
+		{InformationElementID: 1, Length: 4},      // octetDeltaCount, reduced size
+		{InformationElementID: 152, Length: 8},    // flowStartMilliseconds
+		{InformationElementID: 82, Length: 65535}, // interfaceName
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddTemplate(7, &OptionsTemplateRecord{TemplateID: 301,
+		ScopeFields: FieldSpecifiers{{InformationElementID: 10, Length: 4}},
+		Fields:      FieldSpecifiers{{InformationElementID: 82, Length: 65535}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestExporterRoundTrip(t *testing.T) {
+	const mtu = 200
+	e := testExporter(t, mtu)
+	now := time.Unix(1600000000, 0)
+	for i := 0; i < 10; i++ {
+		if err := e.AddRecord(7, 300, net.IP{10, 0, 0, byte(i)}, net.IP{10, 1, 1, 1}, uint64(1000*i), now, "eth0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.AddRecord(7, 301, 3, "ge-0/0/0"); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := e.Flush(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) < 2 {
+		t.Fatalf("expected the records to be split over multiple messages, got %d", len(messages))
+	}
+
+	s := session.New()
+	d := NewDecoder(nil, s)
+	var (
+		records  int
+		sequence uint32
+	)
+	for _, data := range messages {
+		if len(data) > mtu {
+			t.Fatalf("message of %d bytes exceeds MTU %d", len(data), mtu)
+		}
+		m, err := d.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Header.ObservationDomainID != 7 || m.Header.ExportTime != uint32(now.Unix()) {
+			t.Fatalf("unexpected header %+v", m.Header)
+		}
+		if m.Header.SequenceNumber != sequence {
+			t.Fatalf("expected sequence number %d, got %d", sequence, m.Header.SequenceNumber)
+		}
+		for _, ds := range m.DataSets {
+			for _, dr := range ds.Records {
+				if ip := dr.Fields[0].Translated.Value; !ip.(net.IP).Equal(net.IP{10, 0, 0, byte(records)}) {
+					t.Fatalf("record %d: unexpected source address %v", records, ip)
+				}
+				if octets := dr.Fields[2].Translated.Value; octets != uint64(1000*records) {
+					t.Fatalf("record %d: unexpected octets %v", records, octets)
+				}
+				records++
+				sequence++
+			}
+		}
+		for _, ds := range m.OptionsDataSets {
+			sequence += uint32(len(ds.Records))
+		}
+	}
+	if records != 10 {
+		t.Fatalf("expected 10 records, got %d", records)
+	}
+	if option := s.GetOption(7, 0, 82, session.SCOPE_INTERFACE, 3); option == nil {
+		t.Fatal("expected interface option")
+	}
+
+	// Templates are not sent again until the refresh interval passed
+	if err := e.AddRecord(7, 300, net.IP{1, 1, 1, 1}, net.IP{2, 2, 2, 2}, uint32(5), now, ""); err != nil {
+		t.Fatal(err)
+	}
+	if messages, err = e.Flush(now.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	m, err := d.Decode(messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.TemplateSets) != 0 || len(m.OptionsTemplateSets) != 0 {
+		t.Fatal("unexpected template refresh")
+	}
+	if m.Header.SequenceNumber != sequence {
+		t.Fatalf("expected sequence number %d, got %d", sequence, m.Header.SequenceNumber)
+	}
+
+	if messages, err = e.Flush(now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected one message with templates, got %d", len(messages))
+	}
+	if m, err = d.Decode(messages[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.TemplateSets) != 1 || len(m.OptionsTemplateSets) != 1 {
+		t.Fatal("expected templates to be refreshed")
+	}
+}
+
+func TestExporterInvalid(t *testing.T) {
+	e := testExporter(t, 64)
+	if err := e.AddTemplate(7, &TemplateRecord{TemplateID: 255}); err == nil {
+		t.Fatal("expected reserved template id to fail")
+	}
+	if err := e.AddRecord(7, 302, 1); err == nil {
+		t.Fatal("expected unknown template to fail")
+	}
+	if err := e.AddRecord(7, 300, 1, 2, 3); err == nil {
+		t.Fatal("expected missing values to fail")
+	}
+	if err := e.AddTemplate(7, &TemplateRecord{TemplateID: 302, Fields: make(FieldSpecifiers, 12)}); err == nil {
+		t.Fatal("expected template exceeding the MTU to fail")
+	}
+	if err := e.AddRecord(7, 300, net.IP{1, 1, 1, 1}, net.IP{2, 2, 2, 2}, uint32(5), time.Now(), string(make([]byte, 64))); err == nil {
+		t.Fatal("expected record exceeding the MTU to fail")
+	}
+
+	// The rejected record doesn't hold up the records after it
+	e.MTU = 128
+	if err := e.AddRecord(7, 300, net.IP{1, 1, 1, 1}, net.IP{2, 2, 2, 2}, uint32(5), time.Now(), "eth0"); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := e.Flush(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewDecoder(nil, session.New()).Decode(messages[len(messages)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.DataSets) != 1 || len(m.DataSets[0].Records) != 1 {
+		t.Fatalf("expected the valid record to be exported, got %+v", m.DataSets)
+	}
+}
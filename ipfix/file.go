@@ -0,0 +1,257 @@
+package ipfix
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/translate"
+)
+
+// Information Elements used by the IPFIX File Format (RFC 5655 section 8)
+const (
+	ieExporterIPv4Address     uint16 = 130
+	ieExporterIPv6Address     uint16 = 131
+	ieCollectorIPv4Address    uint16 = 211
+	ieCollectorIPv6Address    uint16 = 212
+	ieExportProtocolVersion   uint16 = 214
+	ieExportTransportProtocol uint16 = 215
+	ieCollectorTransportPort  uint16 = 216
+	ieExporterTransportPort   uint16 = 217
+	ieMaxExportSeconds        uint16 = 260
+	ieMessageMD5Checksum      uint16 = 262
+	ieMessageScope            uint16 = 263
+	ieMinExportSeconds        uint16 = 264
+	ieSessionScope            uint16 = 267
+)
+
+func errChecksum(sequence uint32) error {
+	return fmt.Errorf("message with sequence number %d has an invalid MD5 checksum", sequence)
+}
+
+// ExportSessionDetails describes the Transport Session an IPFIX File was
+// collected from, as recorded in the Export Session Details Options
+// (RFC 5655 section 8.1.3).
+type ExportSessionDetails struct {
+	ExporterAddress   net.IP
+	ExporterPort      uint16
+	CollectorAddress  net.IP
+	CollectorPort     uint16
+	TransportProtocol uint8
+	ProtocolVersion   uint8
+	MinExportTime     time.Time
+	MaxExportTime     time.Time
+}
+
+func (d ExportSessionDetails) String() string {
+	return fmt.Sprintf("exporter %s port %d, collector %s port %d, protocol %d, version %d, export time %s - %s",
+		d.ExporterAddress, d.ExporterPort, d.CollectorAddress, d.CollectorPort,
+		d.TransportProtocol, d.ProtocolVersion, d.MinExportTime, d.MaxExportTime)
+}
+
+// FileReader reads IPFIX Messages from an IPFIX File (RFC 5655). The file is a
+// serialized stream of messages, templates are kept in the session of the
+// reader for all subsequent messages.
+type FileReader struct {
+	r io.Reader
+	session.Session
+	*Translate
+
+	// VerifyChecksum enables verification of the Message Checksum Options
+	// (RFC 5655 section 8.1.2), if present in a message.
+	VerifyChecksum bool
+
+	// ExportSessions contains the Export Session Details Options seen so far.
+	ExportSessions []ExportSessionDetails
+}
+
+// NewFileReader returns a new IPFIX File reader.
+func NewFileReader(r io.Reader) *FileReader {
+	s := session.New()
+	return &FileReader{r: r, Session: s, Translate: NewTranslate(s)}
+}
+
+// Next reads the next message from the file, io.EOF is returned when there are
+// no more messages in the file.
+func (f *FileReader) Next() (*Message, error) {
+	data, err := ReadMessage(f.r)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := Read(bytes.NewBuffer(data), f.Session, f.Translate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Number of Data Records seen per template, used to locate the record in
+	// the message
+	records := make(map[uint16]int)
+	for _, ds := range m.OptionsDataSets {
+		template, ok := ds.Template.(*OptionsTemplateRecord)
+		if !ok {
+			continue
+		}
+		for _, dr := range ds.Records {
+			n := records[template.TemplateID]
+			records[template.TemplateID]++
+			switch {
+			case hasScope(template, ieMessageScope):
+				if f.VerifyChecksum && !verifyChecksum(data, template, n, dr) {
+					return m, errChecksum(m.Header.SequenceNumber)
+				}
+			case hasScope(template, ieSessionScope):
+				f.ExportSessions = append(f.ExportSessions, exportSessionDetails(template, dr))
+			}
+		}
+	}
+
+	return m, nil
+}
+
+func hasScope(template *OptionsTemplateRecord, id uint16) bool {
+	for _, fs := range template.ScopeFields {
+		if !fs.EnterpriseBitSet && fs.InformationElementID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyChecksum computes the MD5 checksum over the message, with the checksum
+// itself set to zero. The Data Record is the n-th record of the template in the
+// message.
+func verifyChecksum(data []byte, template *OptionsTemplateRecord, n int, dr DataRecord) bool {
+	for i, fs := range template.Fields {
+		if fs.EnterpriseBitSet || fs.InformationElementID != ieMessageMD5Checksum || i >= len(dr.Fields) {
+			continue
+		}
+		checksum := dr.Fields[i].Bytes
+		if len(checksum) != md5.Size {
+			return false
+		}
+		offset := fieldOffset(data, template, n, i)
+		if offset < 0 || !bytes.Equal(data[offset:offset+md5.Size], checksum) {
+			return false
+		}
+		zeroed := make([]byte, len(data))
+		copy(zeroed, data)
+		copy(zeroed[offset:offset+md5.Size], make([]byte, md5.Size))
+		sum := md5.Sum(zeroed)
+		return bytes.Equal(sum[:], checksum)
+	}
+	return true
+}
+
+// fieldOffset returns the offset in the message of the value of field i in the
+// n-th Data Record described by the template, or -1 if there is no such record.
+func fieldOffset(data []byte, template *OptionsTemplateRecord, n, i int) int {
+	for offset := 16; offset+4 <= len(data); {
+		id := binary.BigEndian.Uint16(data[offset:])
+		end := offset + int(binary.BigEndian.Uint16(data[offset+2:]))
+		if end < offset+4 || end > len(data) {
+			return -1
+		}
+		if id == template.TemplateID {
+			r := bytes.NewReader(data[offset+4 : end])
+			for ; r.Len() > 0; n-- {
+				at := -1
+				for _, fs := range template.ScopeFields {
+					var f Field
+					if err := f.Unmarshal(r, fs); err != nil {
+						return -1
+					}
+				}
+				for j, fs := range template.Fields {
+					var f Field
+					if err := f.Unmarshal(r, fs); err != nil {
+						return -1
+					}
+					if j == i {
+						at = end - r.Len() - len(f.Bytes)
+					}
+				}
+				if n == 0 {
+					return at
+				}
+			}
+		}
+		offset = end
+	}
+	return -1
+}
+
+func exportSessionDetails(template *OptionsTemplateRecord, dr DataRecord) ExportSessionDetails {
+	var d ExportSessionDetails
+	for i, fs := range template.Fields {
+		if fs.EnterpriseBitSet || i >= len(dr.Fields) {
+			continue
+		}
+		b := dr.Fields[i].Bytes
+		switch fs.InformationElementID {
+		case ieExporterIPv4Address, ieExporterIPv6Address:
+			d.ExporterAddress = net.IP(b)
+		case ieCollectorIPv4Address, ieCollectorIPv6Address:
+			d.CollectorAddress = net.IP(b)
+		case ieExporterTransportPort:
+			d.ExporterPort = uint16(unsigned(b))
+		case ieCollectorTransportPort:
+			d.CollectorPort = uint16(unsigned(b))
+		case ieExportTransportProtocol:
+			d.TransportProtocol = uint8(unsigned(b))
+		case ieExportProtocolVersion:
+			d.ProtocolVersion = uint8(unsigned(b))
+		case ieMinExportSeconds:
+			d.MinExportTime = time.Unix(int64(unsigned(b)), 0)
+		case ieMaxExportSeconds:
+			d.MaxExportTime = time.Unix(int64(unsigned(b)), 0)
+		}
+	}
+	return d
+}
+
+// unsigned decodes an unsigned integer, invalid lengths decode as zero.
+func unsigned(b []byte) uint64 {
+	v, _ := translate.Unsigned(b)
+	return v
+}
+
+// FileWriter writes IPFIX Messages to an IPFIX File (RFC 5655).
+type FileWriter struct {
+	w io.Writer
+}
+
+// NewFileWriter returns a new IPFIX File writer.
+func NewFileWriter(w io.Writer) *FileWriter {
+	return &FileWriter{w: w}
+}
+
+// WriteMessage serializes the message to the file.
+func (f *FileWriter) WriteMessage(m *Message) error {
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = f.w.Write(data)
+	return err
+}
+
+// Write writes a raw IPFIX message to the file, such as a message as it was
+// received from the network. The message must be complete.
+func (f *FileWriter) Write(data []byte) (int, error) {
+	if len(data) < 16 {
+		return 0, io.ErrShortBuffer
+	}
+	if version := binary.BigEndian.Uint16(data[0:]); version != Version {
+		return 0, errInvalidVersion(version)
+	}
+	if length := int(binary.BigEndian.Uint16(data[2:])); length != len(data) {
+		return 0, errProtocol(fmt.Sprintf("message length %d does not match %d bytes", length, len(data)))
+	}
+	return f.w.Write(data)
+}
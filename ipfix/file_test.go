@@ -0,0 +1,150 @@
+package ipfix
+
+import (
+	"bytes"
+	"crypto/md5"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// testFileMessages returns a message with data records and a message with the
+// Message Checksum Options and the Export Session Details Options, with a
+// valid checksum.
+func testFileMessages(t *testing.T) [][]byte {
+	now := time.Unix(1600000000, 0)
+	e := testExporter(t, 0)
+	if err := e.AddRecord(7, 300, net.IP{10, 0, 0, 1}, net.IP{10, 1, 1, 1}, uint64(1000), now, "eth0"); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := e.Flush(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.AddTemplate(7, &OptionsTemplateRecord{TemplateID: 400,
+		ScopeFields: FieldSpecifiers{{InformationElementID: ieMessageScope, Length: 1}},
+		Fields:      FieldSpecifiers{{InformationElementID: ieMessageMD5Checksum, Length: md5.Size}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddTemplate(7, &OptionsTemplateRecord{TemplateID: 401,
+		ScopeFields: FieldSpecifiers{{InformationElementID: ieSessionScope, Length: 1}},
+		Fields: FieldSpecifiers{
+			{InformationElementID: ieExporterIPv4Address, Length: 4},
+			{InformationElementID: ieExporterTransportPort, Length: 2},
+			{InformationElementID: ieCollectorIPv4Address, Length: 4},
+			{InformationElementID: ieCollectorTransportPort, Length: 2},
+			{InformationElementID: ieExportTransportProtocol, Length: 1},
+			{InformationElementID: ieExportProtocolVersion, Length: 1},
+			{InformationElementID: ieMinExportSeconds, Length: 4},
+			{InformationElementID: ieMaxExportSeconds, Length: 4},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	marker := bytes.Repeat([]byte{0xaa}, md5.Size)
+	if err := e.AddRecord(7, 400, 1, marker); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddRecord(7, 401, 1, net.IP{192, 0, 2, 1}, uint16(4739), net.IP{192, 0, 2, 2}, uint16(4739),
+		uint8(17), uint8(10), now, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddRecord(7, 300, net.IP{10, 0, 0, 2}, net.IP{10, 1, 1, 1}, uint64(2000), now, "eth1"); err != nil {
+		t.Fatal(err)
+	}
+	checksummed, err := e.Flush(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The checksum is computed over the message with the checksum zeroed
+	data := checksummed[0]
+	offset := bytes.Index(data, marker)
+	copy(data[offset:], make([]byte, md5.Size))
+	sum := md5.Sum(data)
+	copy(data[offset:], sum[:])
+	return append(messages, data)
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+	w := NewFileWriter(&buffer)
+	for _, data := range testFileMessages(t) {
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewFileReader(&buffer)
+	r.VerifyChecksum = true
+	var records int
+	for {
+		m, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		for _, ds := range m.DataSets {
+			records += len(ds.Records)
+		}
+	}
+	if records != 2 {
+		t.Fatalf("expected 2 records, got %d", records)
+	}
+
+	if len(r.ExportSessions) != 1 {
+		t.Fatalf("expected 1 export session, got %+v", r.ExportSessions)
+	}
+	d := r.ExportSessions[0]
+	if !d.ExporterAddress.Equal(net.IP{192, 0, 2, 1}) || d.ExporterPort != 4739 ||
+		!d.CollectorAddress.Equal(net.IP{192, 0, 2, 2}) || d.CollectorPort != 4739 ||
+		d.TransportProtocol != 17 || d.ProtocolVersion != 10 ||
+		d.MinExportTime.Unix() != 1600000000 || d.MaxExportTime.Unix() != 1600000060 {
+		t.Fatalf("unexpected export session %s", d)
+	}
+}
+
+func TestFileWriteMessage(t *testing.T) {
+	messages := testFileMessages(t)
+	m, err := NewFileReader(bytes.NewReader(messages[0])).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	if err := NewFileWriter(&buffer).WriteMessage(m); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffer.Bytes(), messages[0]) {
+		t.Fatalf("expected the message to be written as read\n%x\n%x", buffer.Bytes(), messages[0])
+	}
+
+	// Incomplete messages are refused
+	if _, err := NewFileWriter(&buffer).Write(messages[0][:20]); err == nil {
+		t.Fatal("expected incomplete message to fail")
+	}
+}
+
+func TestFileChecksum(t *testing.T) {
+	data := testFileMessages(t)[1]
+
+	// Without verification the checksum is not checked
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	if _, err := NewFileReader(bytes.NewReader(corrupt)).Next(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewFileReader(bytes.NewReader(append(append([]byte(nil), data...), corrupt...)))
+	r.VerifyChecksum = true
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err == nil || err.Error() != errChecksum(1).Error() {
+		t.Fatalf("expected corrupted message to be rejected, got %v", err)
+	}
+}
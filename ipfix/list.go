@@ -0,0 +1,216 @@
+package ipfix
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/tehmaze/netflow/read"
+	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/translate"
+)
+
+// Structured data semantics (RFC 6313 section 4.4)
+const (
+	SemanticNoneOf       uint8 = 0x00
+	SemanticExactlyOneOf uint8 = 0x01
+	SemanticOneOrMoreOf  uint8 = 0x02
+	SemanticAllOf        uint8 = 0x03
+	SemanticOrdered      uint8 = 0x04
+	SemanticUndefined    uint8 = 0xff
+)
+
+var semanticNames = map[uint8]string{
+	SemanticNoneOf:       "noneOf",
+	SemanticExactlyOneOf: "exactlyOneOf",
+	SemanticOneOrMoreOf:  "oneOrMoreOf",
+	SemanticAllOf:        "allOf",
+	SemanticOrdered:      "ordered",
+	SemanticUndefined:    "undefined",
+}
+
+// SemanticName returns the name of a structured data semantic.
+func SemanticName(semantic uint8) string {
+	if name, ok := semanticNames[semantic]; ok {
+		return name
+	}
+	return fmt.Sprintf("semantic(%d)", semantic)
+}
+
+// BasicList represents a list of zero or more instances of a single
+// Information Element (RFC 6313 section 4.5.1).
+type BasicList struct {
+	Semantic uint8
+	Field    FieldSpecifier
+	Elements Fields
+}
+
+func (bl BasicList) String() string {
+	values := make([]string, len(bl.Elements))
+	for i, f := range bl.Elements {
+		values[i] = f.String()
+	}
+	return fmt.Sprintf("%s%v", SemanticName(bl.Semantic), values)
+}
+
+// SubTemplateList represents a list of zero or more Data Records that all use
+// the same Template (RFC 6313 section 4.5.2). If the Template is unknown, the
+// Records are nil and the encoded records are kept in Bytes.
+type SubTemplateList struct {
+	Semantic   uint8
+	TemplateID uint16
+	Records    []DataRecord
+	Bytes      []byte
+}
+
+func (stl SubTemplateList) String() string {
+	return fmt.Sprintf("%s(template=%d, %d records)", SemanticName(stl.Semantic), stl.TemplateID, len(stl.Records))
+}
+
+// SubTemplateMultiList represents a list of zero or more groups of Data
+// Records, where each group uses its own Template (RFC 6313 section 4.5.3).
+type SubTemplateMultiList struct {
+	Semantic uint8
+	Entries  []SubTemplateList
+}
+
+func (stml SubTemplateMultiList) String() string {
+	return fmt.Sprintf("%s(%d entries)", SemanticName(stml.Semantic), len(stml.Entries))
+}
+
+// unmarshalList decodes the structured data in the field according to the
+// field type, nested Data Records are decoded using the templates in the
+// session.
+func (t *Translate) unmarshalList(f *Field, ft translate.FieldType) (interface{}, error) {
+	buffer := bytes.NewBuffer(f.Bytes)
+	switch ft {
+	case translate.BasicList:
+		bl := new(BasicList)
+		return bl, bl.Unmarshal(buffer, t)
+	case translate.SubTemplateList:
+		stl := new(SubTemplateList)
+		return stl, stl.Unmarshal(buffer, t)
+	case translate.SubTemplateMultiList:
+		stml := new(SubTemplateMultiList)
+		return stml, stml.Unmarshal(buffer, t)
+	}
+	return f.Bytes, nil
+}
+
+func (bl *BasicList) Unmarshal(r io.Reader, t *Translate) error {
+	if err := read.Uint8(&bl.Semantic, r); err != nil {
+		return err
+	}
+	if err := bl.Field.Unmarshal(r); err != nil {
+		return err
+	}
+	if bl.Field.Length == 0 {
+		return errProtocol("basic list with zero length elements")
+	}
+
+	bl.Elements = make(Fields, 0)
+	buffer := new(bytes.Buffer)
+	buffer.ReadFrom(r)
+	for buffer.Len() > 0 {
+		// Lists are not padded, a partial element means the list is truncated
+		if bl.Field.Length != VariableLength && buffer.Len() < int(bl.Field.Length) {
+			return io.ErrUnexpectedEOF
+		}
+		f := Field{}
+		if err := f.Unmarshal(buffer, bl.Field); err != nil {
+			return err
+		}
+		if t != nil {
+			t.translate_field(&f, bl.Field)
+		}
+		bl.Elements = append(bl.Elements, f)
+	}
+
+	return nil
+}
+
+func (stl *SubTemplateList) Unmarshal(r io.Reader, t *Translate) error {
+	if err := read.Uint8(&stl.Semantic, r); err != nil {
+		return err
+	}
+	if err := read.Uint16(&stl.TemplateID, r); err != nil {
+		return err
+	}
+
+	buffer := new(bytes.Buffer)
+	buffer.ReadFrom(r)
+	return stl.unmarshalRecords(buffer, t)
+}
+
+// unmarshalRecords decodes the Data Records in the buffer, using the template
+// identified by TemplateID.
+func (stl *SubTemplateList) unmarshalRecords(buffer *bytes.Buffer, t *Translate) error {
+	var (
+		tm session.Template
+		ok bool
+	)
+	if t != nil && t.Session != nil {
+		tm, ok = t.Session.GetTemplate(t.domain, stl.TemplateID)
+	}
+	if !ok {
+		if debug {
+			debugLog.Printf("no template for id=%d, storing %d raw bytes in list\n", stl.TemplateID, buffer.Len())
+		}
+		stl.Bytes = buffer.Bytes()
+		return nil
+	}
+
+	stl.Records = make([]DataRecord, 0)
+	for buffer.Len() > 0 {
+		if tm.Size() == 0 {
+			return errProtocol(fmt.Sprintf("template id=%d has no fields", stl.TemplateID))
+		}
+		dr := DataRecord{TemplateID: stl.TemplateID}
+		if err := dr.Unmarshal(buffer, tm, t); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		stl.Records = append(stl.Records, dr)
+	}
+
+	return nil
+}
+
+func (stml *SubTemplateMultiList) Unmarshal(r io.Reader, t *Translate) error {
+	if err := read.Uint8(&stml.Semantic, r); err != nil {
+		return err
+	}
+
+	stml.Entries = make([]SubTemplateList, 0)
+	buffer := new(bytes.Buffer)
+	buffer.ReadFrom(r)
+	for buffer.Len() > 0 {
+		// Each entry has a header consisting of the Template ID and the
+		// length of the entry, including the header.
+		header := SetHeader{}
+		if err := header.Unmarshal(buffer); err != nil {
+			return err
+		}
+		if int(header.Length) < header.Len() || int(header.Length)-header.Len() > buffer.Len() {
+			return io.ErrUnexpectedEOF
+		}
+
+		entry := SubTemplateList{Semantic: stml.Semantic, TemplateID: header.ID}
+		data := bytes.NewBuffer(buffer.Next(int(header.Length) - header.Len()))
+		if err := entry.unmarshalRecords(data, t); err != nil {
+			return err
+		}
+		stml.Entries = append(stml.Entries, entry)
+	}
+
+	return nil
+}
+
+func (f Field) String() string {
+	if f.Translated != nil && f.Translated.Value != nil {
+		return fmt.Sprintf("%v", f.Translated.Value)
+	}
+	return fmt.Sprintf("%v", f.Bytes)
+}
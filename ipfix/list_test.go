@@ -0,0 +1,182 @@
+package ipfix
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+// listMessage has a record with a basicList, a subTemplateList and a
+// subTemplateMultiList (RFC 6313), the multi list refers to a known and an
+// unknown template.
+var listMessage = []byte{
+	// Message header, length 97, domain 1
+	0x00, 0x0a, 0x00, 0x61, 0x5f, 0x5e, 0x10, 0x00,
+	0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+	// Template set
+	0x00, 0x02, 0x00, 0x20,
+	// Template 256 with 2 fields
+	0x01, 0x00, 0x00, 0x02,
+	0x00, 0x08, 0x00, 0x04, // sourceIPv4Address
+	0x00, 0x0b, 0x00, 0x02, // destinationTransportPort
+	// Template 257 with 3 fields
+	0x01, 0x01, 0x00, 0x03,
+	0x01, 0x23, 0xff, 0xff, // basicList
+	0x01, 0x24, 0xff, 0xff, // subTemplateList
+	0x01, 0x25, 0xff, 0xff, // subTemplateMultiList
+	// Data set for template 257
+	0x01, 0x01, 0x00, 0x31,
+	// basicList, allOf destinationTransportPort 80 and 443
+	0x09, 0x03, 0x00, 0x0b, 0x00, 0x02, 0x00, 0x50, 0x01, 0xbb,
+	// subTemplateList, ordered records of template 256
+	0x0f, 0x04, 0x01, 0x00,
+	0x0a, 0x00, 0x00, 0x01, 0x00, 0x50,
+	0x0a, 0x00, 0x00, 0x02, 0x01, 0xbb,
+	// subTemplateMultiList, exactlyOneOf
+	0x12, 0x01,
+	0x01, 0x00, 0x00, 0x0a, 0x0a, 0x00, 0x00, 0x03, 0x00, 0x35, // template 256
+	0x03, 0xe7, 0x00, 0x07, 0xde, 0xad, 0xbe, // unknown template 999
+}
+
+// listMessageFields returns the fields of the record in the list message.
+func listMessageFields(t *testing.T, data []byte) Fields {
+	t.Helper()
+	m, err := NewDecoder(nil, session.New()).Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.DataSets) != 1 || len(m.DataSets[0].Records) != 1 {
+		t.Fatalf("expected one data record, got %+v", m.DataSets)
+	}
+	return m.DataSets[0].Records[0].Fields
+}
+
+func TestBasicList(t *testing.T) {
+	fields := listMessageFields(t, listMessage)
+	bl, ok := fields[0].Translated.Value.(*BasicList)
+	if !ok {
+		t.Fatalf("expected basic list, got %T", fields[0].Translated.Value)
+	}
+	if bl.Semantic != SemanticAllOf || bl.Field.InformationElementID != 11 || len(bl.Elements) != 2 {
+		t.Fatalf("unexpected basic list %+v", bl)
+	}
+	for i, port := range []uint16{80, 443} {
+		if f := bl.Elements[i].Translated; f == nil || f.Name != "destinationTransportPort" || f.Value != port {
+			t.Fatalf("element %d: expected port %d, got %+v", i, port, f)
+		}
+	}
+	if s := bl.String(); s != "allOf[80 443]" {
+		t.Fatalf("unexpected string %q", s)
+	}
+}
+
+func TestSubTemplateList(t *testing.T) {
+	fields := listMessageFields(t, listMessage)
+	stl, ok := fields[1].Translated.Value.(*SubTemplateList)
+	if !ok {
+		t.Fatalf("expected sub template list, got %T", fields[1].Translated.Value)
+	}
+	if stl.Semantic != SemanticOrdered || stl.TemplateID != 256 || len(stl.Records) != 2 {
+		t.Fatalf("unexpected sub template list %+v", stl)
+	}
+	for i, port := range []uint16{80, 443} {
+		dr := stl.Records[i]
+		if ip := dr.Fields[0].Translated.Value; !ip.(net.IP).Equal(net.IP{10, 0, 0, byte(i + 1)}) {
+			t.Fatalf("record %d: unexpected source address %v", i, ip)
+		}
+		if value := dr.Fields[1].Translated.Value; value != port {
+			t.Fatalf("record %d: expected port %d, got %v", i, port, value)
+		}
+	}
+}
+
+func TestSubTemplateMultiList(t *testing.T) {
+	fields := listMessageFields(t, listMessage)
+	stml, ok := fields[2].Translated.Value.(*SubTemplateMultiList)
+	if !ok {
+		t.Fatalf("expected sub template multi list, got %T", fields[2].Translated.Value)
+	}
+	if stml.Semantic != SemanticExactlyOneOf || len(stml.Entries) != 2 {
+		t.Fatalf("unexpected sub template multi list %+v", stml)
+	}
+	if entry := stml.Entries[0]; entry.TemplateID != 256 || len(entry.Records) != 1 || entry.Records[0].Fields[1].Translated.Value != uint16(53) {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+
+	// Records of an unknown template are kept as raw bytes
+	entry := stml.Entries[1]
+	if entry.TemplateID != 999 || entry.Records != nil || !bytes.Equal(entry.Bytes, []byte{0xde, 0xad, 0xbe}) {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+}
+
+func TestListTruncated(t *testing.T) {
+	// The length of the first multi list entry exceeds the list
+	data := append([]byte(nil), listMessage...)
+	data[len(data)-14] = 0x20
+	fields := listMessageFields(t, data)
+	if _, ok := fields[2].Translated.Value.([]byte); !ok {
+		t.Fatalf("expected truncated list to be kept as raw bytes, got %T", fields[2].Translated.Value)
+	}
+	if _, ok := fields[1].Translated.Value.(*SubTemplateList); !ok {
+		t.Fatalf("expected sub template list, got %T", fields[1].Translated.Value)
+	}
+
+	for _, test := range []struct {
+		name string
+		list interface {
+			Unmarshal(io.Reader, *Translate) error
+		}
+		data []byte
+	}{
+		{"basic list without field", new(BasicList), []byte{0x03, 0x00, 0x0b}},
+		{"basic list element", new(BasicList), []byte{0x03, 0x00, 0x0b, 0x00, 0x02, 0x00, 0x50, 0x01}},
+		{"basic list zero length", new(BasicList), []byte{0x03, 0x00, 0x0b, 0x00, 0x00}},
+		{"sub template list header", new(SubTemplateList), []byte{0x04, 0x01}},
+		{"sub template multi list header", new(SubTemplateMultiList), []byte{0x01, 0x01, 0x00, 0x00}},
+		{"sub template multi list entry", new(SubTemplateMultiList), []byte{0x01, 0x01, 0x00, 0x00, 0x08, 0x0a}},
+	} {
+		if err := test.list.Unmarshal(bytes.NewReader(test.data), nil); err == nil {
+			t.Errorf("%s: expected truncated list to fail", test.name)
+		}
+	}
+}
+
+func TestListDump(t *testing.T) {
+	m, err := NewDecoder(nil, session.New()).Decode(listMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := captureStdout(t, func() { Dump(m) })
+	want := `IPFIX message
+  data set
+    1 records:
+      record 0:
+        basicList: allOf list of 0.11:
+          destinationTransportPort: 80
+          destinationTransportPort: 443
+        subTemplateList: ordered list:
+          template 256:
+            2 records:
+              record 0:
+                sourceIPv4Address: 10.0.0.1
+                destinationTransportPort: 80
+              record 1:
+                sourceIPv4Address: 10.0.0.2
+                destinationTransportPort: 443
+        subTemplateMultiList: exactlyOneOf multi list of 2 entries:
+          template 256:
+            1 records:
+              record 0:
+                sourceIPv4Address: 10.0.0.3
+                destinationTransportPort: 53
+          template 999:
+            3 raw bytes: deadbe
+`
+	if out != want {
+		t.Fatalf("unexpected dump:\n%s\nexpected:\n%s", out, want)
+	}
+}
@@ -51,9 +51,15 @@ type Message struct {
 // UnmarshalSets will, based on the Message length, unmarshal all sets in the
 // message.
 func (m *Message) UnmarshalSets(r io.Reader, s session.Session, t *Translate) error {
+	// Templates and options are scoped by the Observation Domain
+	domain := m.Header.ObservationDomainID
+	if t != nil {
+		t.domain = domain
+	}
+
 	// Read the rest of the message, containing the sets.
 	data := make([]byte, int(m.Header.Length)-m.Header.Len())
-	if _, err := r.Read(data); err != nil {
+	if _, err := io.ReadFull(r, data); err != nil {
 		return err
 	}
 
@@ -97,8 +103,9 @@ func (m *Message) UnmarshalSets(r io.Reader, s session.Session, t *Translate) er
 			}
 			m.TemplateSets = append(m.TemplateSets, ts)
 
-			for _, tr := range ts.Records {
-				tr.register(s)
+			for i := range ts.Records {
+				ts.Records[i].register(s, domain)
+				decodePending(s, t, domain, ts.Records[i].TemplateID)
 			}
 
 		case header.ID == 3: // Options Template set
@@ -109,8 +116,9 @@ func (m *Message) UnmarshalSets(r io.Reader, s session.Session, t *Translate) er
 			}
 			m.OptionsTemplateSets = append(m.OptionsTemplateSets, ots)
 
-			for _, record := range ots.Records {
-				record.register(s)
+			for i := range ots.Records {
+				ots.Records[i].register(s, domain)
+				decodePending(s, t, domain, ots.Records[i].TemplateID)
 			}
 
 		case header.ID >= 4 && header.ID <= 255:
@@ -134,64 +142,161 @@ func (m *Message) UnmarshalSets(r io.Reader, s session.Session, t *Translate) er
 					debugLog.Printf("no session, storing %d raw bytes in data set\n", len(data))
 				}
 				ds.Bytes = data
+				m.DataSets = append(m.DataSets, ds)
 				continue
 			}
-			tm, ok = s.GetTemplate(header.ID)
+			tm, ok = s.GetTemplate(domain, header.ID)
 			if !ok {
+				// Decode the Data Set once the template arrives, if there is
+				// a pending handler and the session has a pending data buffer
+				if t != nil && t.PendingHandler != nil && s.AddPending(domain, header.ID, data) {
+					if debug {
+						debugLog.Printf("no template for id=%d, buffering %d bytes\n", header.ID, len(data))
+					}
+					continue
+				}
 				if debug {
 					debugLog.Printf("no template for id=%d, storing %d raw bytes in data set\n", header.ID, len(data))
 				}
 				ds.Bytes = data
+				m.DataSets = append(m.DataSets, ds)
 				continue
 			}
 
-			err := ds.Unmarshal(bytes.NewBuffer(data), tm, t)
-			if(err != nil) {
+			if err := decodeDataSet(&ds, data, tm, s, t, domain); err != nil {
 				return err
 			}
+			m.addDataSet(ds)
+		}
+	}
+	return nil
+}
 
-			switch tm.(type) {
-				case *TemplateRecord:
-					m.DataSets = append(m.DataSets, ds)
-				case *OptionsTemplateRecord:
-					if(debug) {
-						debugLog.Printf("ipfix data record with option template: %v\n", tm)
-					}
-					for _, record := range ds.Records {
-						if(debug) {
-							debugLog.Printf("ipfix option data record: %v\n", record)
-						}
-						for range record.OptionScopes {
-							for _, field := range record.Fields {
-								if(debug) {
-									debugLog.Printf(
-										"ipfix option: en %d, type %d, template id %d, scope %d:%d, value %v",
-										field.Translated.EnterpriseNumber,
-										field.Translated.InformationElementID,
-										header.ID,
-										1, 0, // TODO: Implement this for realsies
-										field.Translated.Value,
-									)
-								}
-								s.SetOption(field.Translated.EnterpriseNumber, field.Translated.InformationElementID, &session.Option{
-									TemplateID: header.ID,
-									Scope: session.OptionScope{1, 0}, // TODO:  Once again, implement this for realsies
-									Bytes: field.Bytes,
-									EnterpriseNumber: field.Translated.EnterpriseNumber,
-									Type: field.Translated.InformationElementID,
-									Value: field.Translated.Value,
-								})
-							}
-						}
-					}
-					m.OptionsDataSets = append(m.OptionsDataSets, ds)
+// decodeDataSet decodes the Data Records in the Data Set using the template,
+// the values of Options Data Records are stored in the session.
+func decodeDataSet(ds *DataSet, data []byte, tm session.Template, s session.Session, t *Translate, domain uint32) error {
+	if err := ds.Unmarshal(bytes.NewBuffer(data), tm, t); err != nil {
+		return err
+	}
+
+	template, ok := tm.(*OptionsTemplateRecord)
+	if !ok {
+		return nil
+	}
+	if(debug) {
+		debugLog.Printf("ipfix data record with option template: %v\n", tm)
+	}
+	for _, record := range ds.Records {
+		if(debug) {
+			debugLog.Printf("ipfix option data record: %v\n", record)
+		}
+		if len(record.OptionScopes) > 0 {
+			scope := optionScope(record)
+			for _, field := range record.Fields {
+				if(debug) {
+					debugLog.Printf(
+						"ipfix option: en %d, type %d, template id %d, scope %d:%d, value %v",
+						field.Translated.EnterpriseNumber,
+						field.Translated.InformationElementID,
+						ds.Header.ID,
+						scope.Type, scope.Index,
+						field.Translated.Value,
+					)
+				}
+				s.SetOption(domain, field.Translated.EnterpriseNumber, field.Translated.InformationElementID, &session.Option{
+					TemplateID: ds.Header.ID,
+					Scope: scope,
+					Bytes: field.Bytes,
+					EnterpriseNumber: field.Translated.EnterpriseNumber,
+					Type: field.Translated.InformationElementID,
+					Value: field.Translated.Value,
+				})
 			}
 		}
 	}
-
+	learnInformationElements(s, template, ds.Records)
 	return nil
 }
 
+// addDataSet adds the decoded Data Set to the message.
+func (m *Message) addDataSet(ds DataSet) {
+	if _, ok := ds.Template.(*OptionsTemplateRecord); ok {
+		m.OptionsDataSets = append(m.OptionsDataSets, ds)
+	} else {
+		m.DataSets = append(m.DataSets, ds)
+	}
+}
+
+// decodePending decodes the Data Sets that arrived before the template with the
+// given id, and passes them to the PendingHandler of the translator.
+func decodePending(s session.Session, t *Translate, domain uint32, id uint16) {
+	if s == nil || t == nil || t.PendingHandler == nil {
+		return
+	}
+	pending := s.TakePending(domain, id)
+	if len(pending) == 0 {
+		return
+	}
+	tm, ok := s.GetTemplate(domain, id)
+	if !ok {
+		return
+	}
+	for _, set := range pending {
+		header := SetHeader{ID: id, Length: uint16(len(set.Data) + 4)}
+		ds := DataSet{Header: header}
+		if err := decodeDataSet(&ds, set.Data, tm, s, t, domain); err != nil {
+			if debug {
+				debugLog.Printf("error decoding pending data set for id=%d: %v\n", id, err)
+			}
+			t.PendingHandler(domain, DataSet{Header: header, Bytes: set.Data}, err)
+			continue
+		}
+		t.PendingHandler(domain, ds, nil)
+	}
+}
+
+// optionScope maps the scope of an options record to a session scope. Records
+// scoped by an interface are stored per interface, all other records are
+// stored in the system scope.
+func optionScope(record DataRecord) session.OptionScope {
+	scope := record.OptionScopes[0]
+	if scope.Translated != nil && scope.Translated.EnterpriseNumber == 0 {
+		switch scope.Translated.InformationElementID {
+		case ieIngressInterface, ieEgressInterface:
+			return session.OptionScope{Type: session.SCOPE_INTERFACE, Index: uint32(unsigned(scope.Bytes))}
+		}
+	}
+	return session.OptionScope{Type: session.SCOPE_SYSTEM, Index: 0}
+}
+
+// MarshalBinary encodes the Message, including all of its sets, in its wire
+// format. The Message Header length is updated to reflect the encoded length.
+// The Template Sets and Options Template Sets are encoded before the Data Sets.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	data := make([]byte, m.Header.Len())
+	for _, ts := range m.TemplateSets {
+		data = append(data, ts.Bytes()...)
+	}
+	for _, ots := range m.OptionsTemplateSets {
+		data = append(data, ots.Bytes()...)
+	}
+	for _, sets := range [][]DataSet{m.OptionsDataSets, m.DataSets} {
+		for _, ds := range sets {
+			if ds.Template == nil && ds.Records != nil {
+				return nil, errTemplateNotFound(ds.Header.ID)
+			}
+			data = append(data, ds.Marshal()...)
+		}
+	}
+	if len(data) > 0xffff {
+		return nil, errProtocol(fmt.Sprintf("message length %d exceeds maximum", len(data)))
+	}
+
+	m.Header.Length = uint16(len(data))
+	copy(data, m.Header.Bytes())
+	return data, nil
+}
+
 // MessageHeader is a Message Header (RFC 7011 section 3.1)
 //
 // The format of the Message Header on the wire is:
@@ -215,6 +320,16 @@ type MessageHeader struct {
 	ObservationDomainID uint32
 }
 
+func (h *MessageHeader) Bytes() []byte {
+	data := make([]byte, h.Len())
+	binary.BigEndian.PutUint16(data[0:], h.Version)
+	binary.BigEndian.PutUint16(data[2:], h.Length)
+	binary.BigEndian.PutUint32(data[4:], h.ExportTime)
+	binary.BigEndian.PutUint32(data[8:], h.SequenceNumber)
+	binary.BigEndian.PutUint32(data[12:], h.ObservationDomainID)
+	return data
+}
+
 // Len returns the length of the Message Header in bytes.
 func (h *MessageHeader) Len() int {
 	return 16
@@ -267,9 +382,22 @@ func (fs FieldSpecifier) IsEnterprise() bool {
 	return fs.EnterpriseBitSet
 }
 
-// IsVariableLength ...
+// IsVariableLength checks if the Field Specifier describes a variable length
+// field (RFC 7011 section 7).
 func (fs FieldSpecifier) IsVariableLength() bool {
-	return fs.InformationElementID == VariableLength
+	return fs.Length == VariableLength
+}
+
+func (fs FieldSpecifier) Bytes() []byte {
+	data := make([]byte, fs.Len())
+	if fs.EnterpriseBitSet {
+		binary.BigEndian.PutUint16(data[0:], fs.InformationElementID|EnterpriseBit)
+		binary.BigEndian.PutUint32(data[4:], fs.EnterpriseNumber)
+	} else {
+		binary.BigEndian.PutUint16(data[0:], fs.InformationElementID)
+	}
+	binary.BigEndian.PutUint16(data[2:], fs.Length)
+	return data
 }
 
 func (fs FieldSpecifier) Len() int {
@@ -318,6 +446,14 @@ func (fs *FieldSpecifier) Unmarshal(r io.Reader) error {
 
 type FieldSpecifiers []FieldSpecifier
 
+func (fs FieldSpecifiers) Bytes() []byte {
+	data := make([]byte, 0, fs.Len())
+	for _, f := range fs {
+		data = append(data, f.Bytes()...)
+	}
+	return data
+}
+
 func (fs FieldSpecifiers) Len() int {
 	var l = 0
 	for _, f := range fs {
@@ -401,19 +537,28 @@ type TemplateSet struct {
 	Records []TemplateRecord
 }
 
+// Bytes encodes the Template Set, the Set Header length is derived from the
+// Template Records.
 func (ts TemplateSet) Bytes() []byte {
-	data := make([]byte, 0)
-	data = append(data, ts.Header.Bytes()...)
-	//data = append(data, ts.Records.Bytes()...)
+	header := SetHeader{ID: 2, Length: uint16(ts.Len())}
+	data := make([]byte, 0, ts.Len())
+	data = append(data, header.Bytes()...)
+	for _, tr := range ts.Records {
+		data = append(data, tr.Bytes()...)
+	}
 	return data
 }
 
 func (ts TemplateSet) Len() int {
-	return ts.Header.Len() // + ts.Records.Len()
+	l := ts.Header.Len()
+	for _, tr := range ts.Records {
+		l += tr.Len()
+	}
+	return l
 }
 
 func (ts TemplateSet) String() string {
-	return fmt.Sprintf("%s (%s)", ts.Header, "") // ts.TemplateRecord)
+	return fmt.Sprintf("%s (%d records): %v", ts.Header, len(ts.Records), ts.Records)
 }
 
 func (ts *TemplateSet) UnmarshalRecords(r io.Reader) error {
@@ -422,14 +567,18 @@ func (ts *TemplateSet) UnmarshalRecords(r io.Reader) error {
 		return err
 	}
 
-	// As long as there are more than 4 bytes in the buffer, we parse the next
-	// TemplateRecord, otherwise it's padding.
+	// As long as there are at least 4 bytes in the buffer, we parse the next
+	// TemplateRecord, otherwise it's padding. Withdrawals are only 4 bytes, so
+	// a zero Template ID is also treated as padding.
 	ts.Records = make([]TemplateRecord, 0)
-	for buffer.Len() > 4 {
+	for buffer.Len() >= 4 {
 		record := TemplateRecord{}
 		if err := record.Unmarshal(buffer); err != nil {
 			return err
 		}
+		if record.TemplateID == 0 {
+			break
+		}
 
 		ts.Records = append(ts.Records, record)
 	}
@@ -463,21 +612,47 @@ type TemplateRecord struct {
 	Fields     FieldSpecifiers
 }
 
-func (tr *TemplateRecord) register(s session.Session) {
+func (tr *TemplateRecord) register(s session.Session, domain uint32) {
 	if s == nil {
 		return
 	}
+	if tr.IsWithdrawal() {
+		if(debug) {
+			debugLog.Println("withdraw template:", tr)
+		}
+		if tr.TemplateID == 2 {
+			s.RemoveTemplates(domain, session.TEMPLATE_KIND_DATA)
+		} else {
+			removeTemplate(s, domain, tr.TemplateID, session.TEMPLATE_KIND_DATA)
+		}
+		return
+	}
 	if(debug) {
 		debugLog.Println("register template:", tr)
 	}
-	s.AddTemplate(tr)
+	s.AddTemplate(domain, tr)
+}
+
+// removeTemplate withdraws a single template, a withdrawal in a Template Set
+// doesn't withdraw an Options Template with the same ID and vice versa.
+func removeTemplate(s session.Session, domain uint32, id uint16, kind uint8) {
+	if tm, found := s.GetTemplate(domain, id); found && session.TemplateKind(tm) == kind {
+		s.RemoveTemplate(domain, id)
+	}
+}
+
+// IsWithdrawal checks if the Template Record is a Template Withdrawal, which
+// has no fields (RFC 7011 section 8.1). A withdrawal with Template ID 2
+// withdraws all Templates.
+func (tr TemplateRecord) IsWithdrawal() bool {
+	return tr.FieldCount == 0 && len(tr.Fields) == 0
 }
 
 func (tr TemplateRecord) Bytes() []byte {
-	data := make([]byte, 4)
+	data := make([]byte, 4, tr.Len())
 	binary.BigEndian.PutUint16(data[0:], tr.TemplateID)
-	binary.BigEndian.PutUint16(data[2:], tr.FieldCount)
-	return data
+	binary.BigEndian.PutUint16(data[2:], uint16(len(tr.Fields)))
+	return append(data, tr.Fields.Bytes()...)
 }
 
 func (tr TemplateRecord) ID() uint16 {
@@ -529,6 +704,26 @@ type OptionsTemplateSet struct {
 	Records []OptionsTemplateRecord
 }
 
+// Bytes encodes the Options Template Set, the Set Header length is derived from
+// the Options Template Records.
+func (ots OptionsTemplateSet) Bytes() []byte {
+	header := SetHeader{ID: 3, Length: uint16(ots.Len())}
+	data := make([]byte, 0, ots.Len())
+	data = append(data, header.Bytes()...)
+	for _, otr := range ots.Records {
+		data = append(data, otr.Bytes()...)
+	}
+	return data
+}
+
+func (ots OptionsTemplateSet) Len() int {
+	l := ots.Header.Len()
+	for _, otr := range ots.Records {
+		l += otr.Len()
+	}
+	return l
+}
+
 func (ots OptionsTemplateSet) String() string {
 	return fmt.Sprintf("%s (%d records): %v", ots.Header, len(ots.Records), ots.Records)
 }
@@ -539,14 +734,18 @@ func (ots *OptionsTemplateSet) UnmarshalRecords(r io.Reader) error {
 		return err
 	}
 
-	// As long as there are more than 4 bytes in the buffer, we parse the next
-	// TemplateRecord, otherwise it's padding.
+	// As long as there are at least 4 bytes in the buffer, we parse the next
+	// OptionsTemplateRecord, otherwise it's padding. Withdrawals are only 4
+	// bytes, so a zero Template ID is also treated as padding.
 	ots.Records = make([]OptionsTemplateRecord, 0)
-	for buffer.Len() > 4 {
+	for buffer.Len() >= 4 {
 		record := OptionsTemplateRecord{}
 		if err := record.Unmarshal(buffer); err != nil {
 			return err
 		}
+		if record.TemplateID == 0 {
+			break
+		}
 
 		ots.Records = append(ots.Records, record)
 	}
@@ -605,14 +804,53 @@ func (this OptionsTemplateRecord) Size() int {
 	return size
 }
 
-func (this *OptionsTemplateRecord) register(s session.Session) {
+func (this *OptionsTemplateRecord) register(s session.Session, domain uint32) {
 	if s == nil {
 		return
 	}
+	if this.IsWithdrawal() {
+		if debug {
+			debugLog.Println("withdraw options template:", this)
+		}
+		if this.TemplateID == 3 {
+			s.RemoveTemplates(domain, session.TEMPLATE_KIND_OPTIONS)
+		} else {
+			removeTemplate(s, domain, this.TemplateID, session.TEMPLATE_KIND_OPTIONS)
+		}
+		return
+	}
 	if debug {
 		debugLog.Println("register options template:", this)
 	}
-	s.AddTemplate(this)
+	s.AddTemplate(domain, this)
+}
+
+// IsWithdrawal checks if the Options Template Record is an Options Template
+// Withdrawal, which has no fields (RFC 7011 section 8.1). A withdrawal with
+// Template ID 3 withdraws all Options Templates.
+func (otr OptionsTemplateRecord) IsWithdrawal() bool {
+	return otr.FieldCount == 0 && len(otr.ScopeFields) == 0 && len(otr.Fields) == 0
+}
+
+func (otr OptionsTemplateRecord) Bytes() []byte {
+	if otr.IsWithdrawal() {
+		data := make([]byte, 4)
+		binary.BigEndian.PutUint16(data[0:], otr.TemplateID)
+		return data
+	}
+	data := make([]byte, 6, otr.Len())
+	binary.BigEndian.PutUint16(data[0:], otr.TemplateID)
+	binary.BigEndian.PutUint16(data[2:], uint16(len(otr.ScopeFields)+len(otr.Fields)))
+	binary.BigEndian.PutUint16(data[4:], uint16(len(otr.ScopeFields)))
+	data = append(data, otr.ScopeFields.Bytes()...)
+	return append(data, otr.Fields.Bytes()...)
+}
+
+func (otr OptionsTemplateRecord) Len() int {
+	if otr.IsWithdrawal() {
+		return 4
+	}
+	return 6 + otr.ScopeFields.Len() + otr.Fields.Len()
 }
 
 func (otr OptionsTemplateRecord) String() string {
@@ -627,6 +865,10 @@ func (otr *OptionsTemplateRecord) Unmarshal(r io.Reader) error {
 	if err := read.Uint16(&otr.FieldCount, r); err != nil {
 		return err
 	}
+	if otr.FieldCount == 0 {
+		// Options Template Withdrawal, there is no Scope Field Count.
+		return nil
+	}
 	if err := read.Uint16(&otr.ScopeFieldCount, r); err != nil {
 		return err
 	}
@@ -635,19 +877,15 @@ func (otr *OptionsTemplateRecord) Unmarshal(r io.Reader) error {
 		return errProtocol(fmt.Sprintf("scope field count %d higher than field count %d", otr.ScopeFieldCount, otr.FieldCount))
 	}
 
-	buffer := new(bytes.Buffer)
-	buffer.ReadFrom(r)
-	if debug {
-		hexdump(buffer.Bytes())
-	}
-
+	// Only consume the Field Specifiers of this record, the Options Template
+	// Set may contain more records.
 	otr.ScopeFields = make(FieldSpecifiers, otr.ScopeFieldCount)
-	if err := otr.ScopeFields.Unmarshal(buffer); err != nil {
+	if err := otr.ScopeFields.Unmarshal(r); err != nil {
 		return err
 	}
 
 	otr.Fields = make(FieldSpecifiers, otr.FieldCount-otr.ScopeFieldCount)
-	if err := otr.Fields.Unmarshal(buffer); err != nil {
+	if err := otr.Fields.Unmarshal(r); err != nil {
 		return err
 	}
 
@@ -655,9 +893,29 @@ func (otr *OptionsTemplateRecord) Unmarshal(r io.Reader) error {
 }
 
 type DataSet struct {
-	Header  SetHeader
-	Bytes   []byte
-	Records []DataRecord
+	Header   SetHeader
+	Bytes    []byte
+	Records  []DataRecord
+	Template session.Template
+}
+
+// Marshal encodes the Data Set using its Template. If the Data Set has no
+// decoded records, the raw bytes are used in stead.
+func (ds DataSet) Marshal() []byte {
+	var body []byte
+	if ds.Records == nil {
+		body = ds.Bytes
+	} else {
+		for _, dr := range ds.Records {
+			body = append(body, dr.Marshal(ds.Template)...)
+		}
+	}
+
+	header := SetHeader{ID: ds.Header.ID, Length: uint16(ds.Header.Len() + len(body))}
+	if ds.Template != nil {
+		header.ID = ds.Template.ID()
+	}
+	return append(header.Bytes(), body...)
 }
 
 func (ds *DataSet) Unmarshal(r io.Reader, template session.Template, t *Translate) error {
@@ -666,6 +924,7 @@ func (ds *DataSet) Unmarshal(r io.Reader, template session.Template, t *Translat
 	buffer := new(bytes.Buffer)
 	buffer.ReadFrom(r)
 
+	ds.Template = template
 	ds.Records = make([]DataRecord, 0)
 	var err error
 	for buffer.Len() > 0 {
@@ -690,6 +949,10 @@ type DataRecord struct {
 	TemplateID   uint16
 	OptionScopes Fields
 	Fields       Fields
+
+	// InnerFlow contains the tenant flow for records exported by VMware,
+	// see NewInnerFlow
+	InnerFlow *InnerFlow
 }
 
 func (dr *DataRecord) Unmarshal(r io.Reader, template session.Template, t *Translate) error {
@@ -725,6 +988,25 @@ func (dr *DataRecord) Unmarshal(r io.Reader, template session.Template, t *Trans
 	return nil
 }
 
+// Marshal encodes the Data Record using the Template it was described by.
+func (dr DataRecord) Marshal(template session.Template) []byte {
+	var data []byte
+	if option_template, is_option := template.(*OptionsTemplateRecord); is_option {
+		for i, f := range dr.OptionScopes {
+			if i < len(option_template.ScopeFields) {
+				data = append(data, f.Marshal(option_template.ScopeFields[i])...)
+			}
+		}
+	}
+	fss := template.GetFields()
+	for i, f := range dr.Fields {
+		if i < len(fss) {
+			data = append(data, f.Marshal(fss[i])...)
+		}
+	}
+	return data
+}
+
 type Field struct {
 	Bytes      []byte
 	Translated *TranslatedField
@@ -743,6 +1025,22 @@ func (f *Field) Unmarshal(r io.Reader, fs session.TemplateFieldSpecifier) error
 
 }
 
+// Marshal encodes the Field, variable length fields are prefixed with their
+// length as per RFC 7011 section 7.
+func (f Field) Marshal(fs session.TemplateFieldSpecifier) []byte {
+	if fs.GetLength() != VariableLength {
+		return f.Bytes
+	}
+	var data []byte
+	if len(f.Bytes) < 0xff {
+		data = []byte{uint8(len(f.Bytes))}
+	} else {
+		data = []byte{0xff, 0, 0}
+		binary.BigEndian.PutUint16(data[1:], uint16(len(f.Bytes)))
+	}
+	return append(data, f.Bytes...)
+}
+
 type Fields []Field
 
 func (fs Fields) Len() int {
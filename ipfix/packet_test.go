@@ -0,0 +1,171 @@
+package ipfix
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+// testPendingMessages returns a message with only the Data Set and a message
+// with only the Template Set of the test exporter.
+func testPendingMessages(t *testing.T) (data, template []byte) {
+	e := testExporter(t, 0)
+	now := time.Unix(1600000000, 0)
+	if err := e.AddRecord(7, 300, net.IP{10, 0, 0, 1}, net.IP{10, 1, 1, 1}, uint64(1000), now, "eth0"); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := e.Flush(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewDecoder(nil, session.New()).Decode(messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err = (&Message{Header: m.Header, DataSets: m.DataSets}).MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if template, err = (&Message{Header: m.Header, TemplateSets: m.TemplateSets}).MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestPendingDataSet(t *testing.T) {
+	data, template := testPendingMessages(t)
+
+	s := session.New()
+	s.SetPendingLimits(session.DefaultPendingSize, session.DefaultPendingAge)
+	d := NewDecoder(nil, s)
+	var pending []DataSet
+	d.PendingHandler = func(domain uint32, ds DataSet, err error) {
+		if domain != 7 || err != nil {
+			t.Fatalf("unexpected pending data set for domain %d: %v", domain, err)
+		}
+		pending = append(pending, ds)
+	}
+
+	m, err := d.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.DataSets) != 0 {
+		t.Fatalf("expected data set to be buffered, got %+v", m.DataSets)
+	}
+	if stats := s.TemplateStats(); stats.Pending != 1 {
+		t.Fatalf("expected 1 pending set, got %+v", stats)
+	}
+
+	if m, err = d.Decode(template); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.DataSets) != 0 {
+		t.Fatalf("unexpected data sets in message with template %+v", m.DataSets)
+	}
+	if len(pending) != 1 || len(pending[0].Records) != 1 {
+		t.Fatalf("expected 1 pending record, got %+v", pending)
+	}
+	if ip := pending[0].Records[0].Fields[0].Translated.Value; !ip.(net.IP).Equal(net.IP{10, 0, 0, 1}) {
+		t.Fatalf("unexpected source address %v", ip)
+	}
+	if stats := s.TemplateStats(); stats.Pending != 0 {
+		t.Fatalf("expected no pending sets, got %+v", stats)
+	}
+}
+
+func TestPendingWithoutHandler(t *testing.T) {
+	data, template := testPendingMessages(t)
+
+	// Without a handler the Data Set isn't buffered, but kept as raw bytes
+	s := session.New()
+	s.SetPendingLimits(session.DefaultPendingSize, session.DefaultPendingAge)
+	d := NewDecoder(nil, s)
+	m, err := d.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.DataSets) != 1 || m.DataSets[0].Records != nil || len(m.DataSets[0].Bytes) == 0 {
+		t.Fatalf("expected raw data set, got %+v", m.DataSets)
+	}
+	if stats := s.TemplateStats(); stats.Pending != 0 {
+		t.Fatalf("expected no pending sets, got %+v", stats)
+	}
+	if m, err = d.Decode(template); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.DataSets) != 0 {
+		t.Fatalf("unexpected data sets in message with template %+v", m.DataSets)
+	}
+}
+
+// withdrawalMessage returns a message for domain 7 with a single template
+// withdrawal in the set.
+func withdrawalMessage(setID, templateID uint16) []byte {
+	return []byte{
+		// Message header, length 24, domain 7
+		0x00, 0x0a, 0x00, 0x18, 0x5f, 0x5e, 0x10, 0x00,
+		0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x07,
+		// Set header and the withdrawal, without fields
+		byte(setID >> 8), byte(setID), 0x00, 0x08,
+		byte(templateID >> 8), byte(templateID), 0x00, 0x00,
+	}
+}
+
+func TestTemplateWithdrawal(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		withdrawal []byte
+		remaining  []uint16
+	}{
+		{"template", withdrawalMessage(2, 300), []uint16{301, 302}},
+		{"options template", withdrawalMessage(3, 301), []uint16{300, 302}},
+		{"all templates", withdrawalMessage(2, 2), []uint16{301}},
+		{"all options templates", withdrawalMessage(3, 3), []uint16{300, 302}},
+		{"template in options template set", withdrawalMessage(3, 300), []uint16{300, 301, 302}},
+		{"options template in template set", withdrawalMessage(2, 301), []uint16{300, 301, 302}},
+	} {
+		e := testExporter(t, 0)
+		if err := e.AddTemplate(7, &TemplateRecord{TemplateID: 302, Fields: FieldSpecifiers{
+			{InformationElementID: 8, Length: 4}, // sourceIPv4Address
+		}}); err != nil {
+			t.Fatal(err)
+		}
+		messages, err := e.Flush(time.Unix(1600000000, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var events []uint16
+		m := session.NewManager()
+		m.OnTemplate = func(addr string, event session.TemplateEvent, domain uint32, tm session.Template) {
+			if event == session.TEMPLATE_WITHDRAWN {
+				events = append(events, tm.ID())
+			}
+		}
+		s := m.Session("192.0.2.1:4739", Version)
+		d := NewDecoder(nil, s)
+		if _, err = d.Decode(messages[0]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = d.Decode(test.withdrawal); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		var remaining []uint16
+		for _, id := range []uint16{300, 301, 302} {
+			if _, found := s.GetTemplate(7, id); found {
+				remaining = append(remaining, id)
+			}
+		}
+		if fmt.Sprint(remaining) != fmt.Sprint(test.remaining) {
+			t.Errorf("%s: expected templates %v to remain, got %v", test.name, test.remaining, remaining)
+		}
+		if stats := s.TemplateStats(); stats.Withdrawn != uint64(3-len(test.remaining)) || len(events) != 3-len(test.remaining) {
+			t.Errorf("%s: unexpected withdrawals %v, stats %+v", test.name, events, stats)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package ipfix
+
+import (
+	"github.com/tehmaze/netflow/psamp"
+)
+
+// Information Elements containing packet sections (RFC 5477 section 8.2)
+const (
+	ieProtocolIdentifier     uint16 = 4
+	ieIPHeaderPacketSection  uint16 = 313
+	ieIPPayloadPacketSection uint16 = 314
+	ieDataLinkFrameSection   uint16 = 315
+)
+
+// decodePacketSections decodes the packet sections in a translated Data Record
+// and attaches the decoded packets to the translated fields. The IP payload
+// section is decoded using the protocolIdentifier in the same record.
+func decodePacketSections(dr *DataRecord) {
+	var protocol uint8
+	for _, f := range dr.Fields {
+		if f.Translated != nil && f.Translated.EnterpriseNumber == 0 && f.Translated.InformationElementID == ieProtocolIdentifier {
+			protocol = uint8(unsigned(f.Bytes))
+		}
+	}
+
+	for i := range dr.Fields {
+		f := &dr.Fields[i]
+		if f.Translated == nil || f.Translated.EnterpriseNumber != 0 {
+			continue
+		}
+		switch f.Translated.InformationElementID {
+		case ieDataLinkFrameSection:
+			f.Translated.Packet = psamp.DecodeDataLink(f.Bytes)
+		case ieIPHeaderPacketSection:
+			f.Translated.Packet = psamp.DecodeIP(f.Bytes)
+		case ieIPPayloadPacketSection:
+			if protocol != 0 {
+				f.Translated.Packet = psamp.DecodePayload(protocol, f.Bytes)
+			}
+		}
+	}
+}
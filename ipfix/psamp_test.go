@@ -0,0 +1,92 @@
+package ipfix
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+// testPSAMPMessage returns a message with a PSAMP record, carrying the frame,
+// IP header and IP payload sections of a TCP packet.
+func testPSAMPMessage(t *testing.T, protocol uint8) []byte {
+	var (
+		frame = []byte{
+			0x00, 0x00, 0x5e, 0x00, 0x53, 0x02, 0x00, 0x00, 0x5e, 0x00, 0x53, 0x01, 0x08, 0x00,
+		}
+		header = []byte{
+			0x45, 0x00, 0x00, 0x28, 0x12, 0x34, 0x40, 0x00, 0x40, 0x06, 0x00, 0x00,
+			0xc0, 0x00, 0x02, 0x01, 0xc6, 0x33, 0x64, 0x01,
+		}
+		payload = []byte{
+			0xc0, 0x00, 0x01, 0xbb, 0x00, 0x00, 0x03, 0xe8, 0x00, 0x00, 0x00, 0x00,
+			0x50, 0x02, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
+		}
+	)
+
+	e := NewExporter(0)
+	if err := e.AddTemplate(1, &TemplateRecord{TemplateID: 300, Fields: FieldSpecifiers{
+		{InformationElementID: ieProtocolIdentifier, Length: 1},
+		{InformationElementID: ieDataLinkFrameSection, Length: VariableLength},
+		{InformationElementID: ieIPHeaderPacketSection, Length: VariableLength},
+		{InformationElementID: ieIPPayloadPacketSection, Length: VariableLength},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddRecord(1, 300, protocol, append(frame, header...), header, payload); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := e.Flush(time.Unix(1600000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages[0]
+}
+
+func TestPacketSections(t *testing.T) {
+	m, err := NewDecoder(nil, session.New()).Decode(testPSAMPMessage(t, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := m.DataSets[0].Records[0].Fields
+	if fields[0].Translated.Packet != nil {
+		t.Fatal("expected no packet for the protocol identifier")
+	}
+	if p := fields[1].Translated.Packet; p == nil || p.Ethernet == nil || p.IPv4 == nil || p.TCP != nil || !p.Truncated {
+		t.Fatalf("unexpected frame section %+v", p)
+	}
+	if p := fields[2].Translated.Packet; p == nil || p.IPv4 == nil || p.IPv4.Protocol != 6 || !p.Truncated {
+		t.Fatalf("unexpected IP header section %+v", p)
+	}
+	if p := fields[3].Translated.Packet; p == nil || p.TCP == nil || p.TCP.DestinationPort != 443 {
+		t.Fatalf("unexpected IP payload section %+v", p)
+	}
+
+	// The payload section can't be decoded without the protocol
+	m, err = NewDecoder(nil, session.New()).Decode(testPSAMPMessage(t, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := m.DataSets[0].Records[0].Fields[3].Translated.Packet; p != nil {
+		t.Fatalf("expected no packet for the payload section without protocol, got %+v", p)
+	}
+}
+
+func TestPacketSectionsDump(t *testing.T) {
+	m, err := NewDecoder(nil, session.New()).Decode(testPSAMPMessage(t, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := captureStdout(t, func() { Dump(m) })
+	for _, want := range []string{
+		"        protocolIdentifier: 6\n",
+		"        dataLinkFrameSection: 00:00:5e:00:53:01 > 00:00:5e:00:53:02, ipv4 ttl 64 len 40, 192.0.2.1 > 198.51.100.1, truncated\n",
+		"        ipHeaderPacketSection: ipv4 ttl 64 len 40, 192.0.2.1 > 198.51.100.1, truncated\n",
+		"        ipPayloadPacketSection: tcp 49152 > 443 [S] seq 1000 win 65535\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in dump:\n%s", want, out)
+		}
+	}
+}
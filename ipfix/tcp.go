@@ -0,0 +1,183 @@
+package ipfix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+// ReadMessage reads the raw bytes of a single IPFIX message from a stream. The
+// Length in the Message Header is used to frame the message, as is required
+// for stream transports such as TCP (RFC 7011 section 10.4).
+func ReadMessage(r io.Reader) ([]byte, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	version := binary.BigEndian.Uint16(header[0:])
+	if version != Version {
+		return nil, errInvalidVersion(version)
+	}
+
+	length := int(binary.BigEndian.Uint16(header[2:]))
+	if length < len(header) {
+		return nil, io.ErrShortBuffer
+	}
+
+	data := make([]byte, length)
+	copy(data, header)
+	if _, err := io.ReadFull(r, data[len(header):]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// TCPCollector collects IPFIX messages from Exporters connecting over TCP.
+//
+// Every connection is a Transport Session, templates received over TCP remain
+// valid for the lifetime of the connection and are not expected to be
+// refreshed by the Exporter. The templates are withdrawn from the session when
+// the connection closes.
+type TCPCollector struct {
+	// Handler is called for every message received, with the raw bytes of
+	// the message as received. Messages from the same connection are handled
+	// in order.
+	Handler func(remote net.Addr, data []byte, m *Message)
+
+	// ErrorHandler, if set, is called if a connection is closed because of a
+	// framing or decoding error.
+	ErrorHandler func(remote net.Addr, err error)
+
+	// Session, if set, returns the session for the connection. It's called
+	// for every message, so a session.Manager can keep track of the
+	// Exporter. By default every connection gets its own session.New.
+	Session func(remote net.Addr) session.Session
+
+	// ConnectHandler and CloseHandler, if set, are called when a connection
+	// is accepted and after it's closed, for example to keep a
+	// session.Manager from evicting the Exporter while the connection is
+	// open, see session.Manager.Acquire.
+	ConnectHandler func(remote net.Addr)
+	CloseHandler   func(remote net.Addr)
+}
+
+// ListenAndServe listens on the TCP network address addr and then calls Serve
+// to handle incoming connections.
+func (c *TCPCollector) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	return c.Serve(l)
+}
+
+// Serve accepts connections on the listener, every connection is handled in
+// its own goroutine. Serve returns net.ErrClosed once the listener is closed,
+// other accept errors, such as running out of file descriptors, are retried
+// with a back off.
+func (c *TCPCollector) Serve(l net.Listener) error {
+	var delay time.Duration
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return net.ErrClosed
+			}
+			if delay == 0 {
+				delay = 5 * time.Millisecond
+			} else if delay *= 2; delay > time.Second {
+				delay = time.Second
+			}
+			if debug {
+				debugLog.Printf("accept error: %v, retrying in %s\n", err, delay)
+			}
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+		go c.serve(conn)
+	}
+}
+
+func (c *TCPCollector) serve(conn net.Conn) {
+	defer conn.Close()
+
+	var (
+		s session.Session
+		t *Translate
+	)
+	if c.Session == nil {
+		s = session.New()
+		t = NewTranslate(s)
+	}
+	remote := conn.RemoteAddr()
+	domains := make(map[uint32]bool)
+
+	if debug {
+		debugLog.Println("new transport session from", remote)
+	}
+	if c.ConnectHandler != nil {
+		c.ConnectHandler(remote)
+	}
+
+	for {
+		data, err := ReadMessage(conn)
+		if err != nil {
+			if err != io.EOF {
+				c.error(remote, err)
+			}
+			break
+		}
+
+		if c.Session != nil {
+			if next := c.Session(remote); next != s {
+				s, t = next, NewTranslate(next)
+			}
+		}
+
+		m, err := Read(bytes.NewBuffer(data), s, t)
+		if m != nil {
+			domains[m.Header.ObservationDomainID] = true
+		}
+		if err != nil {
+			c.error(remote, err)
+			break
+		}
+
+		if c.Handler != nil {
+			c.Handler(remote, data, m)
+		}
+	}
+
+	// Templates are only valid for the lifetime of the Transport Session.
+	for domain := range domains {
+		s.RemoveTemplates(domain, session.TEMPLATE_KIND_DATA)
+		s.RemoveTemplates(domain, session.TEMPLATE_KIND_OPTIONS)
+	}
+
+	if debug {
+		debugLog.Println("closed transport session from", remote)
+	}
+	if c.CloseHandler != nil {
+		c.CloseHandler(remote)
+	}
+}
+
+func (c *TCPCollector) error(remote net.Addr, err error) {
+	if debug {
+		debugLog.Printf("transport session from %s: %v\n", remote, err)
+	}
+	if c.ErrorHandler != nil {
+		c.ErrorHandler(remote, err)
+	}
+}
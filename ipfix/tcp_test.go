@@ -0,0 +1,140 @@
+package ipfix
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+func TestTCPCollector(t *testing.T) {
+	e := testExporter(t, 1400)
+	now := time.Unix(1600000000, 0)
+	for i := 0; i < 3; i++ {
+		if err := e.AddRecord(7, 300, net.IP{10, 0, 0, byte(i)}, net.IP{10, 1, 1, 1}, uint64(i), now, "eth0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, err := e.Flush(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddRecord(7, 301, 3, "ge-0/0/0"); err != nil {
+		t.Fatal(err)
+	}
+	second, err := e.Flush(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s := session.New()
+	messages := make(chan *Message, 4)
+	closed := make(chan error, 1)
+	c := &TCPCollector{
+		Session: func(remote net.Addr) session.Session { return s },
+		Handler: func(remote net.Addr, data []byte, m *Message) { messages <- m },
+		ErrorHandler: func(remote net.Addr, err error) {
+			t.Errorf("unexpected error from %s: %v", remote, err)
+		},
+	}
+	go func() { closed <- c.Serve(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The messages are framed by their length, regardless of how they are
+	// split over the writes
+	stream := append(append([]byte(nil), first[0]...), second[0]...)
+	for _, part := range [][]byte{stream[:10], stream[10 : len(first[0])+5], stream[len(first[0])+5:]} {
+		if _, err := conn.Write(part); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	var records []int
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-messages:
+			var n int
+			for _, ds := range append(m.DataSets, m.OptionsDataSets...) {
+				n += len(ds.Records)
+			}
+			records = append(records, n)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for message")
+		}
+	}
+	if records[0] != 3 || records[1] != 1 {
+		t.Fatalf("unexpected records per message %v", records)
+	}
+	if _, found := s.GetTemplate(7, 300); !found {
+		t.Fatal("expected template 300 to be registered")
+	}
+
+	// Closing the connection withdraws the templates
+	conn.Close()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(5 * time.Millisecond) {
+		_, data := s.GetTemplate(7, 300)
+		_, options := s.GetTemplate(7, 301)
+		if !data && !options {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the templates to be withdrawn")
+		}
+	}
+
+	l.Close()
+	select {
+	case err := <-closed:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("expected net.ErrClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for Serve to return")
+	}
+}
+
+type testListener struct {
+	net.Listener
+	mu   sync.Mutex
+	errs []error
+}
+
+func (l *testListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.errs) == 0 {
+		return nil, net.ErrClosed
+	}
+	err := l.errs[0]
+	l.errs = l.errs[1:]
+	return nil, err
+}
+
+func TestTCPCollectorAcceptError(t *testing.T) {
+	l := &testListener{errs: []error{
+		&net.OpError{Op: "accept", Net: "tcp", Err: syscall.EMFILE},
+		&net.OpError{Op: "accept", Net: "tcp", Err: syscall.ECONNABORTED},
+	}}
+	c := &TCPCollector{}
+	if err := c.Serve(l); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected accept errors to be retried, got %v", err)
+	}
+	if len(l.errs) != 0 {
+		t.Fatalf("expected all errors to be consumed, %d left", len(l.errs))
+	}
+}
@@ -1,6 +1,7 @@
 package ipfix
 
 import (
+	"github.com/tehmaze/netflow/psamp"
 	"github.com/tehmaze/netflow/session"
 	"github.com/tehmaze/netflow/translate"
 )
@@ -11,26 +12,42 @@ type TranslatedField struct {
 	EnterpriseNumber     uint32
 	Value                interface{}
 	Bytes                []byte
+
+	// Packet contains the decoded headers for PSAMP packet sections
+	Packet *psamp.Packet
 }
 
 type Translate struct {
 	*translate.Translate
+
+	// PendingHandler is called with Data Sets that arrived before their
+	// template, once the template is registered. Data Sets are only buffered
+	// if there is a handler and the session has a pending data buffer, see
+	// session.Session.SetPendingLimits, otherwise they are added to their
+	// message as raw bytes. If a Data Set can't be decoded, the handler is
+	// called with the error and the raw bytes.
+	PendingHandler func(domain uint32, ds DataSet, err error)
+
+	// Observation Domain of the message being translated
+	domain uint32
 }
 
 func NewTranslate(s session.Session) *Translate {
-	return &Translate{translate.NewTranslate(s)}
+	return &Translate{Translate: translate.NewTranslate(s)}
 }
 
 func (t *Translate) Record(dr *DataRecord, tm session.Template) error {
 	if t.Session == nil {
 		return nil
 	}
-	tm, ok := t.Session.GetTemplate(dr.TemplateID)
-	if !ok {
-		if(debug) {
-			debugLog.Printf("no template for id=%d, can't translate field\n", dr.TemplateID)
+	if tm == nil {
+		var ok bool
+		if tm, ok = t.Session.GetTemplate(t.domain, dr.TemplateID); !ok {
+			if(debug) {
+				debugLog.Printf("no template for id=%d, can't translate field\n", dr.TemplateID)
+			}
+			return nil
 		}
-		return nil
 	}
 	fields := tm.GetFields()
 	if fields == nil {
@@ -46,19 +63,25 @@ func (t *Translate) Record(dr *DataRecord, tm session.Template) error {
 
 	option_template, is_option := tm.(*OptionsTemplateRecord)
 	if(is_option) {
-		dr.OptionScopes = make(Fields, len(option_template.ScopeFields))
 		for i, field := range option_template.ScopeFields {
+			if i >= len(dr.OptionScopes) {
+				break
+			}
 			t.translate_field(&dr.OptionScopes[i], field)
 		}
 	}
 
 	for i, field := range fields {
-		if i > len(dr.Fields) {
+		if i >= len(dr.Fields) {
 			break
 		}
 
 		t.translate_field(&dr.Fields[i], field.(FieldSpecifier))
 	}
+	decodePacketSections(dr)
+	if f, ok := NewInnerFlow(dr, t.Session, t.domain); ok {
+		dr.InnerFlow = f
+	}
 
 	return nil
 }
@@ -69,10 +92,22 @@ func (this *Translate) translate_field(f *Field, fs FieldSpecifier) error {
 		InformationElementID: fs.InformationElementID,
 	}
 
-	element, ok := this.Translate.Key(translate.Key{fs.EnterpriseNumber, fs.InformationElementID})
+	element, ok := this.Translate.Key(translate.Key{EnterpriseID: fs.EnterpriseNumber, FieldID: fs.InformationElementID})
 	if(ok) {
 		f.Translated.Name = element.Name
-		f.Translated.Value = translate.Bytes(f.Bytes, element.Type)
+		switch element.Type {
+		case translate.BasicList, translate.SubTemplateList, translate.SubTemplateMultiList:
+			value, err := this.unmarshalList(f, element.Type)
+			if err != nil {
+				if(debug) {
+					debugLog.Printf("error decoding %s: %v\n", element.Name, err)
+				}
+				value = f.Bytes
+			}
+			f.Translated.Value = value
+		default:
+			f.Translated.Value = translate.Bytes(f.Bytes, element.Type)
+		}
 		if(debug) {
 			debugLog.Printf("translated {%d, %d} (%v) to %s, %v\n", fs.EnterpriseNumber, fs.InformationElementID, f.Bytes, f.Translated.Name, f.Translated.Value)
 		}
@@ -0,0 +1,61 @@
+package ipfix
+
+import (
+	"strings"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+// Information Elements used by the Information Element Type Options
+// (RFC 5610 section 3)
+const (
+	ieInformationElementId        uint16 = 303
+	ieInformationElementDataType  uint16 = 339
+	ieInformationElementName      uint16 = 341
+	ieInformationElementSemantics uint16 = 344
+	ieInformationElementUnits     uint16 = 345
+	iePrivateEnterpriseNumber     uint16 = 346
+)
+
+// learnInformationElements registers the Information Elements described by
+// Information Element Type Options records in the session, so subsequent
+// records using these elements can be translated.
+func learnInformationElements(s session.Session, template *OptionsTemplateRecord, records []DataRecord) {
+	if s == nil || !hasScope(template, ieInformationElementId) {
+		return
+	}
+
+	specifiers := append(append(FieldSpecifiers{}, template.ScopeFields...), template.Fields...)
+	for _, dr := range records {
+		fields := append(append(Fields{}, dr.OptionScopes...), dr.Fields...)
+
+		ie := new(session.InformationElement)
+		for i, fs := range specifiers {
+			if fs.EnterpriseBitSet || i >= len(fields) {
+				continue
+			}
+			b := fields[i].Bytes
+			switch fs.InformationElementID {
+			case ieInformationElementId:
+				ie.Type = uint16(unsigned(b))
+			case iePrivateEnterpriseNumber:
+				ie.EnterpriseNumber = uint32(unsigned(b))
+			case ieInformationElementDataType:
+				ie.DataType = uint8(unsigned(b))
+			case ieInformationElementName:
+				// Some exporters pad the name with NUL bytes
+				ie.Name = strings.TrimRight(string(b), "\x00")
+			case ieInformationElementSemantics:
+				ie.Semantics = uint8(unsigned(b))
+			case ieInformationElementUnits:
+				ie.Units = uint16(unsigned(b))
+			}
+		}
+
+		if debug {
+			debugLog.Printf("learned information element {%d, %d}: %s (type %d)\n",
+				ie.EnterpriseNumber, ie.Type, ie.Name, ie.DataType)
+		}
+		s.AddInformationElement(ie)
+	}
+}
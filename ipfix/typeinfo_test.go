@@ -0,0 +1,88 @@
+package ipfix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+// testTypeInfoMessages returns a message with an Information Element Type
+// Options record describing enterprise element 99999/100 as an unsigned32,
+// and a message with a data record using the element.
+func testTypeInfoMessages(t *testing.T) (options, data []byte) {
+	e := NewExporter(0)
+	if err := e.AddTemplate(1, &OptionsTemplateRecord{TemplateID: 400,
+		ScopeFields: FieldSpecifiers{
+			{InformationElementID: ieInformationElementId, Length: 2},
+			{InformationElementID: iePrivateEnterpriseNumber, Length: 4},
+		},
+		Fields: FieldSpecifiers{
+			{InformationElementID: ieInformationElementDataType, Length: 1},
+			{InformationElementID: ieInformationElementName, Length: VariableLength},
+			{InformationElementID: ieInformationElementSemantics, Length: 1},
+			{InformationElementID: ieInformationElementUnits, Length: 2},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Data type 3 is unsigned32, semantics 2 is totalCounter and units 2 are
+	// packets (RFC 5610 section 3)
+	if err := e.AddRecord(1, 400, 100, 99999, uint8(3), "examplePacketCount", uint8(2), uint16(2)); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1600000000, 0)
+	messages, err := e.Flush(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options = messages[0]
+
+	if err := e.AddTemplate(1, &TemplateRecord{TemplateID: 300, Fields: FieldSpecifiers{
+		{InformationElementID: 8, Length: 4}, // sourceIPv4Address
+		{EnterpriseBitSet: true, EnterpriseNumber: 99999, InformationElementID: 100, Length: 4},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddRecord(1, 300, []byte{10, 0, 0, 1}, []byte{0x00, 0x00, 0x04, 0xd2}); err != nil {
+		t.Fatal(err)
+	}
+	if messages, err = e.Flush(now); err != nil {
+		t.Fatal(err)
+	}
+	return options, messages[0]
+}
+
+func TestLearnInformationElement(t *testing.T) {
+	options, data := testTypeInfoMessages(t)
+
+	// Without the type information the element is not known
+	m, err := NewDecoder(nil, session.New()).Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := m.DataSets[0].Records[0].Fields[1].Translated; f.Name != "" || f.Value != nil {
+		t.Fatalf("expected unknown element, got %+v", f)
+	}
+
+	s := session.New()
+	d := NewDecoder(nil, s)
+	if _, err = d.Decode(options); err != nil {
+		t.Fatal(err)
+	}
+	ie, found := s.GetInformationElement(99999, 100)
+	if !found {
+		t.Fatal("expected the element to be learned")
+	}
+	if ie.Name != "examplePacketCount" || ie.DataType != 3 || ie.Semantics != 2 || ie.Units != 2 {
+		t.Fatalf("unexpected element %+v", ie)
+	}
+
+	if m, err = d.Decode(data); err != nil {
+		t.Fatal(err)
+	}
+	f := m.DataSets[0].Records[0].Fields[1].Translated
+	if f.Name != "examplePacketCount" || f.EnterpriseNumber != 99999 || f.Value != uint32(1234) {
+		t.Fatalf("expected the element to be decoded with the learned type, got %+v", f)
+	}
+}
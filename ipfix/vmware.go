@@ -0,0 +1,174 @@
+package ipfix
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+// VMwarePEN is the Private Enterprise Number used by VMware vSphere Distributed
+// Switch and NSX exporters.
+const VMwarePEN uint32 = 6876
+
+// VMware Information Elements
+const (
+	vmwareTenantProtocol       uint16 = 880
+	vmwareTenantSourceIPv4     uint16 = 881
+	vmwareTenantDestIPv4       uint16 = 882
+	vmwareTenantSourceIPv6     uint16 = 883
+	vmwareTenantDestIPv6       uint16 = 884
+	vmwareTenantSourcePort     uint16 = 886
+	vmwareTenantDestPort       uint16 = 887
+	vmwareEgressInterfaceAttr  uint16 = 888
+	vmwareVXLANExportRole      uint16 = 889
+	vmwareIngressInterfaceAttr uint16 = 890
+	vmwareVirtualObsID         uint16 = 891
+)
+
+// IANA Information Elements used by the inner flow view
+const (
+	ieIngressInterface uint16 = 10
+	ieEgressInterface  uint16 = 14
+)
+
+// InnerFlow is a view on a Data Record exported by VMware, containing the
+// tenant flow carried inside a VXLAN or Geneve tunnel. The outer flow is
+// described by the IANA Information Elements in the same record.
+//
+// The interface attributes and virtual observation point IDs are announced in
+// options records scoped by the interface, and resolved through the session.
+// A virtual observation point ID in the record itself doesn't tell the
+// direction, it's kept in VirtualObsID.
+type InnerFlow struct {
+	Protocol           uint8
+	SourceAddress      net.IP
+	SourcePort         uint16
+	DestinationAddress net.IP
+	DestinationPort    uint16
+	VXLANExportRole    uint8
+	VirtualObsID       string
+
+	IngressInterface     uint32
+	IngressInterfaceAttr uint16
+	IngressVirtualObsID  string
+	EgressInterface      uint32
+	EgressInterfaceAttr  uint16
+	EgressVirtualObsID   string
+}
+
+// NewInnerFlow returns the inner flow in the Data Record, if the record has no
+// tenant fields false is returned. If a session is given, the interface
+// attributes and virtual observation point IDs are resolved through the
+// options table in the session for the Observation Domain of the record.
+// Translated records have their inner flow attached in DataRecord.InnerFlow.
+func NewInnerFlow(dr *DataRecord, s session.Session, domain uint32) (*InnerFlow, bool) {
+	var (
+		f     = new(InnerFlow)
+		found bool
+	)
+	for _, field := range dr.Fields {
+		if field.Translated == nil {
+			continue
+		}
+		b := field.Bytes
+		switch field.Translated.EnterpriseNumber {
+		case 0:
+			switch field.Translated.InformationElementID {
+			case ieIngressInterface:
+				f.IngressInterface = uint32(unsigned(b))
+			case ieEgressInterface:
+				f.EgressInterface = uint32(unsigned(b))
+			}
+
+		case VMwarePEN:
+			switch field.Translated.InformationElementID {
+			case vmwareTenantProtocol:
+				f.Protocol = uint8(unsigned(b))
+				found = true
+			case vmwareTenantSourceIPv4, vmwareTenantSourceIPv6:
+				f.SourceAddress = net.IP(b)
+				found = true
+			case vmwareTenantDestIPv4, vmwareTenantDestIPv6:
+				f.DestinationAddress = net.IP(b)
+				found = true
+			case vmwareTenantSourcePort:
+				f.SourcePort = uint16(unsigned(b))
+			case vmwareTenantDestPort:
+				f.DestinationPort = uint16(unsigned(b))
+			case vmwareVXLANExportRole:
+				f.VXLANExportRole = uint8(unsigned(b))
+			case vmwareIngressInterfaceAttr:
+				f.IngressInterfaceAttr = uint16(unsigned(b))
+			case vmwareEgressInterfaceAttr:
+				f.EgressInterfaceAttr = uint16(unsigned(b))
+			case vmwareVirtualObsID:
+				f.VirtualObsID = vmwareString(b)
+			}
+		}
+	}
+	if !found {
+		return nil, false
+	}
+	if s != nil {
+		f.resolve(s, domain)
+	}
+	return f, true
+}
+
+// resolve looks up the interface attributes and virtual observation point IDs
+// for the ingress and egress interfaces, attributes present in the record
+// itself take precedence.
+func (f *InnerFlow) resolve(s session.Session, domain uint32) {
+	f.IngressVirtualObsID = vmwareOptionString(s, domain, vmwareVirtualObsID, f.IngressInterface)
+	f.EgressVirtualObsID = vmwareOptionString(s, domain, vmwareVirtualObsID, f.EgressInterface)
+	// The attributes describe the interface, regardless of the direction
+	// of the options record they were announced in
+	if f.IngressInterfaceAttr == 0 {
+		f.IngressInterfaceAttr = vmwareInterfaceAttr(s, domain, f.IngressInterface)
+	}
+	if f.EgressInterfaceAttr == 0 {
+		f.EgressInterfaceAttr = vmwareInterfaceAttr(s, domain, f.EgressInterface)
+	}
+}
+
+func (f *InnerFlow) String() string {
+	s := fmt.Sprintf("tenant proto %d %s -> %s, ingress %d (%s) egress %d (%s)", f.Protocol,
+		net.JoinHostPort(f.SourceAddress.String(), strconv.Itoa(int(f.SourcePort))),
+		net.JoinHostPort(f.DestinationAddress.String(), strconv.Itoa(int(f.DestinationPort))),
+		f.IngressInterface, f.IngressVirtualObsID, f.EgressInterface, f.EgressVirtualObsID)
+	if f.VirtualObsID != "" {
+		s += ", observed at " + f.VirtualObsID
+	}
+	return s
+}
+
+func vmwareOption(s session.Session, domain uint32, id uint16, ifIndex uint32) *session.Option {
+	if ifIndex == 0 {
+		return nil
+	}
+	return s.GetOption(domain, VMwarePEN, id, session.SCOPE_INTERFACE, ifIndex)
+}
+
+func vmwareOptionString(s session.Session, domain uint32, id uint16, ifIndex uint32) string {
+	if option := vmwareOption(s, domain, id, ifIndex); option != nil {
+		return vmwareString(option.Bytes)
+	}
+	return ""
+}
+
+func vmwareInterfaceAttr(s session.Session, domain uint32, ifIndex uint32) uint16 {
+	for _, id := range []uint16{vmwareIngressInterfaceAttr, vmwareEgressInterfaceAttr} {
+		if option := vmwareOption(s, domain, id, ifIndex); option != nil {
+			return uint16(unsigned(option.Bytes))
+		}
+	}
+	return 0
+}
+
+// vmwareString decodes a NUL padded string.
+func vmwareString(b []byte) string {
+	return strings.TrimRight(string(b), "\x00")
+}
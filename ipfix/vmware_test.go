@@ -0,0 +1,129 @@
+package ipfix
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+// testVMwareMessage returns a message with the interface options and a tenant
+// flow over IPv4 and IPv6, as exported by a vSphere Distributed Switch.
+func testVMwareMessage(t *testing.T) []byte {
+	e := NewExporter(0)
+	for _, template := range []session.Template{
+		&OptionsTemplateRecord{TemplateID: 400,
+			ScopeFields: FieldSpecifiers{{InformationElementID: ieIngressInterface, Length: 4}},
+			Fields: FieldSpecifiers{
+				{EnterpriseBitSet: true, EnterpriseNumber: VMwarePEN, InformationElementID: vmwareVirtualObsID, Length: 65535},
+				{EnterpriseBitSet: true, EnterpriseNumber: VMwarePEN, InformationElementID: vmwareIngressInterfaceAttr, Length: 2},
+			},
+		},
+		&TemplateRecord{TemplateID: 300, Fields: FieldSpecifiers{
+			{InformationElementID: ieIngressInterface, Length: 4},
+			{InformationElementID: ieEgressInterface, Length: 4},
+			{EnterpriseBitSet: true, EnterpriseNumber: VMwarePEN, InformationElementID: vmwareTenantProtocol, Length: 1},
+			{EnterpriseBitSet: true, EnterpriseNumber: VMwarePEN, InformationElementID: vmwareTenantSourceIPv4, Length: 4},
+			{EnterpriseBitSet: true, EnterpriseNumber: VMwarePEN, InformationElementID: vmwareTenantDestIPv4, Length: 4},
+			{EnterpriseBitSet: true, EnterpriseNumber: VMwarePEN, InformationElementID: vmwareTenantSourcePort, Length: 2},
+			{EnterpriseBitSet: true, EnterpriseNumber: VMwarePEN, InformationElementID: vmwareTenantDestPort, Length: 2},
+			{EnterpriseBitSet: true, EnterpriseNumber: VMwarePEN, InformationElementID: vmwareVXLANExportRole, Length: 1},
+		}},
+		&TemplateRecord{TemplateID: 301, Fields: FieldSpecifiers{
+			{InformationElementID: ieIngressInterface, Length: 4},
+			{InformationElementID: ieEgressInterface, Length: 4},
+			{EnterpriseBitSet: true, EnterpriseNumber: VMwarePEN, InformationElementID: vmwareTenantProtocol, Length: 1},
+			{EnterpriseBitSet: true, EnterpriseNumber: VMwarePEN, InformationElementID: vmwareTenantSourceIPv6, Length: 16},
+			{EnterpriseBitSet: true, EnterpriseNumber: VMwarePEN, InformationElementID: vmwareTenantDestIPv6, Length: 16},
+			{EnterpriseBitSet: true, EnterpriseNumber: VMwarePEN, InformationElementID: vmwareEgressInterfaceAttr, Length: 2},
+			{EnterpriseBitSet: true, EnterpriseNumber: VMwarePEN, InformationElementID: vmwareVirtualObsID, Length: 65535},
+		}},
+	} {
+		if err := e.AddTemplate(1, template); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, values := range [][]interface{}{
+		{400, 1, "vnic-1", uint16(1)},
+		{400, 2, "uplink-2", uint16(3)},
+		{300, uint32(1), uint32(2), uint8(6), net.IP{192, 168, 1, 1}, net.IP{192, 168, 2, 2}, uint16(49152), uint16(443), uint8(1)},
+		{301, uint32(2), uint32(0), uint8(17), net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), uint16(5), "vtep-7"},
+	} {
+		if err := e.AddRecord(1, uint16(values[0].(int)), values[1:]...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	messages, err := e.Flush(time.Unix(1600000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages[0]
+}
+
+func TestVMwareInnerFlow(t *testing.T) {
+	m, err := NewDecoder(nil, session.New()).Decode(testVMwareMessage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.DataSets) != 2 {
+		t.Fatalf("expected 2 data sets, got %d", len(m.DataSets))
+	}
+
+	f := m.DataSets[0].Records[0].InnerFlow
+	if f == nil {
+		t.Fatal("expected IPv4 record to have an inner flow")
+	}
+	if f.Protocol != 6 || !f.SourceAddress.Equal(net.IP{192, 168, 1, 1}) || !f.DestinationAddress.Equal(net.IP{192, 168, 2, 2}) ||
+		f.SourcePort != 49152 || f.DestinationPort != 443 || f.VXLANExportRole != 1 {
+		t.Fatalf("unexpected inner flow %+v", f)
+	}
+	// Both interfaces are resolved through the interface scoped options
+	if f.IngressInterface != 1 || f.IngressVirtualObsID != "vnic-1" || f.IngressInterfaceAttr != 1 ||
+		f.EgressInterface != 2 || f.EgressVirtualObsID != "uplink-2" || f.EgressInterfaceAttr != 3 {
+		t.Fatalf("unexpected inner flow interfaces %+v", f)
+	}
+
+	f = m.DataSets[1].Records[0].InnerFlow
+	if f == nil {
+		t.Fatal("expected IPv6 record to have an inner flow")
+	}
+	if f.Protocol != 17 || !f.SourceAddress.Equal(net.ParseIP("2001:db8::1")) || !f.DestinationAddress.Equal(net.ParseIP("2001:db8::2")) {
+		t.Fatalf("unexpected inner flow %+v", f)
+	}
+	// The virtual observation point ID in the record doesn't take the place
+	// of the one of the ingress interface, the attribute does
+	if f.VirtualObsID != "vtep-7" || f.IngressVirtualObsID != "uplink-2" || f.EgressVirtualObsID != "" {
+		t.Fatalf("unexpected virtual observation point IDs %+v", f)
+	}
+	if f.IngressInterfaceAttr != 3 || f.EgressInterfaceAttr != 5 {
+		t.Fatalf("unexpected interface attributes %+v", f)
+	}
+
+	// Options records have no inner flow
+	if m.OptionsDataSets[0].Records[0].InnerFlow != nil {
+		t.Fatal("expected options record not to have an inner flow")
+	}
+	if _, ok := NewInnerFlow(&m.OptionsDataSets[0].Records[0], nil, 1); ok {
+		t.Fatal("expected options record not to have an inner flow")
+	}
+}
+
+func TestVMwareDump(t *testing.T) {
+	m, err := NewDecoder(nil, session.New()).Decode(testVMwareMessage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := captureStdout(t, func() { Dump(m) })
+	for _, want := range []string{
+		"tenantSourceIPv4: 192.168.1.1\n",
+		"inner flow: tenant proto 6 192.168.1.1:49152 -> 192.168.2.2:443, ingress 1 (vnic-1) egress 2 (uplink-2)\n",
+		"inner flow: tenant proto 17 [2001:db8::1]:0 -> [2001:db8::2]:0, ingress 2 (uplink-2) egress 0 (), observed at vtep-7\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in dump:\n%s", want, out)
+		}
+	}
+}
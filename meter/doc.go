@@ -0,0 +1,14 @@
+/*
+Package meter implements a flow metering process, building flows from packets.
+
+# About
+
+The meter observes packets, for example from a mirror port or a PCAP file, and
+keeps a flow cache keyed by the 5-tuple of the packets. Flows expire after an
+active or idle timeout, or when a TCP FIN or RST is seen. The expired flows can
+be exported as NetFlow version 5, NetFlow version 9 or IPFIX records. If the
+packets are sampled, the sampling interval and algorithm are exported in the
+NetFlow version 5 Packet Header, or in an options record for NetFlow version 9
+and IPFIX.
+*/
+package meter
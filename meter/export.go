@@ -0,0 +1,234 @@
+package meter
+
+import (
+	"time"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow5"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/session"
+)
+
+// Template IDs used for exported flows, and for the options record with the
+// sampling configuration
+const (
+	TemplateIPv4     uint16 = 256
+	TemplateIPv6     uint16 = 257
+	TemplateSampling uint16 = 258
+)
+
+// Fields in the sampling options record, samplingInterval and
+// samplingAlgorithm
+const (
+	fieldSamplingInterval  uint16 = 34
+	fieldSamplingAlgorithm uint16 = 35
+)
+
+// ieObservationDomainID is the observationDomainId, used as the scope of the
+// IPFIX sampling options record.
+const ieObservationDomainID uint16 = 149
+
+// maxNetflow5Records is the maximum number of records in a NetFlow version 5
+// packet.
+const maxNetflow5Records = 30
+
+// Netflow5Exporter builds NetFlow version 5 packets from flows. NetFlow
+// version 5 has no support for IPv6, IPv6 flows are skipped.
+type Netflow5Exporter struct {
+	// Boot is the time the meter started, the SysUptime in the packets and
+	// flow timestamps are relative to Boot.
+	Boot time.Time
+
+	EngineType uint8
+	EngineID   uint8
+
+	// Sampler, if set, is the sampler of the meter. Its algorithm and
+	// interval are encoded in the SamplingInterval of the Packet Header,
+	// intervals that don't fit in 14 bits are capped.
+	Sampler Sampler
+
+	sequence uint32
+}
+
+// Packets converts the flows to NetFlow version 5 packets.
+func (e *Netflow5Exporter) Packets(flows []*Flow, now time.Time) []*netflow5.Packet {
+	var (
+		packets []*netflow5.Packet
+		p       *netflow5.Packet
+	)
+	for _, f := range flows {
+		if f.IsIPv6() {
+			continue
+		}
+		if p == nil || len(p.Records) == maxNetflow5Records {
+			p = &netflow5.Packet{Header: netflow5.PacketHeader{
+				Version:          netflow5.Version,
+				SysUptime:        now.Sub(e.Boot) / time.Millisecond * time.Millisecond,
+				Unix:             now,
+				FlowSequence:     e.sequence,
+				EngineType:       e.EngineType,
+				EngineID:         e.EngineID,
+				SamplingInterval: netflow5SamplingInterval(e.Sampler),
+			}}
+			packets = append(packets, p)
+		}
+		p.Records = append(p.Records, &netflow5.FlowRecord{
+			SrcAddr:  f.SrcAddr.To4(),
+			DstAddr:  f.DstAddr.To4(),
+			NextHop:  make([]byte, 4),
+			Packets:  uint32(f.Packets),
+			Bytes:    uint32(f.Octets),
+			First:    uptime(e.Boot, f.Start),
+			Last:     uptime(e.Boot, f.End),
+			SrcPort:  f.SrcPort,
+			DstPort:  f.DstPort,
+			TCPFlags: f.TCPFlags,
+			Protocol: f.Protocol,
+			ToS:      f.ToS,
+		})
+		p.Header.Count = uint16(len(p.Records))
+		e.sequence++
+	}
+	return packets
+}
+
+// netflow5SamplingInterval encodes the sampling algorithm in the two most
+// significant bits, and the interval in the remaining 14 bits.
+func netflow5SamplingInterval(s Sampler) uint16 {
+	if s == nil {
+		return 0
+	}
+	n := s.Interval()
+	if n > 0x3fff {
+		n = 0x3fff
+	}
+	return uint16(s.Algorithm())<<14 | uint16(n)
+}
+
+// Fields in the exported templates
+var (
+	netflow9Fields = []uint16{
+		8, 12, 7, 11, 4, 5, 6, 2, 1, 22, 21, // IPv4
+		27, 28, 7, 11, 4, 5, 6, 2, 1, 22, 21, // IPv6
+	}
+	ipfixFields = []uint16{
+		8, 12, 7, 11, 4, 5, 6, 2, 1, 152, 153, 136, // IPv4
+		27, 28, 7, 11, 4, 5, 6, 2, 1, 152, 153, 136, // IPv6
+	}
+	fieldLengths = map[uint16]uint16{
+		1: 8, 2: 8, 4: 1, 5: 1, 6: 1, 7: 2, 8: 4, 11: 2, 12: 4, 21: 4, 22: 4,
+		27: 16, 28: 16, 136: 1, 152: 8, 153: 8,
+	}
+)
+
+// ExportNetflow9 adds the flows to the NetFlow version 9 exporter, the
+// templates for IPv4 and IPv6 flows are added when they're missing. The flow
+// timestamps are relative to the Boot time of the exporter.
+func ExportNetflow9(e *netflow9.Exporter, flows []*Flow) error {
+	for i, id := range []uint16{TemplateIPv4, TemplateIPv6} {
+		if _, ok := e.Template(id); ok {
+			continue
+		}
+		template := &netflow9.TemplateRecord{TemplateID: id}
+		for _, field := range netflow9Fields[i*11 : i*11+11] {
+			template.Fields = append(template.Fields, netflow9.FieldSpecifier{Type: field, Length: fieldLengths[field]})
+		}
+		template.FieldCount = uint16(len(template.Fields))
+		if err := e.AddTemplate(template); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range flows {
+		id, src, dst := TemplateIPv4, f.SrcAddr.To4(), f.DstAddr.To4()
+		if f.IsIPv6() {
+			id, src, dst = TemplateIPv6, f.SrcAddr.To16(), f.DstAddr.To16()
+		}
+		err := e.AddRecord(id, src, dst, f.SrcPort, f.DstPort, f.Protocol, f.ToS, f.TCPFlags,
+			f.Packets, f.Octets, uptime(e.Boot, f.Start), uptime(e.Boot, f.End))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportNetflow9Sampling adds an options record with the sampling interval and
+// algorithm of the sampler to the NetFlow version 9 exporter, the options
+// template is added when it's missing. The record is scoped to the system,
+// identified by the Source ID. Collectors need the record to scale the
+// counters of sampled flows, it should be exported again periodically, for
+// example when the templates are refreshed.
+func ExportNetflow9Sampling(e *netflow9.Exporter, s Sampler) error {
+	if _, ok := e.Template(TemplateSampling); !ok {
+		template := &netflow9.OptionTemplateRecord{
+			TemplateID: TemplateSampling,
+			Scopes:     netflow9.ScopeSpecifiers{{Type: session.SCOPE_SYSTEM, Length: 4}},
+			Options: netflow9.FieldSpecifiers{
+				{Type: fieldSamplingInterval, Length: 4},
+				{Type: fieldSamplingAlgorithm, Length: 1},
+			},
+		}
+		if err := e.AddTemplate(template); err != nil {
+			return err
+		}
+	}
+	return e.AddRecord(TemplateSampling, e.SourceID, s.Interval(), s.Algorithm())
+}
+
+// ExportIPFIX adds the flows to the IPFIX exporter in the Observation Domain,
+// the templates for IPv4 and IPv6 flows are added when they're missing.
+func ExportIPFIX(e *ipfix.Exporter, domain uint32, flows []*Flow) error {
+	for i, id := range []uint16{TemplateIPv4, TemplateIPv6} {
+		if _, ok := e.Template(domain, id); ok {
+			continue
+		}
+		template := &ipfix.TemplateRecord{TemplateID: id}
+		for _, field := range ipfixFields[i*12 : i*12+12] {
+			template.Fields = append(template.Fields, ipfix.FieldSpecifier{InformationElementID: field, Length: fieldLengths[field]})
+		}
+		template.FieldCount = uint16(len(template.Fields))
+		if err := e.AddTemplate(domain, template); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range flows {
+		id, src, dst := TemplateIPv4, f.SrcAddr.To4(), f.DstAddr.To4()
+		if f.IsIPv6() {
+			id, src, dst = TemplateIPv6, f.SrcAddr.To16(), f.DstAddr.To16()
+		}
+		err := e.AddRecord(domain, id, src, dst, f.SrcPort, f.DstPort, f.Protocol, f.ToS, f.TCPFlags,
+			f.Packets, f.Octets, f.Start, f.End, f.EndReason)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportIPFIXSampling adds an options record with the sampling interval and
+// algorithm of the sampler to the IPFIX exporter, scoped to the Observation
+// Domain. The options template is added when it's missing. As with NetFlow
+// version 9, the record should be exported again periodically.
+func ExportIPFIXSampling(e *ipfix.Exporter, domain uint32, s Sampler) error {
+	if _, ok := e.Template(domain, TemplateSampling); !ok {
+		template := &ipfix.OptionsTemplateRecord{
+			TemplateID:  TemplateSampling,
+			ScopeFields: ipfix.FieldSpecifiers{{InformationElementID: ieObservationDomainID, Length: 4}},
+			Fields: ipfix.FieldSpecifiers{
+				{InformationElementID: fieldSamplingInterval, Length: 4},
+				{InformationElementID: fieldSamplingAlgorithm, Length: 1},
+			},
+		}
+		if err := e.AddTemplate(domain, template); err != nil {
+			return err
+		}
+	}
+	return e.AddRecord(domain, TemplateSampling, domain, s.Interval(), s.Algorithm())
+}
+
+// uptime returns the milliseconds since boot.
+func uptime(boot, t time.Time) uint32 {
+	return uint32(t.Sub(boot) / time.Millisecond)
+}
@@ -0,0 +1,87 @@
+package meter
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/translate"
+)
+
+func TestNetflow5SamplingInterval(t *testing.T) {
+	tests := []struct {
+		sampler Sampler
+		expect  uint16
+	}{
+		{nil, 0},
+		{NewDeterministicSampler(100), 0x4064},
+		{NewRandomSampler(100, rand.NewSource(1)), 0x8064},
+		{NewDeterministicSampler(100000), 0x7fff},
+	}
+	for _, test := range tests {
+		m := New()
+		m.Packet(testPacket(t, 0, 1000, false, false))
+		e := &Netflow5Exporter{Boot: testStart, Sampler: test.sampler}
+		packets := e.Packets(m.Flush(), testStart)
+		if len(packets) != 1 {
+			t.Fatalf("expected one packet, got %d", len(packets))
+		}
+		if interval := packets[0].Header.SamplingInterval; interval != test.expect {
+			t.Fatalf("expected sampling interval %#04x, got %#04x", test.expect, interval)
+		}
+	}
+}
+
+func TestExportNetflow9Sampling(t *testing.T) {
+	e := netflow9.NewExporter(42, 0)
+	if err := ExportNetflow9Sampling(e, NewRandomSampler(64, rand.NewSource(1))); err != nil {
+		t.Fatal(err)
+	}
+	packets, err := e.Flush(testStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := session.New()
+	if _, err = netflow9.NewDecoder(nil, s).Decode(packets[0]); err != nil {
+		t.Fatal(err)
+	}
+	assertSamplingOption(t, s, 42, session.SCOPE_SYSTEM, 42, 64, SamplingRandom)
+}
+
+func TestExportIPFIXSampling(t *testing.T) {
+	e := ipfix.NewExporter(0)
+	if err := ExportIPFIXSampling(e, 7, NewDeterministicSampler(1000)); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := e.Flush(testStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := session.New()
+	if _, err = ipfix.NewDecoder(nil, s).Decode(messages[0]); err != nil {
+		t.Fatal(err)
+	}
+	assertSamplingOption(t, s, 7, session.SCOPE_SYSTEM, 0, 1000, SamplingDeterministic)
+}
+
+func assertSamplingOption(t *testing.T, s session.Session, domain uint32, scopeType uint16, scopeIndex uint32, interval uint32, algorithm uint8) {
+	t.Helper()
+	option := s.GetOption(domain, 0, fieldSamplingInterval, scopeType, scopeIndex)
+	if option == nil {
+		t.Fatal("expected sampling interval option")
+	}
+	if value, _ := translate.Unsigned(option.Bytes); value != uint64(interval) {
+		t.Fatalf("expected sampling interval %d, got %d", interval, value)
+	}
+	option = s.GetOption(domain, 0, fieldSamplingAlgorithm, scopeType, scopeIndex)
+	if option == nil {
+		t.Fatal("expected sampling algorithm option")
+	}
+	if value, _ := translate.Unsigned(option.Bytes); value != uint64(algorithm) {
+		t.Fatalf("expected sampling algorithm %d, got %d", algorithm, value)
+	}
+}
@@ -0,0 +1,241 @@
+package meter
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// Default timeouts
+const (
+	DefaultActiveTimeout = 30 * time.Minute
+	DefaultIdleTimeout   = 15 * time.Second
+)
+
+// Flow end reasons, as used by the flowEndReason (RFC 5102 section 5.11.3)
+const (
+	EndReasonIdleTimeout   uint8 = 0x01
+	EndReasonActiveTimeout uint8 = 0x02
+	EndReasonEndOfFlow     uint8 = 0x03
+	EndReasonForcedEnd     uint8 = 0x04
+)
+
+// TCP flags that end a flow
+const (
+	tcpFlagFIN uint8 = 0x01
+	tcpFlagRST uint8 = 0x04
+)
+
+// Key identifies a flow by its 5-tuple.
+type Key struct {
+	SrcAddr  [16]byte
+	DstAddr  [16]byte
+	SrcPort  uint16
+	DstPort  uint16
+	Protocol uint8
+}
+
+// Flow is a metered flow.
+type Flow struct {
+	Key
+	SrcAddr   net.IP
+	DstAddr   net.IP
+	ToS       uint8
+	TCPFlags  uint8
+	Packets   uint64
+	Octets    uint64
+	Start     time.Time
+	End       time.Time
+	EndReason uint8
+}
+
+// IsIPv6 checks if the flow is an IPv6 flow.
+func (f *Flow) IsIPv6() bool {
+	return f.SrcAddr.To4() == nil
+}
+
+// Meter maintains a flow cache. It's not safe for concurrent use.
+type Meter struct {
+	// ActiveTimeout is the maximum duration of a flow, longer flows are
+	// expired and continue in a new flow.
+	ActiveTimeout time.Duration
+
+	// IdleTimeout is the time after the last packet after which a flow is
+	// expired.
+	IdleTimeout time.Duration
+
+	// Sampler selects the packets that are metered, if nil all packets are
+	// metered.
+	Sampler Sampler
+
+	flows   map[Key]*Flow
+	expired []*Flow
+}
+
+// New returns a new meter using the default timeouts.
+func New() *Meter {
+	return &Meter{
+		ActiveTimeout: DefaultActiveTimeout,
+		IdleTimeout:   DefaultIdleTimeout,
+		flows:         make(map[Key]*Flow),
+	}
+}
+
+// Len returns the number of flows in the cache.
+func (m *Meter) Len() int {
+	return len(m.flows)
+}
+
+// Packet meters a packet, packets that are not IPv4 or IPv6 are ignored. The
+// timestamp of the packet is taken from its metadata.
+func (m *Meter) Packet(p gopacket.Packet) {
+	if m.Sampler != nil && !m.Sampler.Sample() {
+		return
+	}
+
+	var (
+		f      Flow
+		length uint64
+	)
+	switch ip := p.NetworkLayer().(type) {
+	case *layers.IPv4:
+		f.SrcAddr, f.DstAddr = ip.SrcIP, ip.DstIP
+		f.Protocol, f.ToS = uint8(ip.Protocol), ip.TOS
+		length = uint64(ip.Length)
+	case *layers.IPv6:
+		f.SrcAddr, f.DstAddr = ip.SrcIP, ip.DstIP
+		f.Protocol, f.ToS = uint8(ip.NextHeader), ip.TrafficClass
+		length = uint64(ip.Length) + 40
+	default:
+		return
+	}
+
+	switch l := p.TransportLayer().(type) {
+	case *layers.TCP:
+		f.SrcPort, f.DstPort = uint16(l.SrcPort), uint16(l.DstPort)
+		f.TCPFlags = tcpFlags(l)
+	case *layers.UDP:
+		f.SrcPort, f.DstPort = uint16(l.SrcPort), uint16(l.DstPort)
+	case *layers.SCTP:
+		f.SrcPort, f.DstPort = uint16(l.SrcPort), uint16(l.DstPort)
+	}
+	if icmp, ok := p.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); ok {
+		// NetFlow convention, the ICMP type and code in the destination port
+		f.DstPort = uint16(icmp.TypeCode)
+	} else if icmp, ok := p.Layer(layers.LayerTypeICMPv6).(*layers.ICMPv6); ok {
+		f.DstPort = uint16(icmp.TypeCode)
+	}
+
+	m.observe(p.Metadata().Timestamp, &f, length)
+}
+
+// observe adds a packet to its flow in the cache.
+func (m *Meter) observe(ts time.Time, p *Flow, length uint64) {
+	copy(p.Key.SrcAddr[:], p.SrcAddr.To16())
+	copy(p.Key.DstAddr[:], p.DstAddr.To16())
+
+	f, ok := m.flows[p.Key]
+	if ok && m.ActiveTimeout > 0 && ts.Sub(f.Start) >= m.ActiveTimeout {
+		m.expire(f, EndReasonActiveTimeout)
+		ok = false
+	}
+	if !ok {
+		f = &Flow{
+			Key:     p.Key,
+			SrcAddr: p.SrcAddr,
+			DstAddr: p.DstAddr,
+			ToS:     p.ToS,
+			Start:   ts,
+		}
+		m.flows[f.Key] = f
+	}
+
+	f.Packets++
+	f.Octets += length
+	f.TCPFlags |= p.TCPFlags
+	f.End = ts
+
+	if p.TCPFlags&(tcpFlagFIN|tcpFlagRST) != 0 {
+		m.expire(f, EndReasonEndOfFlow)
+	}
+}
+
+func (m *Meter) expire(f *Flow, reason uint8) {
+	f.EndReason = reason
+	delete(m.flows, f.Key)
+	m.expired = append(m.expired, f)
+}
+
+// Expire returns the flows that ended, or timed out at the time now.
+func (m *Meter) Expire(now time.Time) []*Flow {
+	for _, f := range m.flows {
+		switch {
+		case m.IdleTimeout > 0 && now.Sub(f.End) >= m.IdleTimeout:
+			m.expire(f, EndReasonIdleTimeout)
+		case m.ActiveTimeout > 0 && now.Sub(f.Start) >= m.ActiveTimeout:
+			m.expire(f, EndReasonActiveTimeout)
+		}
+	}
+	flows := m.expired
+	m.expired = nil
+	return flows
+}
+
+// Flush expires all flows in the cache.
+func (m *Meter) Flush() []*Flow {
+	for _, f := range m.flows {
+		m.expire(f, EndReasonForcedEnd)
+	}
+	flows := m.expired
+	m.expired = nil
+	return flows
+}
+
+// ReadPcap meters all packets in a PCAP file. The flows are expired using the
+// timestamps of the packets, emit is called with the expired flows and with
+// the remaining flows at the end of the file.
+func (m *Meter) ReadPcap(r io.Reader, emit func([]*Flow)) error {
+	pr, err := pcapgo.NewReader(r)
+	if err != nil {
+		return err
+	}
+
+	var last time.Time
+	source := gopacket.NewPacketSource(pr, pr.LinkType())
+	source.NoCopy = true
+	for {
+		p, err := source.NextPacket()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		m.Packet(p)
+
+		// Check for timeouts once per second of capture time
+		if ts := p.Metadata().Timestamp; ts.Sub(last) >= time.Second {
+			if flows := m.Expire(ts); len(flows) > 0 {
+				emit(flows)
+			}
+			last = ts
+		}
+	}
+	if flows := m.Flush(); len(flows) > 0 {
+		emit(flows)
+	}
+	return nil
+}
+
+func tcpFlags(tcp *layers.TCP) uint8 {
+	var flags uint8
+	for i, set := range []bool{tcp.FIN, tcp.SYN, tcp.RST, tcp.PSH, tcp.ACK, tcp.URG, tcp.ECE, tcp.CWR} {
+		if set {
+			flags |= 1 << uint(i)
+		}
+	}
+	return flags
+}
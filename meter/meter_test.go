@@ -0,0 +1,141 @@
+package meter
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var testStart = time.Unix(1600000000, 0)
+
+// testPacket builds an Ethernet frame with an IPv4 TCP segment, captured at
+// the given offset from testStart.
+func testPacket(t *testing.T, after time.Duration, srcPort uint16, fin, rst bool) gopacket.Packet {
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 6},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		TOS:      0x10,
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    net.IP{10, 0, 0, 1},
+		DstIP:    net.IP{10, 0, 0, 2},
+	}
+	tcp := &layers.TCP{
+		SrcPort: layers.TCPPort(srcPort),
+		DstPort: 80,
+		ACK:     true,
+		FIN:     fin,
+		RST:     rst,
+		Window:  1024,
+	}
+	tcp.SetNetworkLayerForChecksum(ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, tcp, gopacket.Payload("hello")); err != nil {
+		t.Fatal(err)
+	}
+	p := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+	p.Metadata().Timestamp = testStart.Add(after)
+	return p
+}
+
+func TestMeterPacket(t *testing.T) {
+	m := New()
+	m.Packet(testPacket(t, 0, 1000, false, false))
+	m.Packet(testPacket(t, time.Second, 1000, false, false))
+	if m.Len() != 1 {
+		t.Fatalf("expected 1 flow, got %d", m.Len())
+	}
+
+	flows := m.Flush()
+	if len(flows) != 1 {
+		t.Fatalf("expected 1 flow, got %d", len(flows))
+	}
+	f := flows[0]
+	if !f.SrcAddr.Equal(net.IP{10, 0, 0, 1}) || !f.DstAddr.Equal(net.IP{10, 0, 0, 2}) || f.SrcPort != 1000 || f.DstPort != 80 {
+		t.Fatalf("unexpected flow %+v", f)
+	}
+	if f.Protocol != 6 || f.ToS != 0x10 || f.TCPFlags != 0x10 || f.Packets != 2 || f.Octets != 90 {
+		t.Fatalf("unexpected flow %+v", f)
+	}
+	if !f.Start.Equal(testStart) || !f.End.Equal(testStart.Add(time.Second)) || f.EndReason != EndReasonForcedEnd {
+		t.Fatalf("unexpected flow times %+v", f)
+	}
+	if m.Len() != 0 {
+		t.Fatalf("expected empty cache, got %d flows", m.Len())
+	}
+}
+
+func TestMeterEndOfFlow(t *testing.T) {
+	m := New()
+	m.Packet(testPacket(t, 0, 1000, false, false))
+	m.Packet(testPacket(t, time.Second, 1000, true, false))
+	m.Packet(testPacket(t, 0, 2000, false, false))
+	m.Packet(testPacket(t, time.Second, 2000, false, true))
+
+	// A packet after the FIN starts a new flow
+	m.Packet(testPacket(t, 2*time.Second, 1000, false, false))
+	if m.Len() != 1 {
+		t.Fatalf("expected 1 flow, got %d", m.Len())
+	}
+
+	flows := m.Expire(testStart.Add(2 * time.Second))
+	if len(flows) != 2 {
+		t.Fatalf("expected 2 flows, got %d", len(flows))
+	}
+	for _, f := range flows {
+		if f.EndReason != EndReasonEndOfFlow || f.Packets != 2 {
+			t.Fatalf("unexpected flow %+v", f)
+		}
+	}
+	if flags := flows[0].TCPFlags; flags&tcpFlagFIN == 0 {
+		t.Fatalf("expected FIN in flags %#02x", flags)
+	}
+	if flags := flows[1].TCPFlags; flags&tcpFlagRST == 0 {
+		t.Fatalf("expected RST in flags %#02x", flags)
+	}
+}
+
+func TestMeterExpire(t *testing.T) {
+	m := New()
+	m.IdleTimeout = 15 * time.Second
+	m.ActiveTimeout = time.Minute
+
+	// Flow 1000 is idle, flow 2000 stays active
+	m.Packet(testPacket(t, 0, 1000, false, false))
+	for i := 0; i <= 90; i += 10 {
+		m.Packet(testPacket(t, time.Duration(i)*time.Second, 2000, false, false))
+		if i == 10 {
+			if flows := m.Expire(testStart.Add(14 * time.Second)); len(flows) != 0 {
+				t.Fatalf("unexpected expired flows %+v", flows)
+			}
+		}
+		if i == 20 {
+			flows := m.Expire(testStart.Add(20 * time.Second))
+			if len(flows) != 1 || flows[0].SrcPort != 1000 || flows[0].EndReason != EndReasonIdleTimeout {
+				t.Fatalf("expected idle flow to expire, got %+v", flows)
+			}
+		}
+	}
+
+	// The packet at 60 seconds exceeds the active timeout, and starts a new
+	// flow
+	flows := m.Expire(testStart.Add(90 * time.Second))
+	if len(flows) != 1 || flows[0].EndReason != EndReasonActiveTimeout || flows[0].Packets != 6 {
+		t.Fatalf("expected active flow to expire, got %+v", flows)
+	}
+	if m.Len() != 1 {
+		t.Fatalf("expected 1 flow, got %d", m.Len())
+	}
+	if flows = m.Expire(testStart.Add(150 * time.Second)); len(flows) != 1 || flows[0].Packets != 4 {
+		t.Fatalf("expected continued flow to expire, got %+v", flows)
+	}
+}
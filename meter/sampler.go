@@ -0,0 +1,79 @@
+package meter
+
+import (
+	"math/rand"
+	"sync/atomic"
+)
+
+// Sampling algorithms, as used by the NetFlow version 9 SAMPLING_ALGORITHM
+// field (RFC 3954 section 8) and the sampling mode in the NetFlow version 5
+// Packet Header.
+const (
+	SamplingDeterministic uint8 = 0x01
+	SamplingRandom        uint8 = 0x02
+)
+
+// Sampler selects packets for metering.
+type Sampler interface {
+	// Sample checks if the next packet is selected.
+	Sample() bool
+
+	// Interval returns N, for 1-in-N sampling.
+	Interval() uint32
+
+	// Algorithm returns the sampling algorithm.
+	Algorithm() uint8
+}
+
+// DeterministicSampler selects every Nth packet.
+type DeterministicSampler struct {
+	n     uint32
+	count uint32
+}
+
+// NewDeterministicSampler returns a sampler selecting every Nth packet.
+func NewDeterministicSampler(n uint32) *DeterministicSampler {
+	if n == 0 {
+		n = 1
+	}
+	return &DeterministicSampler{n: n}
+}
+
+func (s *DeterministicSampler) Sample() bool {
+	return atomic.AddUint32(&s.count, 1)%s.n == 1%s.n
+}
+
+func (s *DeterministicSampler) Interval() uint32 {
+	return s.n
+}
+
+func (s *DeterministicSampler) Algorithm() uint8 {
+	return SamplingDeterministic
+}
+
+// RandomSampler selects a packet with a probability of 1 in N.
+type RandomSampler struct {
+	n    uint32
+	rand *rand.Rand
+}
+
+// NewRandomSampler returns a sampler selecting 1 in N packets at random, using
+// the random source.
+func NewRandomSampler(n uint32, source rand.Source) *RandomSampler {
+	if n == 0 {
+		n = 1
+	}
+	return &RandomSampler{n: n, rand: rand.New(source)}
+}
+
+func (s *RandomSampler) Sample() bool {
+	return s.rand.Int63n(int64(s.n)) == 0
+}
+
+func (s *RandomSampler) Interval() uint32 {
+	return s.n
+}
+
+func (s *RandomSampler) Algorithm() uint8 {
+	return SamplingRandom
+}
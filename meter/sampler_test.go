@@ -0,0 +1,57 @@
+package meter
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDeterministicSampler(t *testing.T) {
+	s := NewDeterministicSampler(4)
+	var selected []int
+	for i := 0; i < 12; i++ {
+		if s.Sample() {
+			selected = append(selected, i)
+		}
+	}
+	if len(selected) != 3 || selected[0] != 0 || selected[1] != 4 || selected[2] != 8 {
+		t.Fatalf("expected every 4th packet, got %v", selected)
+	}
+	if s.Interval() != 4 || s.Algorithm() != SamplingDeterministic {
+		t.Fatalf("unexpected interval %d algorithm %d", s.Interval(), s.Algorithm())
+	}
+
+	// Without sampling every packet is selected
+	s = NewDeterministicSampler(0)
+	for i := 0; i < 3; i++ {
+		if !s.Sample() {
+			t.Fatal("expected packet to be selected")
+		}
+	}
+}
+
+func TestRandomSampler(t *testing.T) {
+	s := NewRandomSampler(10, rand.NewSource(1))
+	selected := 0
+	for i := 0; i < 10000; i++ {
+		if s.Sample() {
+			selected++
+		}
+	}
+	if selected < 900 || selected > 1100 {
+		t.Fatalf("expected about 1 in 10 packets, got %d in 10000", selected)
+	}
+	if s.Interval() != 10 || s.Algorithm() != SamplingRandom {
+		t.Fatalf("unexpected interval %d algorithm %d", s.Interval(), s.Algorithm())
+	}
+}
+
+func TestMeterSampler(t *testing.T) {
+	m := New()
+	m.Sampler = NewDeterministicSampler(2)
+	for i := 0; i < 6; i++ {
+		m.Packet(testPacket(t, 0, 1000, false, false))
+	}
+	if flows := m.Flush(); len(flows) != 1 || flows[0].Packets != 3 {
+		t.Fatalf("expected 3 sampled packets, got %+v", flows)
+	}
+}
@@ -0,0 +1,11 @@
+/*
+Package nat implements a NAT event model for NetFlow version 9 and IPFIX.
+
+About
+
+Carrier-grade NAT devices log the creation and deletion of NAT bindings, port
+block allocations and resource exhaustion using NetFlow version 9 (Cisco NAT
+Event Logging, NEL) or IPFIX (RFC 8158). This package builds NAT events from
+the decoded data records of either protocol.
+*/
+package nat
@@ -0,0 +1,32 @@
+package nat
+
+import (
+	"fmt"
+
+	"github.com/tehmaze/netflow/translate"
+)
+
+func Dump(events []*Event) {
+	fmt.Printf("%d NAT events\n", len(events))
+	for _, e := range events {
+		fmt.Print("  ")
+		if !e.Time.IsZero() {
+			fmt.Print(e.Time.UTC().Format("2006-01-02 15:04:05.000"), " ")
+		}
+		fmt.Println(e.EventName())
+		fmt.Println("    binding:", e.Binding())
+		if e.Protocol != 0 {
+			fmt.Println("    protocol:", e.Protocol)
+		}
+		if e.Realm != 0 {
+			realm, _ := translate.Enum(translate.Key{EnterpriseID: 0, FieldID: ieNATOriginatingAddressRealm}, uint64(e.Realm))
+			fmt.Println("    realm:   ", e.Realm, realm)
+		}
+		if e.VRFID != 0 {
+			fmt.Println("    vrf:     ", e.VRFID)
+		}
+		if e.PoolID != 0 || e.PoolName != "" {
+			fmt.Println("    pool:    ", e.PoolID, e.PoolName)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package nat
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/translate"
+)
+
+// NAT events (RFC 8158 section 4.1)
+const (
+	EventTranslationCreate     uint8 = 1
+	EventTranslationDelete     uint8 = 2
+	EventAddressesExhausted    uint8 = 3
+	EventNAT44SessionCreate    uint8 = 4
+	EventNAT44SessionDelete    uint8 = 5
+	EventNAT64SessionCreate    uint8 = 6
+	EventNAT64SessionDelete    uint8 = 7
+	EventNAT44BIBCreate        uint8 = 8
+	EventNAT44BIBDelete        uint8 = 9
+	EventNAT64BIBCreate        uint8 = 10
+	EventNAT64BIBDelete        uint8 = 11
+	EventPortsExhausted        uint8 = 12
+	EventQuotaExceeded         uint8 = 13
+	EventAddressBindingCreate  uint8 = 14
+	EventAddressBindingDelete  uint8 = 15
+	EventPortBlockAllocation   uint8 = 16
+	EventPortBlockDeallocation uint8 = 17
+	EventThresholdReached      uint8 = 18
+)
+
+// Information Elements used in NAT events, NetFlow version 9 uses the same
+// field types.
+const (
+	ieProtocolIdentifier               uint16 = 4
+	ieSourceTransportPort              uint16 = 7
+	ieSourceIPv4Address                uint16 = 8
+	ieDestinationTransportPort         uint16 = 11
+	ieDestinationIPv4Address           uint16 = 12
+	ieSourceIPv6Address                uint16 = 27
+	ieDestinationIPv6Address           uint16 = 28
+	iePostNATSourceIPv4Address         uint16 = 225
+	iePostNATDestinationIPv4Address    uint16 = 226
+	iePostNAPTSourceTransportPort      uint16 = 227
+	iePostNAPTDestinationTransportPort uint16 = 228
+	ieNATOriginatingAddressRealm       uint16 = 229
+	ieNATEvent                         uint16 = 230
+	ieIngressVRFID                     uint16 = 234
+	iePostNATSourceIPv6Address         uint16 = 281
+	iePostNATDestinationIPv6Address    uint16 = 282
+	ieNATPoolID                        uint16 = 283
+	ieNATPoolName                      uint16 = 284
+	ieObservationTimeSeconds           uint16 = 322
+	ieObservationTimeMilliseconds      uint16 = 323
+	iePortRangeStart                   uint16 = 361
+	iePortRangeEnd                     uint16 = 362
+	iePortRangeStepSize                uint16 = 363
+	iePortRangeNumPorts                uint16 = 364
+	ieNATInstanceID                    uint16 = 463
+	ieNATQuotaExceededEvent            uint16 = 466
+	ieNATThresholdEvent                uint16 = 467
+)
+
+// Event is a NAT event, such as the creation or deletion of a NAT binding, the
+// allocation of a port block, or a resource limit being hit.
+type Event struct {
+	Time                   time.Time
+	Event                  uint8
+	QuotaExceededEvent     uint32
+	ThresholdEvent         uint32
+	Realm                  uint8
+	InstanceID             uint32
+	VRFID                  uint32
+	PoolID                 uint32
+	PoolName               string
+	Protocol               uint8
+	SourceAddress          net.IP
+	SourcePort             uint16
+	DestinationAddress     net.IP
+	DestinationPort        uint16
+	PostSourceAddress      net.IP
+	PostSourcePort         uint16
+	PostDestinationAddress net.IP
+	PostDestinationPort    uint16
+	PortRangeStart         uint16
+	PortRangeEnd           uint16
+	PortRangeStepSize      uint16
+	PortRangeNumPorts      uint16
+}
+
+// FromIPFIX returns the NAT event in a translated IPFIX Data Record, if the
+// record has no natEvent field it's not a NAT event and false is returned.
+func FromIPFIX(dr *ipfix.DataRecord) (*Event, bool) {
+	var (
+		e     = new(Event)
+		found bool
+	)
+	for _, f := range dr.Fields {
+		if f.Translated == nil || f.Translated.EnterpriseNumber != 0 {
+			continue
+		}
+		found = e.set(f.Translated.InformationElementID, f.Bytes) || found
+	}
+	return e, found
+}
+
+// FromNetflow9 returns the NAT event in a NetFlow version 9 Data Record, if
+// the record has no natEvent field it's not a NAT event and false is returned.
+func FromNetflow9(dr *netflow9.DataRecord) (*Event, bool) {
+	var (
+		e     = new(Event)
+		found bool
+	)
+	for _, f := range dr.Fields {
+		found = e.set(f.Type, f.Bytes) || found
+	}
+	return e, found
+}
+
+// FromIPFIXMessage returns all NAT events in an IPFIX Message.
+func FromIPFIXMessage(m *ipfix.Message) []*Event {
+	var events []*Event
+	for _, ds := range m.DataSets {
+		for i := range ds.Records {
+			if e, ok := FromIPFIX(&ds.Records[i]); ok {
+				events = append(events, e)
+			}
+		}
+	}
+	return events
+}
+
+// FromNetflow9Packet returns all NAT events in a NetFlow version 9 Packet.
+func FromNetflow9Packet(p *netflow9.Packet) []*Event {
+	var events []*Event
+	for _, ds := range p.DataFlowSets {
+		for i := range ds.Records {
+			if e, ok := FromNetflow9(&ds.Records[i]); ok {
+				events = append(events, e)
+			}
+		}
+	}
+	return events
+}
+
+// set the field identified by the Information Element id, returns true if it
+// was the natEvent.
+func (e *Event) set(id uint16, b []byte) bool {
+	switch id {
+	case ieNATEvent:
+		e.Event = uint8(unsigned(b))
+		return true
+	case ieNATQuotaExceededEvent:
+		e.QuotaExceededEvent = uint32(unsigned(b))
+	case ieNATThresholdEvent:
+		e.ThresholdEvent = uint32(unsigned(b))
+	case ieNATOriginatingAddressRealm:
+		e.Realm = uint8(unsigned(b))
+	case ieNATInstanceID:
+		e.InstanceID = uint32(unsigned(b))
+	case ieIngressVRFID:
+		e.VRFID = uint32(unsigned(b))
+	case ieNATPoolID:
+		e.PoolID = uint32(unsigned(b))
+	case ieNATPoolName:
+		e.PoolName = string(b)
+	case ieObservationTimeSeconds:
+		e.Time = time.Unix(int64(unsigned(b)), 0)
+	case ieObservationTimeMilliseconds:
+		e.Time = time.Unix(0, 0).Add(time.Duration(unsigned(b)) * time.Millisecond)
+	case ieProtocolIdentifier:
+		e.Protocol = uint8(unsigned(b))
+	case ieSourceIPv4Address, ieSourceIPv6Address:
+		e.SourceAddress = net.IP(b)
+	case ieSourceTransportPort:
+		e.SourcePort = uint16(unsigned(b))
+	case ieDestinationIPv4Address, ieDestinationIPv6Address:
+		e.DestinationAddress = net.IP(b)
+	case ieDestinationTransportPort:
+		e.DestinationPort = uint16(unsigned(b))
+	case iePostNATSourceIPv4Address, iePostNATSourceIPv6Address:
+		e.PostSourceAddress = net.IP(b)
+	case iePostNAPTSourceTransportPort:
+		e.PostSourcePort = uint16(unsigned(b))
+	case iePostNATDestinationIPv4Address, iePostNATDestinationIPv6Address:
+		e.PostDestinationAddress = net.IP(b)
+	case iePostNAPTDestinationTransportPort:
+		e.PostDestinationPort = uint16(unsigned(b))
+	case iePortRangeStart:
+		e.PortRangeStart = uint16(unsigned(b))
+	case iePortRangeEnd:
+		e.PortRangeEnd = uint16(unsigned(b))
+	case iePortRangeStepSize:
+		e.PortRangeStepSize = uint16(unsigned(b))
+	case iePortRangeNumPorts:
+		e.PortRangeNumPorts = uint16(unsigned(b))
+	}
+	return false
+}
+
+// EventName returns the name of the NAT event, for quota exceeded and
+// threshold reached events the name includes the limit that was hit.
+func (e *Event) EventName() string {
+	name, ok := translate.Enum(translate.Key{EnterpriseID: 0, FieldID: ieNATEvent}, uint64(e.Event))
+	if !ok {
+		return fmt.Sprintf("NAT event %d", e.Event)
+	}
+	switch e.Event {
+	case EventQuotaExceeded:
+		if limit, ok := translate.Enum(translate.Key{EnterpriseID: 0, FieldID: ieNATQuotaExceededEvent}, uint64(e.QuotaExceededEvent)); ok {
+			name += " (" + limit + ")"
+		}
+	case EventThresholdReached:
+		if limit, ok := translate.Enum(translate.Key{EnterpriseID: 0, FieldID: ieNATThresholdEvent}, uint64(e.ThresholdEvent)); ok {
+			name += " (" + limit + ")"
+		}
+	}
+	return name
+}
+
+// HasPortBlock checks if the event contains a port block.
+func (e *Event) HasPortBlock() bool {
+	return e.PortRangeStart != 0 || e.PortRangeEnd != 0 || e.PortRangeNumPorts != 0
+}
+
+// Binding returns a description of the NAT binding.
+func (e *Event) Binding() string {
+	s := fmt.Sprintf("%s -> %s",
+		endpoint(e.SourceAddress, e.SourcePort),
+		endpoint(e.PostSourceAddress, e.PostSourcePort))
+	if e.DestinationAddress != nil {
+		s += fmt.Sprintf(" to %s", endpoint(e.DestinationAddress, e.DestinationPort))
+		if e.PostDestinationAddress != nil {
+			s += fmt.Sprintf(" (%s)", endpoint(e.PostDestinationAddress, e.PostDestinationPort))
+		}
+	}
+	if e.HasPortBlock() {
+		s += fmt.Sprintf(" ports %d-%d", e.PortRangeStart, e.PortRangeEnd)
+		if e.PortRangeStepSize > 1 {
+			s += fmt.Sprintf(" step %d", e.PortRangeStepSize)
+		}
+		if e.PortRangeNumPorts > 0 {
+			s += fmt.Sprintf(" (%d ports)", e.PortRangeNumPorts)
+		}
+	}
+	return s
+}
+
+func (e *Event) String() string {
+	return fmt.Sprintf("%s: %s", e.EventName(), e.Binding())
+}
+
+func endpoint(ip net.IP, port uint16) string {
+	if port == 0 {
+		return fmt.Sprintf("%s", ip)
+	}
+	return net.JoinHostPort(ip.String(), fmt.Sprintf("%d", port))
+}
+
+// unsigned decodes an unsigned integer, invalid lengths decode as zero.
+func unsigned(b []byte) uint64 {
+	v, _ := translate.Unsigned(b)
+	return v
+}
@@ -0,0 +1,162 @@
+package nat
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/session"
+)
+
+var (
+	testNAT44Fields = []uint16{
+		ieNATEvent, ieObservationTimeMilliseconds, ieIngressVRFID, ieProtocolIdentifier,
+		ieSourceIPv4Address, ieSourceTransportPort, ieDestinationIPv4Address, ieDestinationTransportPort,
+		iePostNATSourceIPv4Address, iePostNAPTSourceTransportPort,
+	}
+	testNAT44Values = []interface{}{
+		EventNAT44SessionCreate, time.Unix(1600000000, 500000000), uint32(3), uint8(6),
+		net.IP{10, 0, 0, 1}, uint16(49152), net.IP{192, 0, 2, 1}, uint16(443),
+		net.IP{198, 51, 100, 1}, uint16(1024),
+	}
+	testNAT64Fields = []uint16{
+		ieNATEvent, ieObservationTimeMilliseconds, ieProtocolIdentifier,
+		ieSourceIPv6Address, ieSourceTransportPort, ieDestinationIPv6Address, ieDestinationTransportPort,
+		iePostNATSourceIPv4Address, iePostNAPTSourceTransportPort,
+		iePostNATDestinationIPv4Address, iePostNAPTDestinationTransportPort,
+	}
+	testNAT64Values = []interface{}{
+		EventNAT64SessionDelete, time.Unix(1600000000, 500000000), uint8(17),
+		net.ParseIP("2001:db8::1"), uint16(5353), net.ParseIP("64:ff9b::c000:201"), uint16(53),
+		net.IP{198, 51, 100, 1}, uint16(1025), net.IP{192, 0, 2, 1}, uint16(53),
+	}
+)
+
+// testFieldLength returns the length of the field in the test records.
+func testFieldLength(id uint16) uint16 {
+	switch id {
+	case ieNATEvent, ieProtocolIdentifier:
+		return 1
+	case ieSourceTransportPort, ieDestinationTransportPort, iePostNAPTSourceTransportPort, iePostNAPTDestinationTransportPort:
+		return 2
+	case ieSourceIPv6Address, ieDestinationIPv6Address:
+		return 16
+	case ieObservationTimeMilliseconds:
+		return 8
+	default:
+		return 4
+	}
+}
+
+func testNAT44(t *testing.T, e *Event) {
+	t.Helper()
+	if e.Event != EventNAT44SessionCreate || e.Time.UnixNano() != 1600000000500000000 ||
+		e.VRFID != 3 || e.Protocol != 6 {
+		t.Fatalf("unexpected NAT44 event %+v", e)
+	}
+	if want := "10.0.0.1:49152 -> 198.51.100.1:1024 to 192.0.2.1:443"; e.Binding() != want {
+		t.Fatalf("expected binding %q, got %q", want, e.Binding())
+	}
+}
+
+func testNAT64(t *testing.T, e *Event) {
+	t.Helper()
+	if e.Event != EventNAT64SessionDelete || e.Time.UnixNano() != 1600000000500000000 || e.Protocol != 17 {
+		t.Fatalf("unexpected NAT64 event %+v", e)
+	}
+	if want := "[2001:db8::1]:5353 -> 198.51.100.1:1025 to [64:ff9b::c000:201]:53 (192.0.2.1:53)"; e.Binding() != want {
+		t.Fatalf("expected binding %q, got %q", want, e.Binding())
+	}
+}
+
+func TestFromNetflow9(t *testing.T) {
+	x := netflow9.NewExporter(1, 0)
+	for i, ids := range [][]uint16{testNAT44Fields, testNAT64Fields} {
+		template := &netflow9.TemplateRecord{TemplateID: 256 + uint16(i)}
+		for _, id := range ids {
+			template.Fields = append(template.Fields, netflow9.FieldSpecifier{Type: id, Length: testFieldLength(id)})
+		}
+		if err := x.AddTemplate(template); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := x.AddRecord(256, testNAT44Values...); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.AddRecord(257, testNAT64Values...); err != nil {
+		t.Fatal(err)
+	}
+	packets, err := x.Flush(time.Unix(1600000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := netflow9.NewDecoder(nil, session.New()).Decode(packets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := FromNetflow9Packet(p)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	testNAT44(t, events[0])
+	testNAT64(t, events[1])
+}
+
+func TestFromIPFIX(t *testing.T) {
+	x := ipfix.NewExporter(0)
+	for i, ids := range [][]uint16{testNAT44Fields, testNAT64Fields} {
+		template := &ipfix.TemplateRecord{TemplateID: 256 + uint16(i)}
+		for _, id := range ids {
+			template.Fields = append(template.Fields, ipfix.FieldSpecifier{InformationElementID: id, Length: testFieldLength(id)})
+		}
+		if err := x.AddTemplate(1, template); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := x.AddRecord(1, 256, testNAT44Values...); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.AddRecord(1, 257, testNAT64Values...); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := x.Flush(time.Unix(1600000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ipfix.NewDecoder(nil, session.New()).Decode(messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := FromIPFIXMessage(m)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	testNAT44(t, events[0])
+	testNAT64(t, events[1])
+}
+
+func TestFromIPFIXWithoutEvent(t *testing.T) {
+	dr := &ipfix.DataRecord{Fields: ipfix.Fields{{Bytes: []byte{10, 0, 0, 1}}}}
+	if _, ok := FromIPFIX(dr); ok {
+		t.Fatal("expected record without NAT event not to be a NAT event")
+	}
+}
+
+func TestEventPortBlock(t *testing.T) {
+	e := &Event{
+		Event:             EventPortBlockAllocation,
+		SourceAddress:     net.IP{10, 0, 0, 1},
+		PostSourceAddress: net.IP{198, 51, 100, 1},
+		PortRangeStart:    1024,
+		PortRangeEnd:      2047,
+		PortRangeStepSize: 1,
+		PortRangeNumPorts: 1024,
+	}
+	if want := "10.0.0.1 -> 198.51.100.1 ports 1024-2047 (1024 ports)"; e.Binding() != want {
+		t.Fatalf("expected binding %q, got %q", want, e.Binding())
+	}
+}
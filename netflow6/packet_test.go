@@ -0,0 +1,124 @@
+package netflow6
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testPacket() *Packet {
+	return &Packet{
+		Header: PacketHeader{
+			Version:          Version,
+			Count:            2,
+			SysUptime:        123456 * time.Millisecond,
+			Unix:             time.Unix(1500000000, 42),
+			FlowSequence:     1000,
+			EngineType:       1,
+			EngineID:         2,
+			SamplingInterval: 0x4064,
+		},
+		Records: []*FlowRecord{
+			{
+				SrcAddr:  net.IP{10, 0, 0, 1},
+				DstAddr:  net.IP{192, 0, 2, 2},
+				NextHop:  net.IP{10, 0, 0, 254},
+				Input:    1,
+				Output:   3,
+				Packets:  10,
+				Bytes:    1500,
+				First:    1000,
+				Last:     2000,
+				SrcPort:  50001,
+				DstPort:  443,
+				Protocol: 6,
+				ToS:      0x10,
+				TCPFlags: 0x1b,
+				SrcAS:    64501,
+				DstAS:    64496,
+				SrcMask:  24,
+				DstMask:  16,
+			},
+			{
+				SrcAddr:  net.IP{10, 0, 0, 2},
+				DstAddr:  net.IP{192, 0, 2, 3},
+				NextHop:  net.IP{10, 0, 0, 254},
+				Input:    2,
+				Output:   4,
+				Packets:  20,
+				Bytes:    3000,
+				First:    2000,
+				Last:     4000,
+				SrcPort:  50002,
+				DstPort:  443,
+				Protocol: 6,
+				ToS:      0x10,
+				TCPFlags: 0x1b,
+				SrcAS:    64502,
+				DstAS:    64496,
+				SrcMask:  24,
+				DstMask:  16,
+			},
+		},
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	p := testPacket()
+	data, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 128 {
+		t.Fatalf("expected 128 bytes, got %d", len(data))
+	}
+
+	q, err := Read(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, q) {
+		t.Fatalf("expected %+v, got %+v", p, q)
+	}
+
+	again, err := q.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Fatalf("expected %x, got %x", data, again)
+	}
+}
+
+func TestPacketUnmarshalMarshal(t *testing.T) {
+	data := []byte{
+		0x00, 0x06, 0x00, 0x01, 0x00, 0x36, 0xee, 0x80, 0x59, 0x68, 0x2f, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x4d, 0x00, 0x00, 0x00, 0x00,
+		0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00, 0xb4,
+		0x00, 0x00, 0x00, 0x64, 0x00, 0x00, 0x00, 0xc8, 0x04, 0x00, 0x00, 0x35,
+		0x00, 0x00, 0x11, 0x00, 0x00, 0x00, 0x00, 0x00, 0x18, 0x18, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+	}
+
+	p, err := Read(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, out) {
+		t.Fatalf("expected %x, got %x", data, out)
+	}
+}
+
+func TestPacketMarshalNoRecords(t *testing.T) {
+	p := &Packet{}
+	if _, err := p.MarshalBinary(); err == nil {
+		t.Fatal("expected error for packet without records")
+	}
+}
@@ -1,6 +1,7 @@
 package netflow7
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
 	"net"
@@ -34,6 +35,22 @@ func (p *Packet) Unmarshal(r io.Reader) error {
 	return nil
 }
 
+// MarshalBinary encodes the Packet in its wire format. The Packet Header
+// version and count are updated to reflect the encoded records.
+func (p *Packet) MarshalBinary() ([]byte, error) {
+	if len(p.Records) < 1 || len(p.Records) > 32 {
+		return nil, fmt.Errorf("protocol error: %d flows out of bounds", len(p.Records))
+	}
+	p.Header.Version = Version
+	p.Header.Count = uint16(len(p.Records))
+
+	data := p.Header.Marshal()
+	for _, r := range p.Records {
+		data = append(data, r.Marshal()...)
+	}
+	return data, nil
+}
+
 // PacketHeader is a NetFlow v1 packet
 type PacketHeader struct {
 	Version      uint16
@@ -79,6 +96,19 @@ func (h *PacketHeader) Unmarshal(r io.Reader) error {
 	return nil
 }
 
+// Marshal encodes the Packet Header in its wire format.
+func (h PacketHeader) Marshal() []byte {
+	b := make([]byte, 24)
+	binary.BigEndian.PutUint16(b[0:], h.Version)
+	binary.BigEndian.PutUint16(b[2:], h.Count)
+	binary.BigEndian.PutUint32(b[4:], uint32(h.SysUptime/time.Millisecond))
+	binary.BigEndian.PutUint32(b[8:], uint32(h.Unix.Unix()))
+	binary.BigEndian.PutUint32(b[12:], uint32(h.Unix.Nanosecond()))
+	binary.BigEndian.PutUint32(b[16:], h.FlowSequence)
+	binary.BigEndian.PutUint32(b[20:], h.Reserved)
+	return b
+}
+
 // FlowRecord is a NetFlow v1 Flow Record
 type FlowRecord struct {
 	// SrcAddr is the Source IP address
@@ -201,6 +231,41 @@ func (r *FlowRecord) Unmarshal(h io.Reader) error {
 	return nil
 }
 
+// Marshal encodes the Flow Record in its wire format.
+func (r FlowRecord) Marshal() []byte {
+	b := make([]byte, 52)
+	marshalIPv4(b[0:], r.SrcAddr)
+	marshalIPv4(b[4:], r.DstAddr)
+	marshalIPv4(b[8:], r.NextHop)
+	binary.BigEndian.PutUint16(b[12:], r.Input)
+	binary.BigEndian.PutUint16(b[14:], r.Output)
+	binary.BigEndian.PutUint32(b[16:], r.Packets)
+	binary.BigEndian.PutUint32(b[20:], r.Bytes)
+	binary.BigEndian.PutUint32(b[24:], r.First)
+	binary.BigEndian.PutUint32(b[28:], r.Last)
+	binary.BigEndian.PutUint16(b[32:], r.SrcPort)
+	binary.BigEndian.PutUint16(b[34:], r.DstPort)
+	b[36] = r.Pad1
+	b[37] = r.TCPFlags
+	b[38] = r.Protocol
+	b[39] = r.ToS
+	binary.BigEndian.PutUint16(b[40:], r.SrcAS)
+	binary.BigEndian.PutUint16(b[42:], r.DstAS)
+	b[44] = r.SrcMask
+	b[45] = r.DstMask
+	binary.BigEndian.PutUint16(b[46:], r.Flags)
+	marshalIPv4(b[48:], r.RouterSC)
+	return b
+}
+
 func (f FlowRecord) SampleInterval() int {
 	return 1
 }
+
+// marshalIPv4 encodes an IPv4 address, addresses that are not IPv4 are encoded
+// as 0.0.0.0.
+func marshalIPv4(b []byte, ip net.IP) {
+	if ip4 := ip.To4(); ip4 != nil {
+		copy(b, ip4)
+	}
+}
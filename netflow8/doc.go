@@ -0,0 +1,11 @@
+/*
+Package netflow8 contains decoders for the NetFlow version 8 protocol.
+
+About
+
+The Version 8 (V8) format adds router-based aggregation schemes, which enable
+the router to summarize NetFlow data and export only the aggregated records.
+The aggregation scheme used is indicated in the packet header, every packet
+contains records of a single aggregation scheme.
+*/
+package netflow8
@@ -0,0 +1,163 @@
+package netflow8
+
+import (
+	"fmt"
+
+	"github.com/tehmaze/netflow/read"
+)
+
+func Dump(p *Packet) {
+	fmt.Println("NetFlow version 8 packet", p.Header)
+	fmt.Printf("  %d %s flow records:\n", len(p.Records), AggregationNames[p.Header.Aggregation])
+	for i, r := range p.Records {
+		fmt.Printf("    record %d:\n", i)
+		switch r := r.(type) {
+		case *ASRecord:
+			dumpCounters(r.Counters)
+			fmt.Println("      srcAs:   ", r.SrcAS)
+			fmt.Println("      dstAs:   ", r.DstAS)
+			fmt.Println("      input:   ", r.Input)
+			fmt.Println("      output:  ", r.Output)
+
+		case *ProtocolPortRecord:
+			dumpCounters(r.Counters)
+			fmt.Println("      protocol:", r.Protocol, read.Protocol(r.Protocol))
+			fmt.Println("      srcPort: ", r.SrcPort)
+			fmt.Println("      dstPort: ", r.DstPort)
+
+		case *SourcePrefixRecord:
+			dumpCounters(r.Counters)
+			fmt.Println("      srcAddr: ", r.SrcPrefix)
+			fmt.Println("      srcMask: ", r.SrcMask)
+			fmt.Println("      srcAs:   ", r.SrcAS)
+			fmt.Println("      input:   ", r.Input)
+
+		case *DestinationPrefixRecord:
+			dumpCounters(r.Counters)
+			fmt.Println("      dstAddr: ", r.DstPrefix)
+			fmt.Println("      dstMask: ", r.DstMask)
+			fmt.Println("      dstAs:   ", r.DstAS)
+			fmt.Println("      output:  ", r.Output)
+
+		case *PrefixRecord:
+			dumpCounters(r.Counters)
+			fmt.Println("      srcAddr: ", r.SrcPrefix)
+			fmt.Println("      srcMask: ", r.SrcMask)
+			fmt.Println("      dstAddr: ", r.DstPrefix)
+			fmt.Println("      dstMask: ", r.DstMask)
+			fmt.Println("      srcAs:   ", r.SrcAS)
+			fmt.Println("      dstAs:   ", r.DstAS)
+			fmt.Println("      input:   ", r.Input)
+			fmt.Println("      output:  ", r.Output)
+
+		case *DestinationOnlyRecord:
+			fmt.Println("      dstAddr: ", r.DstAddr)
+			fmt.Println("      bytes:   ", r.Bytes)
+			fmt.Println("      packets: ", r.Packets)
+			fmt.Println("      first:   ", r.First)
+			fmt.Println("      last:    ", r.Last)
+			fmt.Println("      output:  ", r.Output)
+			fmt.Println("      tos:     ", r.ToS)
+			fmt.Println("      markedTos:", r.MarkedToS)
+			fmt.Println("      extraPkts:", r.ExtraPackets)
+			fmt.Println("      routerSC:", r.RouterSC)
+
+		case *SourceDestinationRecord:
+			fmt.Println("      srcAddr: ", r.SrcAddr)
+			fmt.Println("      dstAddr: ", r.DstAddr)
+			fmt.Println("      bytes:   ", r.Bytes)
+			fmt.Println("      packets: ", r.Packets)
+			fmt.Println("      first:   ", r.First)
+			fmt.Println("      last:    ", r.Last)
+			fmt.Println("      input:   ", r.Input)
+			fmt.Println("      output:  ", r.Output)
+			fmt.Println("      tos:     ", r.ToS)
+			fmt.Println("      markedTos:", r.MarkedToS)
+			fmt.Println("      extraPkts:", r.ExtraPackets)
+			fmt.Println("      routerSC:", r.RouterSC)
+
+		case *FullFlowRecord:
+			fmt.Println("      srcAddr: ", r.SrcAddr)
+			fmt.Println("      srcPort: ", r.SrcPort)
+			fmt.Println("      dstAddr: ", r.DstAddr)
+			fmt.Println("      dstPort: ", r.DstPort)
+			fmt.Println("      bytes:   ", r.Bytes)
+			fmt.Println("      packets: ", r.Packets)
+			fmt.Println("      first:   ", r.First)
+			fmt.Println("      last:    ", r.Last)
+			fmt.Println("      input:   ", r.Input)
+			fmt.Println("      output:  ", r.Output)
+			fmt.Println("      protocol:", r.Protocol, read.Protocol(r.Protocol))
+			fmt.Println("      tos:     ", r.ToS)
+			fmt.Println("      markedTos:", r.MarkedToS)
+			fmt.Println("      extraPkts:", r.ExtraPackets)
+			fmt.Println("      routerSC:", r.RouterSC)
+
+		case *ToSASRecord:
+			dumpCounters(r.Counters)
+			fmt.Println("      srcAs:   ", r.SrcAS)
+			fmt.Println("      dstAs:   ", r.DstAS)
+			fmt.Println("      input:   ", r.Input)
+			fmt.Println("      output:  ", r.Output)
+			fmt.Println("      tos:     ", r.ToS)
+
+		case *ToSProtocolPortRecord:
+			dumpCounters(r.Counters)
+			fmt.Println("      protocol:", r.Protocol, read.Protocol(r.Protocol))
+			fmt.Println("      srcPort: ", r.SrcPort)
+			fmt.Println("      dstPort: ", r.DstPort)
+			fmt.Println("      input:   ", r.Input)
+			fmt.Println("      output:  ", r.Output)
+			fmt.Println("      tos:     ", r.ToS)
+
+		case *ToSSourcePrefixRecord:
+			dumpCounters(r.Counters)
+			fmt.Println("      srcAddr: ", r.SrcPrefix)
+			fmt.Println("      srcMask: ", r.SrcMask)
+			fmt.Println("      srcAs:   ", r.SrcAS)
+			fmt.Println("      input:   ", r.Input)
+			fmt.Println("      tos:     ", r.ToS)
+
+		case *ToSDestinationPrefixRecord:
+			dumpCounters(r.Counters)
+			fmt.Println("      dstAddr: ", r.DstPrefix)
+			fmt.Println("      dstMask: ", r.DstMask)
+			fmt.Println("      dstAs:   ", r.DstAS)
+			fmt.Println("      output:  ", r.Output)
+			fmt.Println("      tos:     ", r.ToS)
+
+		case *ToSPrefixRecord:
+			dumpCounters(r.Counters)
+			fmt.Println("      srcAddr: ", r.SrcPrefix)
+			fmt.Println("      srcMask: ", r.SrcMask)
+			fmt.Println("      dstAddr: ", r.DstPrefix)
+			fmt.Println("      dstMask: ", r.DstMask)
+			fmt.Println("      srcAs:   ", r.SrcAS)
+			fmt.Println("      dstAs:   ", r.DstAS)
+			fmt.Println("      input:   ", r.Input)
+			fmt.Println("      output:  ", r.Output)
+			fmt.Println("      tos:     ", r.ToS)
+
+		case *PrefixPortRecord:
+			dumpCounters(r.Counters)
+			fmt.Println("      srcAddr: ", r.SrcPrefix)
+			fmt.Println("      srcMask: ", r.SrcMask)
+			fmt.Println("      srcPort: ", r.SrcPort)
+			fmt.Println("      dstAddr: ", r.DstPrefix)
+			fmt.Println("      dstMask: ", r.DstMask)
+			fmt.Println("      dstPort: ", r.DstPort)
+			fmt.Println("      input:   ", r.Input)
+			fmt.Println("      output:  ", r.Output)
+			fmt.Println("      protocol:", r.Protocol, read.Protocol(r.Protocol))
+			fmt.Println("      tos:     ", r.ToS)
+		}
+	}
+}
+
+func dumpCounters(c Counters) {
+	fmt.Println("      flows:   ", c.Flows)
+	fmt.Println("      bytes:   ", c.Bytes)
+	fmt.Println("      packets: ", c.Packets)
+	fmt.Println("      first:   ", c.First)
+	fmt.Println("      last:    ", c.Last)
+}
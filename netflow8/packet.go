@@ -0,0 +1,1010 @@
+package netflow8
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/tehmaze/netflow/read"
+)
+
+const (
+	// Version word in the Packet Header
+	Version uint16 = 0x0008
+)
+
+// Aggregation schemes, as found in the Aggregation field of the Packet Header.
+const (
+	AggregationAS uint8 = iota + 1
+	AggregationProtocolPort
+	AggregationSourcePrefix
+	AggregationDestinationPrefix
+	AggregationPrefix
+	AggregationDestinationOnly
+	AggregationSourceDestination
+	AggregationFullFlow
+	AggregationToSAS
+	AggregationToSProtocolPort
+	AggregationToSSourcePrefix
+	AggregationToSDestinationPrefix
+	AggregationToSPrefix
+	AggregationPrefixPort
+)
+
+// AggregationNames maps the aggregation schemes to their name.
+var AggregationNames = map[uint8]string{
+	AggregationAS:                   "AS",
+	AggregationProtocolPort:         "Protocol-Port",
+	AggregationSourcePrefix:         "Source-Prefix",
+	AggregationDestinationPrefix:    "Destination-Prefix",
+	AggregationPrefix:               "Prefix",
+	AggregationDestinationOnly:      "Destination-Only",
+	AggregationSourceDestination:    "Source-Destination",
+	AggregationFullFlow:             "Full-Flow",
+	AggregationToSAS:                "ToS-AS",
+	AggregationToSProtocolPort:      "ToS-Protocol-Port",
+	AggregationToSSourcePrefix:      "ToS-Source-Prefix",
+	AggregationToSDestinationPrefix: "ToS-Destination-Prefix",
+	AggregationToSPrefix:            "ToS-Prefix",
+	AggregationPrefixPort:           "Prefix-Port",
+}
+
+// Packet is a NetFlow v8 packet
+type Packet struct {
+	Header  PacketHeader
+	Records []FlowRecord
+}
+
+func (p *Packet) Unmarshal(r io.Reader) error {
+	if err := p.Header.Unmarshal(r); err != nil {
+		return err
+	}
+	p.Records = make([]FlowRecord, p.Header.Count)
+	for i := range p.Records {
+		var err error
+		if p.Records[i], err = NewFlowRecord(p.Header.Aggregation); err != nil {
+			return err
+		}
+		if err = p.Records[i].Unmarshal(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PacketHeader is a NetFlow v8 packet
+type PacketHeader struct {
+	Version            uint16
+	Count              uint16
+	SysUptime          time.Duration // 32 bit milliseconds
+	Unix               time.Time     // 32 bit seconds + 32 bit nanoseconds
+	FlowSequence       uint32
+	EngineType         uint8
+	EngineID           uint8
+	Aggregation        uint8
+	AggregationVersion uint8
+	Reserved           uint32
+}
+
+func (h PacketHeader) String() string {
+	return fmt.Sprintf("v=%d, count=%d, uptime=%s, time=%s, seq=%d, type=%d, id=%d, aggregation=%s, aggregation version=%d",
+		h.Version, h.Count, h.SysUptime, h.Unix, h.FlowSequence, h.EngineType, h.EngineID, AggregationNames[h.Aggregation], h.AggregationVersion)
+}
+
+func (h *PacketHeader) Unmarshal(r io.Reader) error {
+	if err := read.Uint16(&h.Version, r); err != nil {
+		return err
+	}
+	if err := read.Uint16(&h.Count, r); err != nil {
+		return err
+	}
+	// The smallest aggregation records (28 bytes) fit 51 times in a packet.
+	if h.Count < 1 || h.Count > 51 {
+		return fmt.Errorf("protocol error: %d flows out of bounds", h.Count)
+	}
+	var u uint32
+	if err := read.Uint32(&u, r); err != nil {
+		return err
+	}
+	h.SysUptime = time.Duration(u) * time.Millisecond
+	var t uint64
+	if err := read.Uint64(&t, r); err != nil {
+		return err
+	}
+	h.Unix = time.Unix(int64(t>>32), int64(t&0xffffffff))
+	if err := read.Uint32(&h.FlowSequence, r); err != nil {
+		return err
+	}
+	if err := read.Uint8(&h.EngineType, r); err != nil {
+		return err
+	}
+	if err := read.Uint8(&h.EngineID, r); err != nil {
+		return err
+	}
+	if err := read.Uint8(&h.Aggregation, r); err != nil {
+		return err
+	}
+	if err := read.Uint8(&h.AggregationVersion, r); err != nil {
+		return err
+	}
+	if err := read.Uint32(&h.Reserved, r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// FlowRecord is a NetFlow v8 Flow Record, the layout of the record depends on
+// the aggregation scheme.
+type FlowRecord interface {
+	Unmarshal(io.Reader) error
+	String() string
+}
+
+// NewFlowRecord returns an empty Flow Record for the aggregation scheme.
+func NewFlowRecord(aggregation uint8) (FlowRecord, error) {
+	switch aggregation {
+	case AggregationAS:
+		return new(ASRecord), nil
+	case AggregationProtocolPort:
+		return new(ProtocolPortRecord), nil
+	case AggregationSourcePrefix:
+		return new(SourcePrefixRecord), nil
+	case AggregationDestinationPrefix:
+		return new(DestinationPrefixRecord), nil
+	case AggregationPrefix:
+		return new(PrefixRecord), nil
+	case AggregationDestinationOnly:
+		return new(DestinationOnlyRecord), nil
+	case AggregationSourceDestination:
+		return new(SourceDestinationRecord), nil
+	case AggregationFullFlow:
+		return new(FullFlowRecord), nil
+	case AggregationToSAS:
+		return new(ToSASRecord), nil
+	case AggregationToSProtocolPort:
+		return new(ToSProtocolPortRecord), nil
+	case AggregationToSSourcePrefix:
+		return new(ToSSourcePrefixRecord), nil
+	case AggregationToSDestinationPrefix:
+		return new(ToSDestinationPrefixRecord), nil
+	case AggregationToSPrefix:
+		return new(ToSPrefixRecord), nil
+	case AggregationPrefixPort:
+		return new(PrefixPortRecord), nil
+	default:
+		return nil, fmt.Errorf("protocol error: unsupported aggregation scheme %d", aggregation)
+	}
+}
+
+func readIPv4(v *net.IP, r io.Reader) error {
+	*v = make(net.IP, 4)
+	_, err := io.ReadFull(r, *v)
+	return err
+}
+
+// Counters are the flow counters that lead most of the aggregated records.
+type Counters struct {
+	// Flows is the number of flows that were aggregated
+	Flows uint32 // 0-3
+	// Packets is the number of packets in the aggregated flows
+	Packets uint32 // 4-7
+	// Bytes is the number of bytes in the aggregated flows
+	Bytes uint32 // 8-11
+	// First is the SysUptime at start of the first flow
+	First uint32 // 12-15
+	// Last is the SysUptime at end of the last flow
+	Last uint32 // 16-19
+}
+
+func (c *Counters) Unmarshal(h io.Reader) error {
+	if err := read.Uint32(&c.Flows, h); err != nil { // 0-3
+		return err
+	}
+	if err := read.Uint32(&c.Packets, h); err != nil { // 4-7
+		return err
+	}
+	if err := read.Uint32(&c.Bytes, h); err != nil { // 8-11
+		return err
+	}
+	if err := read.Uint32(&c.First, h); err != nil { // 12-15
+		return err
+	}
+	if err := read.Uint32(&c.Last, h); err != nil { // 16-19
+		return err
+	}
+	return nil
+}
+
+// ASRecord is a Flow Record for the AS aggregation scheme
+type ASRecord struct {
+	Counters
+	// SrcAS is the source Autonomous System Number
+	SrcAS uint16 // 20-21
+	// DstAS is the destination Autonomous System Number
+	DstAS uint16 // 22-23
+	// Input is the SNMP index of input interface
+	Input uint16 // 24-25
+	// Output is the SNMP index of output interface
+	Output uint16 // 26-27
+}
+
+func (r ASRecord) String() string {
+	return fmt.Sprintf("AS%d -> AS%d", r.SrcAS, r.DstAS)
+}
+
+func (r *ASRecord) Unmarshal(h io.Reader) error {
+	if err := r.Counters.Unmarshal(h); err != nil { // 0-19
+		return err
+	}
+	if err := read.Uint16(&r.SrcAS, h); err != nil { // 20-21
+		return err
+	}
+	if err := read.Uint16(&r.DstAS, h); err != nil { // 22-23
+		return err
+	}
+	if err := read.Uint16(&r.Input, h); err != nil { // 24-25
+		return err
+	}
+	if err := read.Uint16(&r.Output, h); err != nil { // 26-27
+		return err
+	}
+	return nil
+}
+
+// ProtocolPortRecord is a Flow Record for the Protocol-Port aggregation scheme
+type ProtocolPortRecord struct {
+	Counters
+	// Protocol number
+	Protocol uint8 // 20
+	// Pad1 are unused bytes
+	Pad1 uint8 // 21
+	// Reserved are reserved (unused) bytes
+	Reserved uint16 // 22-23
+	// SrcPort is the TCP/UDP source port number or equivalent
+	SrcPort uint16 // 24-25
+	// DstPort is the TCP/UDP destination port number or equivalent
+	DstPort uint16 // 26-27
+}
+
+func (r ProtocolPortRecord) String() string {
+	return fmt.Sprintf("%s %d -> %d", read.Protocol(r.Protocol), r.SrcPort, r.DstPort)
+}
+
+func (r *ProtocolPortRecord) Unmarshal(h io.Reader) error {
+	if err := r.Counters.Unmarshal(h); err != nil { // 0-19
+		return err
+	}
+	if err := read.Uint8(&r.Protocol, h); err != nil { // 20
+		return err
+	}
+	if err := read.Uint8(&r.Pad1, h); err != nil { // 21
+		return err
+	}
+	if err := read.Uint16(&r.Reserved, h); err != nil { // 22-23
+		return err
+	}
+	if err := read.Uint16(&r.SrcPort, h); err != nil { // 24-25
+		return err
+	}
+	if err := read.Uint16(&r.DstPort, h); err != nil { // 26-27
+		return err
+	}
+	return nil
+}
+
+// SourcePrefixRecord is a Flow Record for the Source-Prefix aggregation scheme
+type SourcePrefixRecord struct {
+	Counters
+	// SrcPrefix is the source IP prefix
+	SrcPrefix net.IP // 20-23
+	// SrcMask is the source network mask
+	SrcMask uint8 // 24
+	// Pad1 are unused bytes
+	Pad1 uint8 // 25
+	// SrcAS is the source Autonomous System Number
+	SrcAS uint16 // 26-27
+	// Input is the SNMP index of input interface
+	Input uint16 // 28-29
+	// Reserved are reserved (unused) bytes
+	Reserved uint16 // 30-31
+}
+
+func (r SourcePrefixRecord) String() string {
+	return fmt.Sprintf("%s/%d ->", r.SrcPrefix, r.SrcMask)
+}
+
+func (r *SourcePrefixRecord) Unmarshal(h io.Reader) error {
+	if err := r.Counters.Unmarshal(h); err != nil { // 0-19
+		return err
+	}
+	if err := readIPv4(&r.SrcPrefix, h); err != nil { // 20-23
+		return err
+	}
+	if err := read.Uint8(&r.SrcMask, h); err != nil { // 24
+		return err
+	}
+	if err := read.Uint8(&r.Pad1, h); err != nil { // 25
+		return err
+	}
+	if err := read.Uint16(&r.SrcAS, h); err != nil { // 26-27
+		return err
+	}
+	if err := read.Uint16(&r.Input, h); err != nil { // 28-29
+		return err
+	}
+	if err := read.Uint16(&r.Reserved, h); err != nil { // 30-31
+		return err
+	}
+	return nil
+}
+
+// DestinationPrefixRecord is a Flow Record for the Destination-Prefix
+// aggregation scheme
+type DestinationPrefixRecord struct {
+	Counters
+	// DstPrefix is the destination IP prefix
+	DstPrefix net.IP // 20-23
+	// DstMask is the destination network mask
+	DstMask uint8 // 24
+	// Pad1 are unused bytes
+	Pad1 uint8 // 25
+	// DstAS is the destination Autonomous System Number
+	DstAS uint16 // 26-27
+	// Output is the SNMP index of output interface
+	Output uint16 // 28-29
+	// Reserved are reserved (unused) bytes
+	Reserved uint16 // 30-31
+}
+
+func (r DestinationPrefixRecord) String() string {
+	return fmt.Sprintf("-> %s/%d", r.DstPrefix, r.DstMask)
+}
+
+func (r *DestinationPrefixRecord) Unmarshal(h io.Reader) error {
+	if err := r.Counters.Unmarshal(h); err != nil { // 0-19
+		return err
+	}
+	if err := readIPv4(&r.DstPrefix, h); err != nil { // 20-23
+		return err
+	}
+	if err := read.Uint8(&r.DstMask, h); err != nil { // 24
+		return err
+	}
+	if err := read.Uint8(&r.Pad1, h); err != nil { // 25
+		return err
+	}
+	if err := read.Uint16(&r.DstAS, h); err != nil { // 26-27
+		return err
+	}
+	if err := read.Uint16(&r.Output, h); err != nil { // 28-29
+		return err
+	}
+	if err := read.Uint16(&r.Reserved, h); err != nil { // 30-31
+		return err
+	}
+	return nil
+}
+
+// PrefixRecord is a Flow Record for the Prefix aggregation scheme
+type PrefixRecord struct {
+	Counters
+	// SrcPrefix is the source IP prefix
+	SrcPrefix net.IP // 20-23
+	// DstPrefix is the destination IP prefix
+	DstPrefix net.IP // 24-27
+	// DstMask is the destination network mask
+	DstMask uint8 // 28
+	// SrcMask is the source network mask
+	SrcMask uint8 // 29
+	// Reserved are reserved (unused) bytes
+	Reserved uint16 // 30-31
+	// SrcAS is the source Autonomous System Number
+	SrcAS uint16 // 32-33
+	// DstAS is the destination Autonomous System Number
+	DstAS uint16 // 34-35
+	// Input is the SNMP index of input interface
+	Input uint16 // 36-37
+	// Output is the SNMP index of output interface
+	Output uint16 // 38-39
+}
+
+func (r PrefixRecord) String() string {
+	return fmt.Sprintf("%s/%d -> %s/%d", r.SrcPrefix, r.SrcMask, r.DstPrefix, r.DstMask)
+}
+
+func (r *PrefixRecord) Unmarshal(h io.Reader) error {
+	if err := r.Counters.Unmarshal(h); err != nil { // 0-19
+		return err
+	}
+	if err := readIPv4(&r.SrcPrefix, h); err != nil { // 20-23
+		return err
+	}
+	if err := readIPv4(&r.DstPrefix, h); err != nil { // 24-27
+		return err
+	}
+	if err := read.Uint8(&r.DstMask, h); err != nil { // 28
+		return err
+	}
+	if err := read.Uint8(&r.SrcMask, h); err != nil { // 29
+		return err
+	}
+	if err := read.Uint16(&r.Reserved, h); err != nil { // 30-31
+		return err
+	}
+	if err := read.Uint16(&r.SrcAS, h); err != nil { // 32-33
+		return err
+	}
+	if err := read.Uint16(&r.DstAS, h); err != nil { // 34-35
+		return err
+	}
+	if err := read.Uint16(&r.Input, h); err != nil { // 36-37
+		return err
+	}
+	if err := read.Uint16(&r.Output, h); err != nil { // 38-39
+		return err
+	}
+	return nil
+}
+
+// DestinationOnlyRecord is a Flow Record for the Destination-Only aggregation
+// scheme
+type DestinationOnlyRecord struct {
+	// DstAddr is the Destination IP address
+	DstAddr net.IP // 0-3
+	// Packets is the number of packets in the flow
+	Packets uint32 // 4-7
+	// Bytes is the number of bytes in the flow
+	Bytes uint32 // 8-11
+	// First is the SysUptime at start of flow
+	First uint32 // 12-15
+	// Last is the SysUptime at end of flow
+	Last uint32 // 16-19
+	// Output is the SNMP index of output interface
+	Output uint16 // 20-21
+	// ToS is the IP type of service
+	ToS uint8 // 22
+	// MarkedToS is the IP type of service of switched packets
+	MarkedToS uint8 // 23
+	// ExtraPackets is the number of packets that exceeded the contract
+	ExtraPackets uint32 // 24-27
+	// RouterSC is the IP address of the router shortcut by the switch
+	RouterSC net.IP // 28-31
+}
+
+func (r DestinationOnlyRecord) String() string {
+	return fmt.Sprintf("-> %s", r.DstAddr)
+}
+
+func (r *DestinationOnlyRecord) Unmarshal(h io.Reader) error {
+	if err := readIPv4(&r.DstAddr, h); err != nil { // 0-3
+		return err
+	}
+	if err := read.Uint32(&r.Packets, h); err != nil { // 4-7
+		return err
+	}
+	if err := read.Uint32(&r.Bytes, h); err != nil { // 8-11
+		return err
+	}
+	if err := read.Uint32(&r.First, h); err != nil { // 12-15
+		return err
+	}
+	if err := read.Uint32(&r.Last, h); err != nil { // 16-19
+		return err
+	}
+	if err := read.Uint16(&r.Output, h); err != nil { // 20-21
+		return err
+	}
+	if err := read.Uint8(&r.ToS, h); err != nil { // 22
+		return err
+	}
+	if err := read.Uint8(&r.MarkedToS, h); err != nil { // 23
+		return err
+	}
+	if err := read.Uint32(&r.ExtraPackets, h); err != nil { // 24-27
+		return err
+	}
+	if err := readIPv4(&r.RouterSC, h); err != nil { // 28-31
+		return err
+	}
+	return nil
+}
+
+// SourceDestinationRecord is a Flow Record for the Source-Destination
+// aggregation scheme
+type SourceDestinationRecord struct {
+	// DstAddr is the Destination IP address
+	DstAddr net.IP // 0-3
+	// SrcAddr is the Source IP address
+	SrcAddr net.IP // 4-7
+	// Packets is the number of packets in the flow
+	Packets uint32 // 8-11
+	// Bytes is the number of bytes in the flow
+	Bytes uint32 // 12-15
+	// First is the SysUptime at start of flow
+	First uint32 // 16-19
+	// Last is the SysUptime at end of flow
+	Last uint32 // 20-23
+	// Output is the SNMP index of output interface
+	Output uint16 // 24-25
+	// Input is the SNMP index of input interface
+	Input uint16 // 26-27
+	// ToS is the IP type of service
+	ToS uint8 // 28
+	// MarkedToS is the IP type of service of switched packets
+	MarkedToS uint8 // 29
+	// Reserved are reserved (unused) bytes
+	Reserved uint16 // 30-31
+	// ExtraPackets is the number of packets that exceeded the contract
+	ExtraPackets uint32 // 32-35
+	// RouterSC is the IP address of the router shortcut by the switch
+	RouterSC net.IP // 36-39
+}
+
+func (r SourceDestinationRecord) String() string {
+	return fmt.Sprintf("%s -> %s", r.SrcAddr, r.DstAddr)
+}
+
+func (r *SourceDestinationRecord) Unmarshal(h io.Reader) error {
+	if err := readIPv4(&r.DstAddr, h); err != nil { // 0-3
+		return err
+	}
+	if err := readIPv4(&r.SrcAddr, h); err != nil { // 4-7
+		return err
+	}
+	if err := read.Uint32(&r.Packets, h); err != nil { // 8-11
+		return err
+	}
+	if err := read.Uint32(&r.Bytes, h); err != nil { // 12-15
+		return err
+	}
+	if err := read.Uint32(&r.First, h); err != nil { // 16-19
+		return err
+	}
+	if err := read.Uint32(&r.Last, h); err != nil { // 20-23
+		return err
+	}
+	if err := read.Uint16(&r.Output, h); err != nil { // 24-25
+		return err
+	}
+	if err := read.Uint16(&r.Input, h); err != nil { // 26-27
+		return err
+	}
+	if err := read.Uint8(&r.ToS, h); err != nil { // 28
+		return err
+	}
+	if err := read.Uint8(&r.MarkedToS, h); err != nil { // 29
+		return err
+	}
+	if err := read.Uint16(&r.Reserved, h); err != nil { // 30-31
+		return err
+	}
+	if err := read.Uint32(&r.ExtraPackets, h); err != nil { // 32-35
+		return err
+	}
+	if err := readIPv4(&r.RouterSC, h); err != nil { // 36-39
+		return err
+	}
+	return nil
+}
+
+// FullFlowRecord is a Flow Record for the Full-Flow aggregation scheme
+type FullFlowRecord struct {
+	// DstAddr is the Destination IP address
+	DstAddr net.IP // 0-3
+	// SrcAddr is the Source IP address
+	SrcAddr net.IP // 4-7
+	// DstPort is the TCP/UDP destination port number or equivalent
+	DstPort uint16 // 8-9
+	// SrcPort is the TCP/UDP source port number or equivalent
+	SrcPort uint16 // 10-11
+	// Packets is the number of packets in the flow
+	Packets uint32 // 12-15
+	// Bytes is the number of bytes in the flow
+	Bytes uint32 // 16-19
+	// First is the SysUptime at start of flow
+	First uint32 // 20-23
+	// Last is the SysUptime at end of flow
+	Last uint32 // 24-27
+	// Output is the SNMP index of output interface
+	Output uint16 // 28-29
+	// Input is the SNMP index of input interface
+	Input uint16 // 30-31
+	// ToS is the IP type of service
+	ToS uint8 // 32
+	// Protocol number
+	Protocol uint8 // 33
+	// MarkedToS is the IP type of service of switched packets
+	MarkedToS uint8 // 34
+	// Pad1 are unused bytes
+	Pad1 uint8 // 35
+	// ExtraPackets is the number of packets that exceeded the contract
+	ExtraPackets uint32 // 36-39
+	// RouterSC is the IP address of the router shortcut by the switch
+	RouterSC net.IP // 40-43
+}
+
+func (r FullFlowRecord) String() string {
+	return fmt.Sprintf("%s:%d -> %s:%d", r.SrcAddr, r.SrcPort, r.DstAddr, r.DstPort)
+}
+
+func (r *FullFlowRecord) Unmarshal(h io.Reader) error {
+	if err := readIPv4(&r.DstAddr, h); err != nil { // 0-3
+		return err
+	}
+	if err := readIPv4(&r.SrcAddr, h); err != nil { // 4-7
+		return err
+	}
+	if err := read.Uint16(&r.DstPort, h); err != nil { // 8-9
+		return err
+	}
+	if err := read.Uint16(&r.SrcPort, h); err != nil { // 10-11
+		return err
+	}
+	if err := read.Uint32(&r.Packets, h); err != nil { // 12-15
+		return err
+	}
+	if err := read.Uint32(&r.Bytes, h); err != nil { // 16-19
+		return err
+	}
+	if err := read.Uint32(&r.First, h); err != nil { // 20-23
+		return err
+	}
+	if err := read.Uint32(&r.Last, h); err != nil { // 24-27
+		return err
+	}
+	if err := read.Uint16(&r.Output, h); err != nil { // 28-29
+		return err
+	}
+	if err := read.Uint16(&r.Input, h); err != nil { // 30-31
+		return err
+	}
+	if err := read.Uint8(&r.ToS, h); err != nil { // 32
+		return err
+	}
+	if err := read.Uint8(&r.Protocol, h); err != nil { // 33
+		return err
+	}
+	if err := read.Uint8(&r.MarkedToS, h); err != nil { // 34
+		return err
+	}
+	if err := read.Uint8(&r.Pad1, h); err != nil { // 35
+		return err
+	}
+	if err := read.Uint32(&r.ExtraPackets, h); err != nil { // 36-39
+		return err
+	}
+	if err := readIPv4(&r.RouterSC, h); err != nil { // 40-43
+		return err
+	}
+	return nil
+}
+
+// ToSASRecord is a Flow Record for the ToS-AS aggregation scheme
+type ToSASRecord struct {
+	Counters
+	// SrcAS is the source Autonomous System Number
+	SrcAS uint16 // 20-21
+	// DstAS is the destination Autonomous System Number
+	DstAS uint16 // 22-23
+	// Input is the SNMP index of input interface
+	Input uint16 // 24-25
+	// Output is the SNMP index of output interface
+	Output uint16 // 26-27
+	// ToS is the IP type of service
+	ToS uint8 // 28
+	// Pad1 are unused bytes
+	Pad1 uint8 // 29
+	// Reserved are reserved (unused) bytes
+	Reserved uint16 // 30-31
+}
+
+func (r ToSASRecord) String() string {
+	return fmt.Sprintf("AS%d -> AS%d tos %d", r.SrcAS, r.DstAS, r.ToS)
+}
+
+func (r *ToSASRecord) Unmarshal(h io.Reader) error {
+	if err := r.Counters.Unmarshal(h); err != nil { // 0-19
+		return err
+	}
+	if err := read.Uint16(&r.SrcAS, h); err != nil { // 20-21
+		return err
+	}
+	if err := read.Uint16(&r.DstAS, h); err != nil { // 22-23
+		return err
+	}
+	if err := read.Uint16(&r.Input, h); err != nil { // 24-25
+		return err
+	}
+	if err := read.Uint16(&r.Output, h); err != nil { // 26-27
+		return err
+	}
+	if err := read.Uint8(&r.ToS, h); err != nil { // 28
+		return err
+	}
+	if err := read.Uint8(&r.Pad1, h); err != nil { // 29
+		return err
+	}
+	if err := read.Uint16(&r.Reserved, h); err != nil { // 30-31
+		return err
+	}
+	return nil
+}
+
+// ToSProtocolPortRecord is a Flow Record for the ToS-Protocol-Port aggregation
+// scheme
+type ToSProtocolPortRecord struct {
+	Counters
+	// Protocol number
+	Protocol uint8 // 20
+	// ToS is the IP type of service
+	ToS uint8 // 21
+	// Reserved are reserved (unused) bytes
+	Reserved uint16 // 22-23
+	// SrcPort is the TCP/UDP source port number or equivalent
+	SrcPort uint16 // 24-25
+	// DstPort is the TCP/UDP destination port number or equivalent
+	DstPort uint16 // 26-27
+	// Input is the SNMP index of input interface
+	Input uint16 // 28-29
+	// Output is the SNMP index of output interface
+	Output uint16 // 30-31
+}
+
+func (r ToSProtocolPortRecord) String() string {
+	return fmt.Sprintf("%s %d -> %d tos %d", read.Protocol(r.Protocol), r.SrcPort, r.DstPort, r.ToS)
+}
+
+func (r *ToSProtocolPortRecord) Unmarshal(h io.Reader) error {
+	if err := r.Counters.Unmarshal(h); err != nil { // 0-19
+		return err
+	}
+	if err := read.Uint8(&r.Protocol, h); err != nil { // 20
+		return err
+	}
+	if err := read.Uint8(&r.ToS, h); err != nil { // 21
+		return err
+	}
+	if err := read.Uint16(&r.Reserved, h); err != nil { // 22-23
+		return err
+	}
+	if err := read.Uint16(&r.SrcPort, h); err != nil { // 24-25
+		return err
+	}
+	if err := read.Uint16(&r.DstPort, h); err != nil { // 26-27
+		return err
+	}
+	if err := read.Uint16(&r.Input, h); err != nil { // 28-29
+		return err
+	}
+	if err := read.Uint16(&r.Output, h); err != nil { // 30-31
+		return err
+	}
+	return nil
+}
+
+// ToSSourcePrefixRecord is a Flow Record for the ToS-Source-Prefix aggregation
+// scheme
+type ToSSourcePrefixRecord struct {
+	Counters
+	// SrcPrefix is the source IP prefix
+	SrcPrefix net.IP // 20-23
+	// SrcMask is the source network mask
+	SrcMask uint8 // 24
+	// ToS is the IP type of service
+	ToS uint8 // 25
+	// SrcAS is the source Autonomous System Number
+	SrcAS uint16 // 26-27
+	// Input is the SNMP index of input interface
+	Input uint16 // 28-29
+	// Reserved are reserved (unused) bytes
+	Reserved uint16 // 30-31
+}
+
+func (r ToSSourcePrefixRecord) String() string {
+	return fmt.Sprintf("%s/%d -> tos %d", r.SrcPrefix, r.SrcMask, r.ToS)
+}
+
+func (r *ToSSourcePrefixRecord) Unmarshal(h io.Reader) error {
+	if err := r.Counters.Unmarshal(h); err != nil { // 0-19
+		return err
+	}
+	if err := readIPv4(&r.SrcPrefix, h); err != nil { // 20-23
+		return err
+	}
+	if err := read.Uint8(&r.SrcMask, h); err != nil { // 24
+		return err
+	}
+	if err := read.Uint8(&r.ToS, h); err != nil { // 25
+		return err
+	}
+	if err := read.Uint16(&r.SrcAS, h); err != nil { // 26-27
+		return err
+	}
+	if err := read.Uint16(&r.Input, h); err != nil { // 28-29
+		return err
+	}
+	if err := read.Uint16(&r.Reserved, h); err != nil { // 30-31
+		return err
+	}
+	return nil
+}
+
+// ToSDestinationPrefixRecord is a Flow Record for the ToS-Destination-Prefix
+// aggregation scheme
+type ToSDestinationPrefixRecord struct {
+	Counters
+	// DstPrefix is the destination IP prefix
+	DstPrefix net.IP // 20-23
+	// DstMask is the destination network mask
+	DstMask uint8 // 24
+	// ToS is the IP type of service
+	ToS uint8 // 25
+	// DstAS is the destination Autonomous System Number
+	DstAS uint16 // 26-27
+	// Output is the SNMP index of output interface
+	Output uint16 // 28-29
+	// Reserved are reserved (unused) bytes
+	Reserved uint16 // 30-31
+}
+
+func (r ToSDestinationPrefixRecord) String() string {
+	return fmt.Sprintf("-> %s/%d tos %d", r.DstPrefix, r.DstMask, r.ToS)
+}
+
+func (r *ToSDestinationPrefixRecord) Unmarshal(h io.Reader) error {
+	if err := r.Counters.Unmarshal(h); err != nil { // 0-19
+		return err
+	}
+	if err := readIPv4(&r.DstPrefix, h); err != nil { // 20-23
+		return err
+	}
+	if err := read.Uint8(&r.DstMask, h); err != nil { // 24
+		return err
+	}
+	if err := read.Uint8(&r.ToS, h); err != nil { // 25
+		return err
+	}
+	if err := read.Uint16(&r.DstAS, h); err != nil { // 26-27
+		return err
+	}
+	if err := read.Uint16(&r.Output, h); err != nil { // 28-29
+		return err
+	}
+	if err := read.Uint16(&r.Reserved, h); err != nil { // 30-31
+		return err
+	}
+	return nil
+}
+
+// ToSPrefixRecord is a Flow Record for the ToS-Prefix aggregation scheme
+type ToSPrefixRecord struct {
+	Counters
+	// SrcPrefix is the source IP prefix
+	SrcPrefix net.IP // 20-23
+	// DstPrefix is the destination IP prefix
+	DstPrefix net.IP // 24-27
+	// DstMask is the destination network mask
+	DstMask uint8 // 28
+	// SrcMask is the source network mask
+	SrcMask uint8 // 29
+	// ToS is the IP type of service
+	ToS uint8 // 30
+	// Pad1 are unused bytes
+	Pad1 uint8 // 31
+	// SrcAS is the source Autonomous System Number
+	SrcAS uint16 // 32-33
+	// DstAS is the destination Autonomous System Number
+	DstAS uint16 // 34-35
+	// Input is the SNMP index of input interface
+	Input uint16 // 36-37
+	// Output is the SNMP index of output interface
+	Output uint16 // 38-39
+}
+
+func (r ToSPrefixRecord) String() string {
+	return fmt.Sprintf("%s/%d -> %s/%d tos %d", r.SrcPrefix, r.SrcMask, r.DstPrefix, r.DstMask, r.ToS)
+}
+
+func (r *ToSPrefixRecord) Unmarshal(h io.Reader) error {
+	if err := r.Counters.Unmarshal(h); err != nil { // 0-19
+		return err
+	}
+	if err := readIPv4(&r.SrcPrefix, h); err != nil { // 20-23
+		return err
+	}
+	if err := readIPv4(&r.DstPrefix, h); err != nil { // 24-27
+		return err
+	}
+	if err := read.Uint8(&r.DstMask, h); err != nil { // 28
+		return err
+	}
+	if err := read.Uint8(&r.SrcMask, h); err != nil { // 29
+		return err
+	}
+	if err := read.Uint8(&r.ToS, h); err != nil { // 30
+		return err
+	}
+	if err := read.Uint8(&r.Pad1, h); err != nil { // 31
+		return err
+	}
+	if err := read.Uint16(&r.SrcAS, h); err != nil { // 32-33
+		return err
+	}
+	if err := read.Uint16(&r.DstAS, h); err != nil { // 34-35
+		return err
+	}
+	if err := read.Uint16(&r.Input, h); err != nil { // 36-37
+		return err
+	}
+	if err := read.Uint16(&r.Output, h); err != nil { // 38-39
+		return err
+	}
+	return nil
+}
+
+// PrefixPortRecord is a Flow Record for the Prefix-Port aggregation scheme
+type PrefixPortRecord struct {
+	Counters
+	// SrcPrefix is the source IP prefix
+	SrcPrefix net.IP // 20-23
+	// DstPrefix is the destination IP prefix
+	DstPrefix net.IP // 24-27
+	// DstMask is the destination network mask
+	DstMask uint8 // 28
+	// SrcMask is the source network mask
+	SrcMask uint8 // 29
+	// ToS is the IP type of service
+	ToS uint8 // 30
+	// Protocol number
+	Protocol uint8 // 31
+	// SrcPort is the TCP/UDP source port number or equivalent
+	SrcPort uint16 // 32-33
+	// DstPort is the TCP/UDP destination port number or equivalent
+	DstPort uint16 // 34-35
+	// Input is the SNMP index of input interface
+	Input uint16 // 36-37
+	// Output is the SNMP index of output interface
+	Output uint16 // 38-39
+}
+
+func (r PrefixPortRecord) String() string {
+	return fmt.Sprintf("%s/%d:%d -> %s/%d:%d", r.SrcPrefix, r.SrcMask, r.SrcPort, r.DstPrefix, r.DstMask, r.DstPort)
+}
+
+func (r *PrefixPortRecord) Unmarshal(h io.Reader) error {
+	if err := r.Counters.Unmarshal(h); err != nil { // 0-19
+		return err
+	}
+	if err := readIPv4(&r.SrcPrefix, h); err != nil { // 20-23
+		return err
+	}
+	if err := readIPv4(&r.DstPrefix, h); err != nil { // 24-27
+		return err
+	}
+	if err := read.Uint8(&r.DstMask, h); err != nil { // 28
+		return err
+	}
+	if err := read.Uint8(&r.SrcMask, h); err != nil { // 29
+		return err
+	}
+	if err := read.Uint8(&r.ToS, h); err != nil { // 30
+		return err
+	}
+	if err := read.Uint8(&r.Protocol, h); err != nil { // 31
+		return err
+	}
+	if err := read.Uint16(&r.SrcPort, h); err != nil { // 32-33
+		return err
+	}
+	if err := read.Uint16(&r.DstPort, h); err != nil { // 34-35
+		return err
+	}
+	if err := read.Uint16(&r.Input, h); err != nil { // 36-37
+		return err
+	}
+	if err := read.Uint16(&r.Output, h); err != nil { // 38-39
+		return err
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package netflow9
 import (
 	"encoding/hex"
 	"fmt"
+
+	"github.com/tehmaze/netflow/translate"
 )
 
 func Dump(p *Packet) {
@@ -19,7 +21,9 @@ func Dump(p *Packet) {
 			fmt.Printf("      record %d:\n", i)
 			for _, f := range dr.Fields {
 				if f.Translated != nil {
-					if f.Translated.Name != "" {
+					if name, ok := enumName(f); ok {
+						fmt.Printf("        %s: %v (%s)\n", f.Translated.Name, f.Translated.Value, name)
+					} else if f.Translated.Name != "" {
 						fmt.Printf("        %s: %v\n", f.Translated.Name, f.Translated.Value)
 					} else {
 						fmt.Printf("        %d: %v\n", f.Translated.Type, f.Bytes)
@@ -31,3 +35,9 @@ func Dump(p *Packet) {
 		}
 	}
 }
+
+// enumName returns the name of the value of a field with enumerated values, or
+// another description of the value.
+func enumName(f Field) (string, bool) {
+	return translate.Description(translate.NetFlow9Key(f.Type), f.Translated.Value, f.Bytes)
+}
@@ -0,0 +1,303 @@
+package netflow9
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/translate"
+)
+
+// DefaultMTU is the default maximum size of exported packets, a packet of this
+// size fits in a single UDP datagram on Ethernet.
+const DefaultMTU = 1472
+
+// Sizes of the Packet Header and FlowSet Header
+const (
+	exportPacketHeaderLen  = 20
+	exportFlowSetHeaderLen = 4
+)
+
+// Exporter builds NetFlow version 9 packets from data records with Go values,
+// for a single Source ID. Templates and records are packed in packets that do
+// not exceed the MTU, each packet returned by Flush can be sent as a single
+// UDP datagram.
+type Exporter struct {
+	// SourceID identifies the exporting process in the Packet Header.
+	SourceID uint32
+
+	// MTU is the maximum size of a packet.
+	MTU int
+
+	// Boot is the time the exporter started, the SysUpTime in the Packet
+	// Header is relative to Boot.
+	Boot time.Time
+
+	// TemplateRefresh is the interval at which all templates are sent again.
+	// If zero, templates are not refreshed based on time.
+	TemplateRefresh time.Duration
+
+	// TemplateRefreshPackets is the number of packets after which all
+	// templates are sent again. If zero, templates are not refreshed based
+	// on the number of packets.
+	TemplateRefreshPackets int
+
+	templates map[uint16]session.Template
+	order     []uint16
+	pending   []uint16
+	refreshed time.Time
+	packets   int
+	sequence  uint32
+	records   []exportRecord
+	translate *translate.Translate
+}
+
+// exportRecord is an encoded record waiting to be exported, in the FlowSet
+// with the given ID.
+type exportRecord struct {
+	flowSetID uint16
+	data      []byte
+}
+
+// NewExporter returns a new NetFlow version 9 exporter, if mtu is zero the
+// DefaultMTU is used.
+func NewExporter(sourceID uint32, mtu int) *Exporter {
+	if mtu == 0 {
+		mtu = DefaultMTU
+	}
+	return &Exporter{
+		SourceID:  sourceID,
+		MTU:       mtu,
+		Boot:      time.Now(),
+		templates: make(map[uint16]session.Template),
+		translate: translate.NewTranslate(nil),
+	}
+}
+
+// AddTemplate adds a Template Record or Options Template Record, the template
+// is sent with the next packet. Adding a template with the ID of an existing
+// template replaces it.
+func (e *Exporter) AddTemplate(template session.Template) error {
+	switch template.(type) {
+	case *TemplateRecord, *OptionTemplateRecord:
+	default:
+		return fmt.Errorf("unsupported template type %T", template)
+	}
+	if template.ID() < 256 {
+		return errProtocol("template id=%d is reserved", template.ID())
+	}
+	var data []byte
+	switch template := template.(type) {
+	case *TemplateRecord:
+		data = template.Marshal()
+	case *OptionTemplateRecord:
+		data = template.Marshal()
+	}
+	if err := e.checkSize(template.ID(), data); err != nil {
+		return err
+	}
+
+	if _, ok := e.templates[template.ID()]; !ok {
+		e.order = append(e.order, template.ID())
+	}
+	e.templates[template.ID()] = template
+	e.pending = append(e.pending, template.ID())
+	return nil
+}
+
+// Template returns the template with the ID.
+func (e *Exporter) Template(templateID uint16) (session.Template, bool) {
+	template, ok := e.templates[templateID]
+	return template, ok
+}
+
+// AddRecord encodes a data record using the template. There must be a value
+// for each field in the template, for Options Template Records the values of
+// the scope fields come first. Values are encoded according to the field type
+// of the field, scope values are encoded as unsigned integers and a []byte
+// value is used as-is.
+func (e *Exporter) AddRecord(templateID uint16, values ...interface{}) error {
+	template, ok := e.templates[templateID]
+	if !ok {
+		return errTemplateNotFound(templateID)
+	}
+
+	var scopes int
+	fields := template.GetFields()
+	if options, ok := template.(*OptionTemplateRecord); ok {
+		scopes = len(options.Scopes)
+		fields = append(options.GetScopeFields(), fields...)
+	}
+	if len(values) != len(fields) {
+		return fmt.Errorf("template id=%d has %d fields, got %d values", templateID, len(fields), len(values))
+	}
+
+	var data []byte
+	for i, value := range values {
+		ft := translate.Uint64
+		if i >= scopes {
+			ft = translate.OctetArray
+			if element, ok := e.translate.Key(translate.NetFlow9Key(fields[i].GetType())); ok {
+				ft = element.Type
+			}
+		}
+		b, err := marshalValue(value, ft, fields[i].GetLength())
+		if err != nil {
+			return fmt.Errorf("template id=%d field %d: %v", templateID, i, err)
+		}
+		data = append(data, b...)
+	}
+	if len(data) == 0 {
+		return errProtocol("template id=%d has no fields", templateID)
+	}
+	if err := e.checkSize(templateID, data); err != nil {
+		return err
+	}
+
+	e.records = append(e.records, exportRecord{flowSetID: templateID, data: data})
+	return nil
+}
+
+// checkSize checks if a packet with just the encoded record fits in the MTU,
+// so a single record can't hold up the records queued after it.
+func (e *Exporter) checkSize(templateID uint16, data []byte) error {
+	if exportPacketHeaderLen+exportFlowSetHeaderLen+len(data) > e.MTU {
+		return errProtocol("record of %d bytes for template id=%d exceeds MTU %d", len(data), templateID, e.MTU)
+	}
+	return nil
+}
+
+func marshalValue(value interface{}, ft translate.FieldType, length uint16) ([]byte, error) {
+	if b, ok := value.([]byte); ok {
+		if len(b) != int(length) {
+			return nil, fmt.Errorf("value of %d bytes does not match field length %d", len(b), length)
+		}
+		return b, nil
+	}
+	return translate.Marshal(value, ft, length)
+}
+
+// Flush packs the pending templates and records in packets. The SysUpTime and
+// UnixSecs of the packets are derived from now, which is also used to decide
+// if the templates are due for a refresh.
+func (e *Exporter) Flush(now time.Time) ([][]byte, error) {
+	if len(e.order) > 0 &&
+		((e.TemplateRefresh > 0 && now.Sub(e.refreshed) >= e.TemplateRefresh) ||
+			(e.TemplateRefreshPackets > 0 && e.packets >= e.TemplateRefreshPackets)) {
+		e.pending = append([]uint16(nil), e.order...)
+	}
+
+	var records []exportRecord
+	sent := make(map[uint16]bool)
+	for _, templateID := range e.pending {
+		template, ok := e.templates[templateID]
+		if !ok || sent[templateID] {
+			continue
+		}
+		sent[templateID] = true
+		switch template := template.(type) {
+		case *TemplateRecord:
+			records = append(records, exportRecord{flowSetID: 0, data: template.Marshal()})
+		case *OptionTemplateRecord:
+			records = append(records, exportRecord{flowSetID: 1, data: template.Marshal()})
+		}
+	}
+	records = append(records, e.records...)
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	b := exportBuilder{
+		mtu: e.MTU,
+		header: PacketHeader{
+			Version:        Version,
+			SysUpTime:      uint32(now.Sub(e.Boot) / time.Millisecond),
+			UnixSecs:       uint32(now.Unix()),
+			SequenceNumber: e.sequence,
+			SourceID:       e.SourceID,
+		},
+	}
+	for _, r := range records {
+		if err := b.add(r); err != nil {
+			return nil, err
+		}
+	}
+	b.closePacket()
+
+	if len(e.pending) > 0 {
+		e.refreshed = now
+		e.packets = 0
+	}
+	e.packets += len(b.packets)
+	e.pending = nil
+	e.records = nil
+	e.sequence = b.header.SequenceNumber
+	return b.packets, nil
+}
+
+// exportBuilder packs records in FlowSets, and FlowSets in packets.
+type exportBuilder struct {
+	mtu    int
+	header PacketHeader
+
+	packets   [][]byte
+	packet    []byte
+	flowSet   []byte
+	flowSetID uint16
+	count     uint16
+}
+
+// padded returns the size of a FlowSet with a body of n bytes, including the
+// padding to a 32 bit boundary.
+func padded(n int) int {
+	return (n + 3) &^ 3
+}
+
+func (b *exportBuilder) add(r exportRecord) error {
+	if exportPacketHeaderLen+exportFlowSetHeaderLen+padded(len(r.data)) > b.mtu {
+		return errProtocol("record of %d bytes in flow set id=%d exceeds MTU %d", len(r.data), r.flowSetID, b.mtu)
+	}
+
+	if b.flowSet != nil && r.flowSetID != b.flowSetID {
+		b.closeFlowSet()
+	}
+	size := padded(len(b.flowSet) + len(r.data))
+	if b.flowSet == nil {
+		size = exportFlowSetHeaderLen + padded(len(r.data))
+	}
+	if b.packet != nil && exportPacketHeaderLen+len(b.packet)+size > b.mtu {
+		b.closePacket()
+	}
+
+	if b.packet == nil {
+		b.packet = make([]byte, 0, b.mtu)
+	}
+	if b.flowSet == nil {
+		b.flowSet = make([]byte, exportFlowSetHeaderLen)
+		b.flowSetID = r.flowSetID
+	}
+	b.flowSet = append(b.flowSet, r.data...)
+	b.count++
+	return nil
+}
+
+func (b *exportBuilder) closeFlowSet() {
+	b.packet = append(b.packet, marshalFlowSet(b.flowSetID, b.flowSet[exportFlowSetHeaderLen:])...)
+	b.flowSet = nil
+}
+
+func (b *exportBuilder) closePacket() {
+	if b.flowSet != nil {
+		b.closeFlowSet()
+	}
+	if b.packet == nil {
+		return
+	}
+
+	// The sequence number counts the packets sent (RFC 3954 section 5.1)
+	b.header.Count = b.count
+	b.packets = append(b.packets, append(b.header.Marshal(), b.packet...))
+	b.header.SequenceNumber++
+	b.packet = nil
+	b.count = 0
+}
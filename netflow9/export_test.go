@@ -0,0 +1,201 @@
+package netflow9
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+func testExporter(t *testing.T, mtu int) *Exporter {
+	e := NewExporter(42, mtu)
+	e.Boot = time.Unix(1600000000, 0)
+	if err := e.AddTemplate(&TemplateRecord{TemplateID: 256, Fields: FieldSpecifiers{
+		{Type: 8, Length: 4},  // IPV4_SRC_ADDR
+		{Type: 12, Length: 4}, // IPV4_DST_ADDR
+		{Type: 1, Length: 4},  // IN_BYTES
+		{Type: 4, Length: 1},  // PROTOCOL
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddTemplate(&OptionTemplateRecord{TemplateID: 257,
+		Scopes:  ScopeSpecifiers{{Type: session.SCOPE_INTERFACE, Length: 4}},
+		Options: FieldSpecifiers{{Type: 34, Length: 4}, {Type: 82, Length: 8}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestExporterRoundTrip(t *testing.T) {
+	const mtu = 150
+	e := testExporter(t, mtu)
+	e.TemplateRefreshPackets = 3
+	for i := 0; i < 12; i++ {
+		if err := e.AddRecord(256, net.IP{10, 0, 0, byte(i)}, net.IP{10, 0, 1, 1}, uint32(i*100), uint8(6)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.AddRecord(257, 3, uint32(100), "ge0"); err != nil {
+		t.Fatal(err)
+	}
+
+	now := e.Boot.Add(90 * time.Second)
+	packets, err := e.Flush(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) < 2 {
+		t.Fatalf("expected the records to be split over multiple packets, got %d", len(packets))
+	}
+
+	s := session.New()
+	d := NewDecoder(nil, s)
+	records := 0
+	for i, data := range packets {
+		if len(data) > mtu {
+			t.Fatalf("packet of %d bytes exceeds MTU %d", len(data), mtu)
+		}
+		p, err := d.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Header.SequenceNumber != uint32(i) || p.Header.SourceID != 42 {
+			t.Fatalf("unexpected header %+v", p.Header)
+		}
+		if p.Header.SysUpTime != 90000 || p.Header.UnixSecs != uint32(now.Unix()) {
+			t.Fatalf("unexpected time in header %+v", p.Header)
+		}
+		for _, dfs := range p.DataFlowSets {
+			for _, dr := range dfs.Records {
+				if ip := dr.Fields[0].Translated.Value; !ip.(net.IP).Equal(net.IP{10, 0, 0, byte(records)}) {
+					t.Fatalf("record %d: unexpected source address %v", records, ip)
+				}
+				records++
+			}
+		}
+
+		// Packets are padded, so they must encode to the same bytes
+		out, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("expected %x, got %x", data, out)
+		}
+	}
+	if records != 12 {
+		t.Fatalf("expected 12 records, got %d", records)
+	}
+	if option := s.GetOption(42, 0, 34, session.SCOPE_INTERFACE, 3); option == nil {
+		t.Fatal("expected interface option")
+	}
+
+	// The templates are sent again once 3 packets were sent since the last
+	// refresh
+	sequence := uint32(len(packets))
+	for sent := len(packets); ; sent++ {
+		if err = e.AddRecord(256, net.IP{1, 1, 1, 1}, net.IP{1, 1, 1, 2}, uint32(1), uint8(17)); err != nil {
+			t.Fatal(err)
+		}
+		if packets, err = e.Flush(now); err != nil {
+			t.Fatal(err)
+		}
+		p, err := d.Decode(packets[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Header.SequenceNumber != sequence {
+			t.Fatalf("expected sequence number %d, got %d", sequence, p.Header.SequenceNumber)
+		}
+		sequence++
+
+		refreshed := len(p.TemplateFlowSets) == 1 && len(p.OptionsTemplateFlowSets) == 1
+		if refreshed != (sent >= 3) {
+			t.Fatalf("after %d packets: expected template refresh %t", sent, sent >= 3)
+		}
+		if refreshed {
+			break
+		}
+	}
+}
+
+func TestExporterTemplateRefresh(t *testing.T) {
+	e := testExporter(t, 0)
+	e.TemplateRefresh = time.Minute
+	now := e.Boot
+
+	for _, test := range []struct {
+		after     time.Duration
+		templates bool
+	}{
+		{0, true},
+		{time.Second, false},
+		{time.Minute, true},
+		{time.Minute + time.Second, false},
+	} {
+		if err := e.AddRecord(256, net.IP{1, 1, 1, 1}, net.IP{1, 1, 1, 2}, uint32(1), uint8(17)); err != nil {
+			t.Fatal(err)
+		}
+		packets, err := e.Flush(now.Add(test.after))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := Read(bytes.NewReader(packets[0]), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if templates := len(p.TemplateFlowSets) > 0; templates != test.templates {
+			t.Fatalf("after %s: expected templates %t, got %t", test.after, test.templates, templates)
+		}
+	}
+}
+
+func TestExporterInvalid(t *testing.T) {
+	e := testExporter(t, 64)
+	if err := e.AddTemplate(&TemplateRecord{TemplateID: 255}); err == nil {
+		t.Fatal("expected reserved template id to fail")
+	}
+	if err := e.AddRecord(258, 1); err == nil {
+		t.Fatal("expected unknown template to fail")
+	}
+	if err := e.AddRecord(256, 1, 2); err == nil {
+		t.Fatal("expected missing values to fail")
+	}
+	if err := e.AddTemplate(&TemplateRecord{TemplateID: 258, Fields: make(FieldSpecifiers, 12)}); err == nil {
+		t.Fatal("expected template exceeding the MTU to fail")
+	}
+	if err := e.AddTemplate(&TemplateRecord{TemplateID: 259, Fields: FieldSpecifiers{
+		{Type: 82, Length: 41}, // IF_NAME
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddRecord(259, make([]byte, 41)); err == nil {
+		t.Fatal("expected record exceeding the MTU to fail")
+	}
+
+	// The rejected record doesn't hold up the records after it
+	if err := e.AddRecord(256, net.IP{1, 1, 1, 1}, net.IP{1, 1, 1, 2}, uint32(1), uint8(17)); err != nil {
+		t.Fatal(err)
+	}
+	packets, err := e.Flush(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDecoder(nil, session.New())
+	var records int
+	for _, data := range packets {
+		p, err := d.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, dfs := range p.DataFlowSets {
+			records += len(dfs.Records)
+		}
+	}
+	if records != 1 {
+		t.Fatalf("expected the valid record to be exported, got %d records", records)
+	}
+}
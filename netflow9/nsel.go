@@ -0,0 +1,236 @@
+package netflow9
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/tehmaze/netflow/translate"
+)
+
+// Firewall events (NF_F_FW_EVENT)
+const (
+	FirewallEventIgnore  uint8 = 0
+	FirewallEventCreated uint8 = 1
+	FirewallEventDeleted uint8 = 2
+	FirewallEventDenied  uint8 = 3
+	FirewallEventAlert   uint8 = 4
+	FirewallEventUpdate  uint8 = 5
+)
+
+// Extended firewall events (NF_F_FW_EXT_EVENT), describing why a flow was
+// denied.
+const (
+	FirewallExtendedEventIngressACL uint16 = 1001
+	FirewallExtendedEventEgressACL  uint16 = 1002
+	FirewallExtendedEventInterface  uint16 = 1003
+	FirewallExtendedEventNotSYN     uint16 = 1004
+)
+
+// NetFlow Security Event Logging (NSEL) field types, as exported by Cisco ASA.
+const (
+	nselConnID            uint16 = 148
+	nselProtocol          uint16 = 4
+	nselSourceIPv4        uint16 = 8
+	nselSourcePort        uint16 = 7
+	nselIngressInterface  uint16 = 10
+	nselDestinationIPv4   uint16 = 12
+	nselDestinationPort   uint16 = 11
+	nselEgressInterface   uint16 = 14
+	nselSourceIPv6        uint16 = 27
+	nselDestinationIPv6   uint16 = 28
+	nselICMPTypeIPv4      uint16 = 176
+	nselICMPCodeIPv4      uint16 = 177
+	nselICMPTypeIPv6      uint16 = 178
+	nselICMPCodeIPv6      uint16 = 179
+	nselXlateSourceIPv4   uint16 = 225
+	nselXlateDestIPv4     uint16 = 226
+	nselXlateSourcePort   uint16 = 227
+	nselXlateDestPort     uint16 = 228
+	nselXlateSourceIPv6   uint16 = 281
+	nselXlateDestIPv6     uint16 = 282
+	nselInitiatorOctets   uint16 = 231
+	nselResponderOctets   uint16 = 232
+	nselFirewallEvent     uint16 = 233
+	nselEventTimeMsec     uint16 = 323
+	nselUsernameIANA      uint16 = 371
+	nselIngressACL        uint16 = 33000
+	nselEgressACL         uint16 = 33001
+	nselExtendedEvent     uint16 = 33002
+	nselUsername          uint16 = 40000
+	nselLegacyXlateSrcIP  uint16 = 40001
+	nselLegacyXlateDstIP  uint16 = 40002
+	nselLegacyXlateSrcPrt uint16 = 40003
+	nselLegacyXlateDstPrt uint16 = 40004
+	nselLegacyEvent       uint16 = 40005
+)
+
+// ACL identifies the access list entry that matched a flow.
+type ACL struct {
+	ACLID uint32
+	ACEID uint32
+	// Extended ACE ID, zero if not used
+	ExtendedACEID uint32
+}
+
+func (acl ACL) String() string {
+	return fmt.Sprintf("%08x/%08x/%08x", acl.ACLID, acl.ACEID, acl.ExtendedACEID)
+}
+
+// NSELEvent is a view on a Data Record containing a NetFlow Security Event
+// Logging (NSEL) event, as exported by Cisco ASA firewalls.
+type NSELEvent struct {
+	ConnID                       uint32
+	Event                        uint8
+	ExtendedEvent                uint16
+	EventTime                    time.Time
+	Protocol                     uint8
+	SourceAddress                net.IP
+	SourcePort                   uint16
+	DestinationAddress           net.IP
+	DestinationPort              uint16
+	ICMPType                     uint8
+	ICMPCode                     uint8
+	IngressInterface             uint32
+	EgressInterface              uint32
+	TranslatedSourceAddress      net.IP
+	TranslatedSourcePort         uint16
+	TranslatedDestinationAddress net.IP
+	TranslatedDestinationPort    uint16
+	IngressACL                   ACL
+	EgressACL                    ACL
+	Username                     string
+	InitiatorOctets              uint64
+	ResponderOctets              uint64
+}
+
+// NewNSELEvent returns the NSEL event in the Data Record, if the record has no
+// firewall event field it's not an NSEL record and false is returned.
+func NewNSELEvent(dr *DataRecord) (*NSELEvent, bool) {
+	var (
+		e     = new(NSELEvent)
+		found bool
+	)
+	for _, f := range dr.Fields {
+		b := f.Bytes
+		switch f.Type {
+		case nselConnID:
+			e.ConnID = uint32(unsigned(b))
+		case nselFirewallEvent, nselLegacyEvent:
+			e.Event = uint8(unsigned(b))
+			found = true
+		case nselExtendedEvent:
+			e.ExtendedEvent = uint16(unsigned(b))
+		case nselEventTimeMsec:
+			e.EventTime = time.Unix(0, 0).Add(time.Duration(unsigned(b)) * time.Millisecond)
+		case nselProtocol:
+			e.Protocol = uint8(unsigned(b))
+		case nselSourceIPv4, nselSourceIPv6:
+			e.SourceAddress = net.IP(b)
+		case nselSourcePort:
+			e.SourcePort = uint16(unsigned(b))
+		case nselDestinationIPv4, nselDestinationIPv6:
+			e.DestinationAddress = net.IP(b)
+		case nselDestinationPort:
+			e.DestinationPort = uint16(unsigned(b))
+		case nselICMPTypeIPv4, nselICMPTypeIPv6:
+			e.ICMPType = uint8(unsigned(b))
+		case nselICMPCodeIPv4, nselICMPCodeIPv6:
+			e.ICMPCode = uint8(unsigned(b))
+		case nselIngressInterface:
+			e.IngressInterface = uint32(unsigned(b))
+		case nselEgressInterface:
+			e.EgressInterface = uint32(unsigned(b))
+		case nselXlateSourceIPv4, nselXlateSourceIPv6, nselLegacyXlateSrcIP:
+			e.TranslatedSourceAddress = net.IP(b)
+		case nselXlateSourcePort, nselLegacyXlateSrcPrt:
+			e.TranslatedSourcePort = uint16(unsigned(b))
+		case nselXlateDestIPv4, nselXlateDestIPv6, nselLegacyXlateDstIP:
+			e.TranslatedDestinationAddress = net.IP(b)
+		case nselXlateDestPort, nselLegacyXlateDstPrt:
+			e.TranslatedDestinationPort = uint16(unsigned(b))
+		case nselIngressACL:
+			e.IngressACL = nselACL(b)
+		case nselEgressACL:
+			e.EgressACL = nselACL(b)
+		case nselUsername, nselUsernameIANA:
+			e.Username = nselString(b)
+		case nselInitiatorOctets:
+			e.InitiatorOctets = unsigned(b)
+		case nselResponderOctets:
+			e.ResponderOctets = unsigned(b)
+		}
+	}
+	if !found {
+		return nil, false
+	}
+	return e, true
+}
+
+// EventName returns the name of the firewall event.
+func (e *NSELEvent) EventName() string {
+	if name, ok := translate.Enum(translate.Key{EnterpriseID: 0, FieldID: nselFirewallEvent}, uint64(e.Event)); ok {
+		return name
+	}
+	return fmt.Sprintf("event %d", e.Event)
+}
+
+// ExtendedEventName returns the name of the extended firewall event.
+func (e *NSELEvent) ExtendedEventName() string {
+	if name, ok := translate.Enum(translate.NetFlow9Key(nselExtendedEvent), uint64(e.ExtendedEvent)); ok {
+		return name
+	}
+	return fmt.Sprintf("extended event %d", e.ExtendedEvent)
+}
+
+// IsTeardown checks if the event is a flow teardown.
+func (e *NSELEvent) IsTeardown() bool {
+	return e.Event == FirewallEventDeleted
+}
+
+func (e *NSELEvent) String() string {
+	s := fmt.Sprintf("%s (%s) conn %d proto %d %s:%d -> %s:%d",
+		e.EventName(), e.ExtendedEventName(), e.ConnID, e.Protocol,
+		e.SourceAddress, e.SourcePort, e.DestinationAddress, e.DestinationPort)
+	if e.TranslatedSourceAddress != nil || e.TranslatedDestinationAddress != nil {
+		s += fmt.Sprintf(" xlate %s:%d -> %s:%d",
+			e.TranslatedSourceAddress, e.TranslatedSourcePort,
+			e.TranslatedDestinationAddress, e.TranslatedDestinationPort)
+	}
+	if e.Username != "" {
+		s += " user " + e.Username
+	}
+	return s
+}
+
+// unsigned decodes an unsigned integer, invalid lengths decode as zero.
+func unsigned(b []byte) uint64 {
+	v, _ := translate.Unsigned(b)
+	return v
+}
+
+// nselString decodes a NUL padded string.
+func nselString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+// nselACL decodes an ACL identifier, which consists of three 32 bit words.
+func nselACL(b []byte) ACL {
+	var acl ACL
+	if len(b) >= 4 {
+		acl.ACLID = binary.BigEndian.Uint32(b[0:])
+	}
+	if len(b) >= 8 {
+		acl.ACEID = binary.BigEndian.Uint32(b[4:])
+	}
+	if len(b) >= 12 {
+		acl.ExtendedACEID = binary.BigEndian.Uint32(b[8:])
+	}
+	return acl
+}
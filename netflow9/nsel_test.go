@@ -0,0 +1,112 @@
+package netflow9
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+// testNSELPacket returns a packet with an NSEL flow teardown event using the
+// IANA field types, and a flow creation event using the legacy field types of
+// older ASA releases.
+func testNSELPacket(t *testing.T) []byte {
+	e := NewExporter(1, 0)
+	if err := e.AddTemplate(&TemplateRecord{TemplateID: 263, Fields: FieldSpecifiers{
+		{Type: nselConnID, Length: 4},
+		{Type: nselFirewallEvent, Length: 1},
+		{Type: nselExtendedEvent, Length: 2},
+		{Type: nselEventTimeMsec, Length: 8},
+		{Type: nselProtocol, Length: 1},
+		{Type: nselSourceIPv4, Length: 4},
+		{Type: nselSourcePort, Length: 2},
+		{Type: nselDestinationIPv4, Length: 4},
+		{Type: nselDestinationPort, Length: 2},
+		{Type: nselXlateSourceIPv4, Length: 4},
+		{Type: nselXlateSourcePort, Length: 2},
+		{Type: nselIngressACL, Length: 12},
+		{Type: nselUsernameIANA, Length: 16},
+		{Type: nselInitiatorOctets, Length: 8},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddTemplate(&TemplateRecord{TemplateID: 264, Fields: FieldSpecifiers{
+		{Type: nselConnID, Length: 4},
+		{Type: nselLegacyEvent, Length: 1},
+		{Type: nselSourceIPv4, Length: 4},
+		{Type: nselLegacyXlateSrcIP, Length: 4},
+		{Type: nselLegacyXlateSrcPrt, Length: 2},
+		{Type: nselUsername, Length: 20},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	acl := []byte{0x12, 0x34, 0x56, 0x78, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}
+	if err := e.AddRecord(263, uint32(42), FirewallEventDeleted, uint16(0), time.Unix(1600000000, 0),
+		uint8(6), net.IP{10, 0, 0, 1}, uint16(49152), net.IP{192, 0, 2, 1}, uint16(443),
+		net.IP{198, 51, 100, 1}, uint16(1024), acl, "alice", uint64(1500)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddRecord(264, uint32(43), FirewallEventCreated, net.IP{10, 0, 0, 2},
+		net.IP{198, 51, 100, 2}, uint16(1025), "bob"); err != nil {
+		t.Fatal(err)
+	}
+	packets, err := e.Flush(time.Unix(1600000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packets[0]
+}
+
+func TestNSELEvent(t *testing.T) {
+	p, err := NewDecoder(nil, session.New()).Decode(testNSELPacket(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.DataFlowSets) != 2 {
+		t.Fatalf("expected 2 data flow sets, got %d", len(p.DataFlowSets))
+	}
+
+	e, ok := NewNSELEvent(&p.DataFlowSets[0].Records[0])
+	if !ok {
+		t.Fatal("expected an NSEL event")
+	}
+	if e.ConnID != 42 || !e.IsTeardown() || e.Protocol != 6 || e.EventTime.Unix() != 1600000000 ||
+		!e.SourceAddress.Equal(net.IP{10, 0, 0, 1}) || e.SourcePort != 49152 ||
+		!e.DestinationAddress.Equal(net.IP{192, 0, 2, 1}) || e.DestinationPort != 443 ||
+		!e.TranslatedSourceAddress.Equal(net.IP{198, 51, 100, 1}) || e.TranslatedSourcePort != 1024 ||
+		e.InitiatorOctets != 1500 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if e.IngressACL != (ACL{ACLID: 0x12345678, ACEID: 1}) || e.IngressACL.String() != "12345678/00000001/00000000" {
+		t.Fatalf("unexpected ingress ACL %s", e.IngressACL)
+	}
+	if e.Username != "alice" {
+		t.Fatalf("expected user name from the IANA field, got %q", e.Username)
+	}
+
+	// The legacy field types of older ASA releases
+	e, ok = NewNSELEvent(&p.DataFlowSets[1].Records[0])
+	if !ok {
+		t.Fatal("expected an NSEL event")
+	}
+	if e.ConnID != 43 || e.Event != FirewallEventCreated || e.IsTeardown() ||
+		!e.TranslatedSourceAddress.Equal(net.IP{198, 51, 100, 2}) || e.TranslatedSourcePort != 1025 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if e.Username != "bob" {
+		t.Fatalf("expected user name from the legacy field, got %q", e.Username)
+	}
+	want := e.EventName() + " (" + e.ExtendedEventName() + ") conn 43 proto 0 10.0.0.2:0 -> <nil>:0 xlate 198.51.100.2:1025 -> <nil>:0 user bob"
+	if e.String() != want {
+		t.Fatalf("expected %q, got %q", want, e.String())
+	}
+}
+
+func TestNSELEventWithoutEvent(t *testing.T) {
+	dr := &DataRecord{Fields: Fields{{Type: nselConnID, Bytes: []byte{0, 0, 0, 1}}}}
+	if _, ok := NewNSELEvent(dr); ok {
+		t.Fatal("expected record without firewall event not to be an NSEL event")
+	}
+}
@@ -2,6 +2,7 @@ package netflow9
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"strings"
@@ -52,6 +53,12 @@ func (p *Packet) UnmarshalFlowSets(r io.Reader, s session.Session, t *Translate)
 	}
 	var records uint16 = 0
 
+	// Templates and options are scoped by the Source ID
+	domain := p.Header.SourceID
+	if t != nil {
+		t.domain = domain
+	}
+
 	for i := uint16(0); i < p.Header.Count; i++ {
 		// We have all expected flows
 		if records >= p.Header.Count {
@@ -93,11 +100,12 @@ func (p *Packet) UnmarshalFlowSets(r io.Reader, s session.Session, t *Translate)
 				debugLog.Printf("unmarshaled %d records: %v\n", len(tfs.Records), tfs)
 			}
 
-			for _, tr := range tfs.Records {
-				tr.register(s)
+			for i := range tfs.Records {
+				tfs.Records[i].register(s, domain)
+				decodePending(s, t, domain, tfs.Records[i].TemplateID)
 			}
 
-			records += 1
+			records += uint16(len(tfs.Records))
 			p.TemplateFlowSets = append(p.TemplateFlowSets, tfs)
 
 		case 1: // Options Template FlowSet
@@ -127,11 +135,12 @@ func (p *Packet) UnmarshalFlowSets(r io.Reader, s session.Session, t *Translate)
 				debugLog.Printf("ofs: unmarshaled %d records: %v\n", len(ofs.Records), ofs)
 			}
 
-			for _, record := range ofs.Records {
-				record.register(s)
+			for i := range ofs.Records {
+				ofs.Records[i].register(s, domain)
+				decodePending(s, t, domain, ofs.Records[i].TemplateID)
 			}
 
-			records += 1
+			records += uint16(len(ofs.Records))
 			p.OptionsTemplateFlowSets = append(p.OptionsTemplateFlowSets, ofs)
 
 		default:
@@ -160,54 +169,146 @@ func (p *Packet) UnmarshalFlowSets(r io.Reader, s session.Session, t *Translate)
 					debugLog.Printf("no session, storing %d raw bytes in data set\n", len(data))
 				}
 				dfs.Bytes = data
+				p.DataFlowSets = append(p.DataFlowSets, dfs)
 				continue
 			}
-			tm, ok = s.GetTemplate(header.ID)
+			tm, ok = s.GetTemplate(domain, header.ID)
 			if !ok {
+				// Decode the Data FlowSet once the template arrives, if there
+				// is a pending handler and the session has a pending data
+				// buffer
+				if t != nil && t.PendingHandler != nil && s.AddPending(domain, header.ID, data) {
+					if debug {
+						debugLog.Printf("no template for id=%d, buffering %d bytes\n", header.ID, len(data))
+					}
+					continue
+				}
 				if(debug) {
 					debugLog.Printf("no template for id=%d, storing %d raw bytes in data set\n", header.ID, len(data))
 				}
 				dfs.Bytes = data
+				p.DataFlowSets = append(p.DataFlowSets, dfs)
 				continue
 			}
-			err := dfs.Unmarshal(bytes.NewBuffer(data), tm, t)
-			if(err != nil) {
+			if err := decodeDataFlowSet(&dfs, data, tm, s, t, domain); err != nil {
 				debugLog.Printf("Failed to unmarshal DataFlowSet: %s\n", err)
 				return err
 			}
 			records += uint16(len(dfs.Records))
-			switch tm.(type) {
-				case *TemplateRecord:
-					p.DataFlowSets = append(p.DataFlowSets, dfs)
-				case *OptionTemplateRecord:
-					if(debug) {
-						debugLog.Printf("v9 data record with option template: %v\n", tm)
-					}
-					for _, record := range dfs.Records {
-						if(debug) {
-							debugLog.Printf("v9 option data record: %v\n", record)
-						}
-						for _, scope := range record.OptionScopes {
-							for _, field := range record.Fields {
-								s.SetOption(0, field.Type, &session.Option{
-									TemplateID: header.ID,
-									Scope: scope,
-									Bytes: field.Bytes,
-									EnterpriseNumber: 0,
-									Type: field.Type,
-									Value: field.Translated.Value,
-								})
-							}
-						}
-					}
-					p.OptionsDataFlowSets = append(p.OptionsDataFlowSets, dfs)
-			}
+			p.addDataFlowSet(dfs, tm)
 		}
 	}
 
 	return nil
 }
 
+// decodeDataFlowSet decodes the Data Records in the Data FlowSet using the
+// template, the values of options Data Records are stored in the session.
+func decodeDataFlowSet(dfs *DataFlowSet, data []byte, tm session.Template, s session.Session, t *Translate, domain uint32) error {
+	if err := dfs.Unmarshal(bytes.NewBuffer(data), tm, t); err != nil {
+		return err
+	}
+	if _, ok := tm.(*OptionTemplateRecord); !ok {
+		return nil
+	}
+	if(debug) {
+		debugLog.Printf("v9 data record with option template: %v\n", tm)
+	}
+	for _, record := range dfs.Records {
+		if(debug) {
+			debugLog.Printf("v9 option data record: %v\n", record)
+		}
+		for _, scope := range record.OptionScopes {
+			for _, field := range record.Fields {
+				option := &session.Option{
+					TemplateID: dfs.Header.ID,
+					Scope: scope,
+					Bytes: field.Bytes,
+					EnterpriseNumber: 0,
+					Type: field.Type,
+				}
+				if field.Translated != nil {
+					option.Value = field.Translated.Value
+				}
+				s.SetOption(domain, 0, field.Type, option)
+			}
+		}
+	}
+	return nil
+}
+
+// addDataFlowSet adds the decoded Data FlowSet to the packet.
+func (p *Packet) addDataFlowSet(dfs DataFlowSet, tm session.Template) {
+	if _, ok := tm.(*OptionTemplateRecord); ok {
+		p.OptionsDataFlowSets = append(p.OptionsDataFlowSets, dfs)
+	} else {
+		p.DataFlowSets = append(p.DataFlowSets, dfs)
+	}
+}
+
+// decodePending decodes the Data FlowSets that arrived before the template with
+// the given id, and passes them to the PendingHandler of the translator.
+func decodePending(s session.Session, t *Translate, domain uint32, id uint16) {
+	if s == nil || t == nil || t.PendingHandler == nil {
+		return
+	}
+	pending := s.TakePending(domain, id)
+	if len(pending) == 0 {
+		return
+	}
+	tm, ok := s.GetTemplate(domain, id)
+	if !ok {
+		return
+	}
+	for _, set := range pending {
+		header := FlowSetHeader{ID: id, Length: uint16(len(set.Data) + 4)}
+		dfs := DataFlowSet{Header: header}
+		if err := decodeDataFlowSet(&dfs, set.Data, tm, s, t, domain); err != nil {
+			if debug {
+				debugLog.Printf("error decoding pending data flow set for id=%d: %v\n", id, err)
+			}
+			t.PendingHandler(domain, DataFlowSet{Header: header, Bytes: set.Data}, err)
+			continue
+		}
+		t.PendingHandler(domain, dfs, nil)
+	}
+}
+
+// MarshalBinary encodes the Packet, including all of its FlowSets, in its wire
+// format. The Packet Header version and count are updated to reflect the
+// encoded records. The Template FlowSets and Options Template FlowSets are
+// encoded before the Data FlowSets.
+func (p *Packet) MarshalBinary() ([]byte, error) {
+	var (
+		data  []byte
+		count int
+	)
+	for _, tfs := range p.TemplateFlowSets {
+		data = append(data, tfs.Marshal()...)
+		count += len(tfs.Records)
+	}
+	for _, ofs := range p.OptionsTemplateFlowSets {
+		data = append(data, ofs.Marshal()...)
+		count += len(ofs.Records)
+	}
+	for _, sets := range [][]DataFlowSet{p.DataFlowSets, p.OptionsDataFlowSets} {
+		for _, dfs := range sets {
+			if dfs.Records == nil && dfs.Bytes == nil {
+				continue
+			}
+			data = append(data, dfs.Marshal()...)
+			count += len(dfs.Records)
+		}
+	}
+	if count > 0xffff {
+		return nil, errProtocol("%d records exceed maximum", count)
+	}
+
+	p.Header.Version = Version
+	p.Header.Count = uint16(count)
+	return append(p.Header.Marshal(), data...), nil
+}
+
 func (h PacketHeader) Len() int {
 	return 20
 }
@@ -235,6 +336,18 @@ func (h *PacketHeader) Unmarshal(r io.Reader) error {
 	return nil
 }
 
+// Marshal encodes the Packet Header in its wire format.
+func (h PacketHeader) Marshal() []byte {
+	data := make([]byte, h.Len())
+	binary.BigEndian.PutUint16(data[0:], h.Version)
+	binary.BigEndian.PutUint16(data[2:], h.Count)
+	binary.BigEndian.PutUint32(data[4:], h.SysUpTime)
+	binary.BigEndian.PutUint32(data[8:], h.UnixSecs)
+	binary.BigEndian.PutUint32(data[12:], h.SequenceNumber)
+	binary.BigEndian.PutUint32(data[16:], h.SourceID)
+	return data
+}
+
 type FlowSetHeader struct {
 	ID     uint16
 	Length uint16
@@ -255,6 +368,18 @@ func (h *FlowSetHeader) Unmarshal(r io.Reader) error {
 	return nil
 }
 
+// marshalFlowSet encodes a FlowSet with the given ID, the body is padded to a
+// 32 bit boundary (RFC 3954 section 5.2).
+func marshalFlowSet(id uint16, body []byte) []byte {
+	if pad := len(body) % 4; pad != 0 {
+		body = append(body, make([]byte, 4-pad)...)
+	}
+	data := make([]byte, 4, 4+len(body))
+	binary.BigEndian.PutUint16(data[0:], id)
+	binary.BigEndian.PutUint16(data[2:], uint16(4+len(body)))
+	return append(data, body...)
+}
+
 // TemplateFlowSet enhance the flexibility of the Flow Record format because
 // they allow the NetFlow Collector to process Flow Records without necessarily
 // knowing the interpretation of all the data in the Flow Record.
@@ -303,10 +428,11 @@ func (tfs *TemplateFlowSet) UnmarshalRecords(r io.Reader) error {
 		return err
 	}
 
-	// As long as there are more than 4 bytes in the buffer, we parse the next
-	// TemplateRecord, otherwise it's padding.
+	// As long as there are at least 4 bytes in the buffer, we parse the next
+	// TemplateRecord, otherwise it's padding. A TemplateRecord without fields
+	// is 4 bytes.
 	tfs.Records = make([]TemplateRecord, 0)
-	for buffer.Len() > 4 {
+	for buffer.Len() >= 4 {
 		record := TemplateRecord{}
 		if err := record.Unmarshal(buffer); err != nil {
 			return err
@@ -318,6 +444,15 @@ func (tfs *TemplateFlowSet) UnmarshalRecords(r io.Reader) error {
 	return nil
 }
 
+// Marshal encodes the Template FlowSet in its wire format.
+func (tfs TemplateFlowSet) Marshal() []byte {
+	var body []byte
+	for _, tr := range tfs.Records {
+		body = append(body, tr.Marshal()...)
+	}
+	return marshalFlowSet(0, body)
+}
+
 // TemplateRecord is a Template Record as per RFC3964 section 5.2
 type TemplateRecord struct {
 	TemplateID uint16
@@ -325,14 +460,22 @@ type TemplateRecord struct {
 	Fields     FieldSpecifiers
 }
 
-func (tr *TemplateRecord) register(s session.Session) {
+func (tr *TemplateRecord) register(s session.Session, domain uint32) {
 	if s == nil {
 		return
 	}
+	// NetFlow version 9 has no template withdrawal, a template without fields
+	// is invalid and is ignored.
+	if len(tr.Fields) == 0 {
+		if debug {
+			debugLog.Println("ignore template with empty fields:", tr)
+		}
+		return
+	}
 	if debug {
 		debugLog.Println("register template:", tr)
 	}
-	s.AddTemplate(tr)
+	s.AddTemplate(domain, tr)
 }
 
 func (tr TemplateRecord) ID() uint16 {
@@ -375,6 +518,14 @@ func (tr *TemplateRecord) Unmarshal(r io.Reader) error {
 	return nil
 }
 
+// Marshal encodes the Template Record in its wire format.
+func (tr TemplateRecord) Marshal() []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint16(data[0:], tr.TemplateID)
+	binary.BigEndian.PutUint16(data[2:], uint16(len(tr.Fields)))
+	return append(data, tr.Fields.Marshal()...)
+}
+
 type FieldSpecifier struct {
 	Type   uint16
 	Length uint16
@@ -422,6 +573,16 @@ func (fs *FieldSpecifiers) Unmarshal(r io.Reader) error {
 	return nil
 }
 
+// Marshal encodes the Field Specifiers in their wire format.
+func (fs FieldSpecifiers) Marshal() []byte {
+	data := make([]byte, 4*len(fs))
+	for i, f := range fs {
+		binary.BigEndian.PutUint16(data[i*4:], f.Type)
+		binary.BigEndian.PutUint16(data[i*4+2:], f.Length)
+	}
+	return data
+}
+
 // OptionsTemplateRecord (and its corresponding OptionsDataRecord) is used to
 // supply information about the NetFlow process configuration or NetFlow
 // process specific data, rather than supplying information about IP Flows.
@@ -476,6 +637,15 @@ func (this *OptionsTemplateFlowSet) UnmarshalRecords(r io.Reader) error {
 	return nil
 }
 
+// Marshal encodes the Options Template FlowSet in its wire format.
+func (ofs OptionsTemplateFlowSet) Marshal() []byte {
+	var body []byte
+	for _, otr := range ofs.Records {
+		body = append(body, otr.Marshal()...)
+	}
+	return marshalFlowSet(1, body)
+}
+
 type OptionTemplateRecord struct {
 	TemplateID    uint16
 	ScopeLength   uint16
@@ -484,14 +654,14 @@ type OptionTemplateRecord struct {
 	Options       FieldSpecifiers
 }
 
-func (this *OptionTemplateRecord) register(s session.Session) {
+func (this *OptionTemplateRecord) register(s session.Session, domain uint32) {
 	if(s == nil) {
 		return
 	}
 	if(debug) {
 		debugLog.Println("register option template:", this)
 	}
-	s.AddTemplate(this)
+	s.AddTemplate(domain, this)
 }
 
 func (this OptionTemplateRecord) ID() uint16 {
@@ -507,6 +677,14 @@ func (this OptionTemplateRecord) GetFields() []session.TemplateFieldSpecifier {
 	return fs
 }
 
+func (this OptionTemplateRecord) GetScopeFields() []session.TemplateFieldSpecifier {
+	fs := make([]session.TemplateFieldSpecifier, len(this.Scopes))
+	for i, v := range this.Scopes {
+		fs[i] = v
+	}
+	return fs
+}
+
 func (this OptionTemplateRecord) Size() int {
 	var size int
 	for _, scope := range this.Scopes {
@@ -551,6 +729,19 @@ func (this *OptionTemplateRecord) Unmarshal(r io.Reader) error {
 	return nil
 }
 
+// Marshal encodes the Options Template Record in its wire format, the scope
+// and option lengths are computed from the scopes and options.
+func (otr OptionTemplateRecord) Marshal() []byte {
+	data := make([]byte, 6, 6+4*(len(otr.Scopes)+len(otr.Options)))
+	binary.BigEndian.PutUint16(data[0:], otr.TemplateID)
+	binary.BigEndian.PutUint16(data[2:], uint16(4*len(otr.Scopes)))
+	binary.BigEndian.PutUint16(data[4:], uint16(4*len(otr.Options)))
+	for _, scope := range otr.Scopes {
+		data = append(data, byte(scope.Type>>8), byte(scope.Type), byte(scope.Length>>8), byte(scope.Length))
+	}
+	return append(data, otr.Options.Marshal()...)
+}
+
 type ScopeSpecifier struct {
 	Type   uint16
 	Length uint16
@@ -608,11 +799,24 @@ func (dfs *DataFlowSet) Unmarshal(r io.Reader, template session.Template, t *Tra
 	buffer := new(bytes.Buffer)
 	buffer.ReadFrom(r)
 
+	option_template, is_option := template.(*OptionTemplateRecord)
+
 	dfs.Records = make([]DataRecord, 0)
 	for buffer.Len() >= 4 { // Continue until only padding alignment bytes left
 		var dr = DataRecord{}
 		dr.TemplateID = template.ID()
-		if err := dr.Unmarshal(bytes.NewBuffer(buffer.Next(template.Size())), template.GetFields(), t); err != nil {
+		data := bytes.NewBuffer(buffer.Next(template.Size()))
+		if is_option {
+			// The scope fields precede the option fields in the record
+			dr.ScopeFields = make(Fields, len(option_template.Scopes))
+			for i, scope := range option_template.Scopes {
+				dr.ScopeFields[i] = Field{Type: scope.Type, Length: scope.Length}
+				if err := dr.ScopeFields[i].Unmarshal(data); err != nil {
+					return err
+				}
+			}
+		}
+		if err := dr.Unmarshal(data, template.GetFields(), t); err != nil {
 			return err
 		}
 		dfs.Records = append(dfs.Records, dr)
@@ -621,10 +825,29 @@ func (dfs *DataFlowSet) Unmarshal(r io.Reader, template session.Template, t *Tra
 	return nil
 }
 
+// Marshal encodes the Data FlowSet in its wire format. If the Data FlowSet has
+// no decoded records, the raw bytes are used in stead.
+func (dfs DataFlowSet) Marshal() []byte {
+	id := dfs.Header.ID
+	if dfs.Records == nil {
+		return marshalFlowSet(id, dfs.Bytes)
+	}
+	var body []byte
+	for _, dr := range dfs.Records {
+		if id == 0 {
+			id = dr.TemplateID
+		}
+		body = append(body, dr.Marshal()...)
+	}
+	return marshalFlowSet(id, body)
+}
+
 type DataRecord struct {
 	TemplateID   uint16
 	OptionScopes []session.OptionScope
-	Fields       Fields
+	// ScopeFields are the scope fields of an options data record
+	ScopeFields Fields
+	Fields      Fields
 }
 
 func (dr *DataRecord) Unmarshal(r io.Reader, fss []session.TemplateFieldSpecifier, t *Translate) error {
@@ -657,6 +880,19 @@ func (dr *DataRecord) Unmarshal(r io.Reader, fss []session.TemplateFieldSpecifie
 	return nil
 }
 
+// Marshal encodes the Data Record in its wire format, the scope fields of an
+// options data record precede its fields.
+func (dr DataRecord) Marshal() []byte {
+	var data []byte
+	for _, f := range dr.ScopeFields {
+		data = append(data, f.Bytes...)
+	}
+	for _, f := range dr.Fields {
+		data = append(data, f.Bytes...)
+	}
+	return data
+}
+
 func (this *DataRecord) GetTemplateID() uint16 {
 	return this.TemplateID
 }
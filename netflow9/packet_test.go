@@ -0,0 +1,107 @@
+package netflow9
+
+import (
+	"net"
+	"testing"
+
+	"github.com/tehmaze/netflow/session"
+)
+
+func TestTemplateWithoutFields(t *testing.T) {
+	e := testExporter(t, 0)
+	packets, err := e.Flush(e.Boot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := session.New()
+	d := NewDecoder(nil, s)
+	if _, err = d.Decode(packets[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// Template 256 without fields, followed by 4 bytes of padding
+	p := &Packet{
+		Header: PacketHeader{Version: 9, Count: 1, SourceID: 42},
+		TemplateFlowSets: []TemplateFlowSet{{Records: []TemplateRecord{
+			{TemplateID: 256},
+		}}},
+	}
+	data, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, 0, 0, 0, 0)
+	data[23] += 4 // FlowSet length
+	if _, err = d.Decode(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found := s.GetTemplate(42, 256); !found {
+		t.Fatal("expected template without fields to be ignored")
+	}
+	if stats := s.TemplateStats(); stats.Active != 2 || stats.Withdrawn != 0 {
+		t.Fatalf("unexpected template stats %+v", stats)
+	}
+}
+
+func TestPendingDataFlowSet(t *testing.T) {
+	e := testExporter(t, 0)
+	if err := e.AddRecord(256, net.IP{10, 0, 0, 1}, net.IP{10, 0, 1, 1}, uint32(100), uint8(6)); err != nil {
+		t.Fatal(err)
+	}
+	packets, err := e.Flush(e.Boot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewDecoder(nil, session.New()).Decode(packets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := (&Packet{Header: p.Header, DataFlowSets: p.DataFlowSets}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, err := (&Packet{Header: p.Header, TemplateFlowSets: p.TemplateFlowSets}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := session.New()
+	s.SetPendingLimits(session.DefaultPendingSize, session.DefaultPendingAge)
+	d := NewDecoder(nil, s)
+
+	// Without a handler the Data FlowSet is kept as raw bytes
+	if p, err = d.Decode(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.DataFlowSets) != 1 || p.DataFlowSets[0].Records != nil || s.TemplateStats().Pending != 0 {
+		t.Fatalf("expected raw data flowset, got %+v", p.DataFlowSets)
+	}
+
+	var pending []DataFlowSet
+	d.PendingHandler = func(sourceID uint32, dfs DataFlowSet, err error) {
+		if sourceID != 42 || err != nil {
+			t.Fatalf("unexpected pending data flowset for source %d: %v", sourceID, err)
+		}
+		pending = append(pending, dfs)
+	}
+	if p, err = d.Decode(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.DataFlowSets) != 0 || s.TemplateStats().Pending != 1 {
+		t.Fatalf("expected data flowset to be buffered, got %+v", p.DataFlowSets)
+	}
+	if p, err = d.Decode(template); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.DataFlowSets) != 0 {
+		t.Fatalf("unexpected data flowsets in packet with template %+v", p.DataFlowSets)
+	}
+	if len(pending) != 1 || len(pending[0].Records) != 1 {
+		t.Fatalf("expected 1 pending record, got %+v", pending)
+	}
+	if ip := pending[0].Records[0].Fields[0].Translated.Value; !ip.(net.IP).Equal(net.IP{10, 0, 0, 1}) {
+		t.Fatalf("unexpected source address %v", ip)
+	}
+}
@@ -20,10 +20,21 @@ func (tf TranslatedField) String() string {
 
 type Translate struct {
 	*translate.Translate
+
+	// PendingHandler is called with Data FlowSets that arrived before their
+	// template, once the template is registered. Data FlowSets are only
+	// buffered if there is a handler and the session has a pending data
+	// buffer, see session.Session.SetPendingLimits, otherwise they are added
+	// to their packet as raw bytes. If a Data FlowSet can't be decoded, the
+	// handler is called with the error and the raw bytes.
+	PendingHandler func(sourceID uint32, dfs DataFlowSet, err error)
+
+	// Source ID of the packet being translated
+	domain uint32
 }
 
 func NewTranslate(s session.Session) *Translate {
-	return &Translate{translate.NewTranslate(s)}
+	return &Translate{Translate: translate.NewTranslate(s)}
 }
 
 func (t *Translate) Record(dr *DataRecord) error {
@@ -37,7 +48,7 @@ func (t *Translate) Record(dr *DataRecord) error {
 		tm session.Template
 		ok bool
 	)
-	if tm, ok = t.Session.GetTemplate(dr.TemplateID); !ok {
+	if tm, ok = t.Session.GetTemplate(t.domain, dr.TemplateID); !ok {
 		if debug {
 			debugLog.Printf("no template for id=%d, can't translate field\n", dr.TemplateID)
 		}
@@ -65,7 +76,9 @@ func (t *Translate) Record(dr *DataRecord) error {
 				case session.SCOPE_SYSTEM:
 					// Do nothing, there's no value for system scope
 				case session.SCOPE_INTERFACE:
-					scope.Index = translate.Bytes(dr.Fields[i].Bytes, translate.Uint16).(uint16)
+					if i < len(dr.ScopeFields) {
+						scope.Index = uint32(unsigned(dr.ScopeFields[i].Bytes))
+					}
 				case session.SCOPE_LINECARD:
 					// TODO:  Figure out data length/type and do something with this
 					continue
@@ -87,11 +100,12 @@ func (t *Translate) Record(dr *DataRecord) error {
 		f.Translated = &TranslatedField{}
 		f.Translated.Type = field.GetType()
 
-		if element, ok := t.Translate.Key(translate.Key{0, field.GetType()}); ok {
+		key := translate.NetFlow9Key(field.GetType())
+		if element, ok := t.Translate.Key(key); ok {
 			f.Translated.Name = element.Name
 			f.Translated.Value = translate.Bytes(dr.Fields[i].Bytes, element.Type)
 		} else if debug {
-			debugLog.Printf("no translator element for {0, %d}\n", field.GetType())
+			debugLog.Printf("no translator element for {%d, %d}\n", key.EnterpriseID, key.FieldID)
 		}
 	}
 
@@ -0,0 +1,13 @@
+/*
+Package psamp decodes the packet sections exported in PSAMP records.
+
+# About
+
+Packet Sampling (PSAMP, RFC 5476) exporters send (parts of) the selected
+packets in IPFIX records, using the dataLinkFrameSection,
+ipHeaderPacketSection and ipPayloadPacketSection Information Elements. This
+package decodes these sections into the headers of the link, network and
+transport layers. Sections are usually truncated, the decoders decode as many
+headers as the section contains.
+*/
+package psamp
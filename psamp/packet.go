@@ -0,0 +1,458 @@
+package psamp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// EtherTypes
+const (
+	EtherTypeIPv4      uint16 = 0x0800
+	EtherTypeVLAN      uint16 = 0x8100
+	EtherTypeIPv6      uint16 = 0x86dd
+	EtherTypeMPLS      uint16 = 0x8847
+	EtherTypeMPLSMulti uint16 = 0x8848
+	EtherTypeQinQ      uint16 = 0x88a8
+)
+
+// IP protocols
+const (
+	ProtocolICMP   uint8 = 1
+	ProtocolTCP    uint8 = 6
+	ProtocolUDP    uint8 = 17
+	ProtocolICMPv6 uint8 = 58
+)
+
+// IPv6 extension headers that are skipped to find the transport header
+const (
+	ipv6HopByHop    uint8 = 0
+	ipv6Routing     uint8 = 43
+	ipv6Fragment    uint8 = 44
+	ipv6DestOptions uint8 = 60
+)
+
+// TCP flags
+const (
+	TCPFlagFIN uint8 = 0x01
+	TCPFlagSYN uint8 = 0x02
+	TCPFlagRST uint8 = 0x04
+	TCPFlagPSH uint8 = 0x08
+	TCPFlagACK uint8 = 0x10
+	TCPFlagURG uint8 = 0x20
+)
+
+// Ethernet header
+type Ethernet struct {
+	Destination net.HardwareAddr
+	Source      net.HardwareAddr
+	EtherType   uint16
+}
+
+// VLAN is an IEEE 802.1Q tag
+type VLAN struct {
+	Priority     uint8
+	DropEligible bool
+	ID           uint16
+	EtherType    uint16
+}
+
+// MPLS is a label stack entry
+type MPLS struct {
+	Label         uint32
+	TrafficClass  uint8
+	BottomOfStack bool
+	TTL           uint8
+}
+
+// IPv4 header
+type IPv4 struct {
+	IHL            uint8
+	TOS            uint8
+	Length         uint16
+	ID             uint16
+	Flags          uint8
+	FragmentOffset uint16
+	TTL            uint8
+	Protocol       uint8
+	Checksum       uint16
+	Source         net.IP
+	Destination    net.IP
+}
+
+// IPv6 header
+type IPv6 struct {
+	TrafficClass  uint8
+	FlowLabel     uint32
+	PayloadLength uint16
+	NextHeader    uint8
+	HopLimit      uint8
+	Source        net.IP
+	Destination   net.IP
+}
+
+// TCP header
+type TCP struct {
+	SourcePort      uint16
+	DestinationPort uint16
+	Sequence        uint32
+	Acknowledgment  uint32
+	DataOffset      uint8
+	Flags           uint8
+	Window          uint16
+	Checksum        uint16
+}
+
+// UDP header
+type UDP struct {
+	SourcePort      uint16
+	DestinationPort uint16
+	Length          uint16
+	Checksum        uint16
+}
+
+// ICMP header, used for both ICMP and ICMPv6
+type ICMP struct {
+	Type     uint8
+	Code     uint8
+	Checksum uint16
+}
+
+// Packet contains the headers decoded from a packet section, headers that are
+// not present in the section are nil. Payload contains the bytes following
+// the last decoded header.
+type Packet struct {
+	Ethernet *Ethernet
+	VLANs    []VLAN
+	MPLS     []MPLS
+	IPv4     *IPv4
+	IPv6     *IPv6
+	TCP      *TCP
+	UDP      *UDP
+	ICMP     *ICMP
+	Payload  []byte
+
+	// Truncated is set if the section ended inside a header.
+	Truncated bool
+}
+
+// DecodeDataLink decodes a dataLinkFrameSection, which starts with the
+// Ethernet header.
+func DecodeDataLink(b []byte) *Packet {
+	p := new(Packet)
+	p.decodeEthernet(b)
+	return p
+}
+
+// DecodeIP decodes an ipHeaderPacketSection, which starts with the IPv4 or IPv6
+// header.
+func DecodeIP(b []byte) *Packet {
+	p := new(Packet)
+	p.decodeIP(b)
+	return p
+}
+
+// DecodePayload decodes an ipPayloadPacketSection, which starts with the header
+// of the transport protocol, as identified by the protocolIdentifier of the
+// flow.
+func DecodePayload(protocol uint8, b []byte) *Packet {
+	p := new(Packet)
+	p.decodeTransport(protocol, b)
+	return p
+}
+
+func (p *Packet) decodeEthernet(b []byte) {
+	if len(b) < 14 {
+		p.truncate(b)
+		return
+	}
+	p.Ethernet = &Ethernet{
+		Destination: net.HardwareAddr(b[0:6]),
+		Source:      net.HardwareAddr(b[6:12]),
+		EtherType:   binary.BigEndian.Uint16(b[12:]),
+	}
+	p.decodeEtherType(p.Ethernet.EtherType, b[14:])
+}
+
+func (p *Packet) decodeEtherType(etherType uint16, b []byte) {
+	switch etherType {
+	case EtherTypeVLAN, EtherTypeQinQ:
+		if len(b) < 4 {
+			p.truncate(b)
+			return
+		}
+		tci := binary.BigEndian.Uint16(b)
+		vlan := VLAN{
+			Priority:     uint8(tci >> 13),
+			DropEligible: tci&0x1000 != 0,
+			ID:           tci & 0x0fff,
+			EtherType:    binary.BigEndian.Uint16(b[2:]),
+		}
+		p.VLANs = append(p.VLANs, vlan)
+		p.decodeEtherType(vlan.EtherType, b[4:])
+	case EtherTypeMPLS, EtherTypeMPLSMulti:
+		p.decodeMPLS(b)
+	case EtherTypeIPv4, EtherTypeIPv6:
+		p.decodeIP(b)
+	default:
+		p.Payload = b
+	}
+}
+
+func (p *Packet) decodeMPLS(b []byte) {
+	for {
+		if len(b) < 4 {
+			p.truncate(b)
+			return
+		}
+		entry := binary.BigEndian.Uint32(b)
+		mpls := MPLS{
+			Label:         entry >> 12,
+			TrafficClass:  uint8(entry>>9) & 0x07,
+			BottomOfStack: entry&0x100 != 0,
+			TTL:           uint8(entry),
+		}
+		p.MPLS = append(p.MPLS, mpls)
+		b = b[4:]
+		if mpls.BottomOfStack {
+			break
+		}
+	}
+
+	// There is no protocol identifier in MPLS, guess it from the version
+	if len(b) > 0 {
+		switch b[0] >> 4 {
+		case 4, 6:
+			p.decodeIP(b)
+			return
+		}
+	}
+	p.Payload = b
+}
+
+func (p *Packet) decodeIP(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	switch b[0] >> 4 {
+	case 4:
+		p.decodeIPv4(b)
+	case 6:
+		p.decodeIPv6(b)
+	default:
+		p.Payload = b
+	}
+}
+
+func (p *Packet) decodeIPv4(b []byte) {
+	if len(b) < 20 {
+		p.truncate(b)
+		return
+	}
+	ip := &IPv4{
+		IHL:            b[0] & 0x0f,
+		TOS:            b[1],
+		Length:         binary.BigEndian.Uint16(b[2:]),
+		ID:             binary.BigEndian.Uint16(b[4:]),
+		Flags:          b[6] >> 5,
+		FragmentOffset: binary.BigEndian.Uint16(b[6:]) & 0x1fff,
+		TTL:            b[8],
+		Protocol:       b[9],
+		Checksum:       binary.BigEndian.Uint16(b[10:]),
+		Source:         net.IP(b[12:16]),
+		Destination:    net.IP(b[16:20]),
+	}
+	p.IPv4 = ip
+
+	size := int(ip.IHL) * 4
+	if size < 20 || size > len(b) {
+		p.truncate(b[20:])
+		return
+	}
+	if ip.FragmentOffset != 0 {
+		// Only the first fragment has the transport header
+		p.Payload = b[size:]
+		return
+	}
+	p.decodeTransport(ip.Protocol, b[size:])
+}
+
+func (p *Packet) decodeIPv6(b []byte) {
+	if len(b) < 40 {
+		p.truncate(b)
+		return
+	}
+	word := binary.BigEndian.Uint32(b)
+	ip := &IPv6{
+		TrafficClass:  uint8(word >> 20),
+		FlowLabel:     word & 0x000fffff,
+		PayloadLength: binary.BigEndian.Uint16(b[4:]),
+		NextHeader:    b[6],
+		HopLimit:      b[7],
+		Source:        net.IP(b[8:24]),
+		Destination:   net.IP(b[24:40]),
+	}
+	p.IPv6 = ip
+
+	next, b := ip.NextHeader, b[40:]
+	for {
+		switch next {
+		case ipv6HopByHop, ipv6Routing, ipv6DestOptions:
+			if len(b) < 2 || len(b) < 8+int(b[1])*8 {
+				p.truncate(b)
+				return
+			}
+			next, b = b[0], b[8+int(b[1])*8:]
+		case ipv6Fragment:
+			if len(b) < 8 {
+				p.truncate(b)
+				return
+			}
+			if binary.BigEndian.Uint16(b[2:])&0xfff8 != 0 {
+				// Only the first fragment has the transport header
+				p.Payload = b[8:]
+				return
+			}
+			next, b = b[0], b[8:]
+		default:
+			p.decodeTransport(next, b)
+			return
+		}
+	}
+}
+
+func (p *Packet) decodeTransport(protocol uint8, b []byte) {
+	switch protocol {
+	case ProtocolTCP:
+		if len(b) < 20 {
+			p.truncate(b)
+			return
+		}
+		tcp := &TCP{
+			SourcePort:      binary.BigEndian.Uint16(b[0:]),
+			DestinationPort: binary.BigEndian.Uint16(b[2:]),
+			Sequence:        binary.BigEndian.Uint32(b[4:]),
+			Acknowledgment:  binary.BigEndian.Uint32(b[8:]),
+			DataOffset:      b[12] >> 4,
+			Flags:           b[13],
+			Window:          binary.BigEndian.Uint16(b[14:]),
+			Checksum:        binary.BigEndian.Uint16(b[16:]),
+		}
+		p.TCP = tcp
+		if size := int(tcp.DataOffset) * 4; size >= 20 && size <= len(b) {
+			p.Payload = b[size:]
+		} else {
+			p.truncate(b[20:])
+		}
+	case ProtocolUDP:
+		if len(b) < 8 {
+			p.truncate(b)
+			return
+		}
+		p.UDP = &UDP{
+			SourcePort:      binary.BigEndian.Uint16(b[0:]),
+			DestinationPort: binary.BigEndian.Uint16(b[2:]),
+			Length:          binary.BigEndian.Uint16(b[4:]),
+			Checksum:        binary.BigEndian.Uint16(b[6:]),
+		}
+		p.Payload = b[8:]
+	case ProtocolICMP, ProtocolICMPv6:
+		if len(b) < 4 {
+			p.truncate(b)
+			return
+		}
+		p.ICMP = &ICMP{
+			Type:     b[0],
+			Code:     b[1],
+			Checksum: binary.BigEndian.Uint16(b[2:]),
+		}
+		p.Payload = b[4:]
+	default:
+		p.Payload = b
+	}
+}
+
+// truncate marks the packet as truncated, the remaining bytes are kept as
+// payload.
+func (p *Packet) truncate(b []byte) {
+	p.Truncated = true
+	if len(b) > 0 {
+		p.Payload = b
+	}
+}
+
+// String returns a one line summary of the packet.
+func (p *Packet) String() string {
+	var parts []string
+	if p.Ethernet != nil {
+		parts = append(parts, fmt.Sprintf("%s > %s", p.Ethernet.Source, p.Ethernet.Destination))
+	}
+	for _, vlan := range p.VLANs {
+		parts = append(parts, fmt.Sprintf("vlan %d", vlan.ID))
+	}
+	for _, mpls := range p.MPLS {
+		parts = append(parts, fmt.Sprintf("mpls %d", mpls.Label))
+	}
+
+	var source, destination string
+	switch {
+	case p.IPv4 != nil:
+		parts = append(parts, fmt.Sprintf("ipv4 ttl %d len %d", p.IPv4.TTL, p.IPv4.Length))
+		source, destination = p.IPv4.Source.String(), p.IPv4.Destination.String()
+	case p.IPv6 != nil:
+		parts = append(parts, fmt.Sprintf("ipv6 hlim %d len %d", p.IPv6.HopLimit, p.IPv6.PayloadLength))
+		source, destination = "["+p.IPv6.Source.String()+"]", "["+p.IPv6.Destination.String()+"]"
+	}
+
+	switch {
+	case p.TCP != nil:
+		parts = append(parts, fmt.Sprintf("tcp %s > %s [%s] seq %d win %d",
+			endpoint(source, p.TCP.SourcePort), endpoint(destination, p.TCP.DestinationPort),
+			tcpFlags(p.TCP.Flags), p.TCP.Sequence, p.TCP.Window))
+	case p.UDP != nil:
+		parts = append(parts, fmt.Sprintf("udp %s > %s len %d",
+			endpoint(source, p.UDP.SourcePort), endpoint(destination, p.UDP.DestinationPort), p.UDP.Length))
+	case p.ICMP != nil && source == "":
+		parts = append(parts, fmt.Sprintf("icmp type %d code %d", p.ICMP.Type, p.ICMP.Code))
+	case p.ICMP != nil:
+		parts = append(parts, fmt.Sprintf("icmp %s > %s type %d code %d",
+			source, destination, p.ICMP.Type, p.ICMP.Code))
+	case source != "":
+		parts = append(parts, fmt.Sprintf("%s > %s", source, destination))
+	}
+
+	if len(p.Payload) > 0 {
+		parts = append(parts, fmt.Sprintf("%d bytes payload", len(p.Payload)))
+	}
+	if p.Truncated {
+		parts = append(parts, "truncated")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func endpoint(address string, port uint16) string {
+	if address == "" {
+		return fmt.Sprintf("%d", port)
+	}
+	return fmt.Sprintf("%s.%d", address, port)
+}
+
+func tcpFlags(flags uint8) string {
+	var s []byte
+	for _, f := range []struct {
+		flag uint8
+		char byte
+	}{
+		{TCPFlagFIN, 'F'}, {TCPFlagSYN, 'S'}, {TCPFlagRST, 'R'},
+		{TCPFlagPSH, 'P'}, {TCPFlagACK, '.'}, {TCPFlagURG, 'U'},
+	} {
+		if flags&f.flag != 0 {
+			s = append(s, f.char)
+		}
+	}
+	if len(s) == 0 {
+		return "none"
+	}
+	return string(s)
+}
@@ -0,0 +1,208 @@
+package psamp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+var (
+	testMACSource      = []byte{0x00, 0x00, 0x5e, 0x00, 0x53, 0x01}
+	testMACDestination = []byte{0x00, 0x00, 0x5e, 0x00, 0x53, 0x02}
+)
+
+// Headers used to build the test sections, the checksums are not verified.
+var (
+	// Ethernet header for an IEEE 802.1Q tagged frame
+	ethernetVLAN = append(append(append([]byte(nil), testMACDestination...), testMACSource...), 0x81, 0x00)
+	// VLAN 100, priority 5, followed by a QinQ tag
+	vlan100 = []byte{0xa0, 0x64, 0x88, 0xa8}
+	// VLAN 200, followed by MPLS
+	vlan200 = []byte{0x00, 0xc8, 0x88, 0x47}
+	// MPLS label 16, TTL 64, followed by label 17 with bottom of stack
+	mplsStack = []byte{
+		0x00, 0x01, 0x00, 0x40,
+		0x00, 0x01, 0x11, 0x3f,
+	}
+	// IPv4 192.0.2.1 > 198.51.100.1, TTL 64, TCP
+	ipv4TCP = []byte{
+		0x45, 0x00, 0x00, 0x2c, 0x12, 0x34, 0x40, 0x00,
+		0x40, 0x06, 0x00, 0x00, 0xc0, 0x00, 0x02, 0x01,
+		0xc6, 0x33, 0x64, 0x01,
+	}
+	// TCP 49152 > 443, SYN and ACK, seq 1000, window 65535
+	tcpSynAck = []byte{
+		0xc0, 0x00, 0x01, 0xbb, 0x00, 0x00, 0x03, 0xe8,
+		0x00, 0x00, 0x00, 0x01, 0x50, 0x12, 0xff, 0xff,
+		0x00, 0x00, 0x00, 0x00,
+	}
+	// IPv6 2001:db8::1 > 2001:db8::2, hop limit 64, hop-by-hop options
+	ipv6HopByHopHeader = []byte{
+		0x60, 0x00, 0x00, 0x00, 0x00, 0x20, 0x00, 0x40,
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+	}
+	// Hop-by-hop options followed by a fragment header, with padding
+	hopByHopOptions = []byte{0x2c, 0x00, 0x01, 0x04, 0x00, 0x00, 0x00, 0x00}
+	// First fragment followed by UDP
+	firstFragment = []byte{0x11, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x2a}
+	// UDP 53 > 33000, length 12
+	udpDNS = []byte{0x00, 0x35, 0x80, 0xe8, 0x00, 0x0c, 0x00, 0x00}
+	// ICMP echo request
+	icmpEcho = []byte{0x08, 0x00, 0xf7, 0xff}
+)
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, part := range parts {
+		b = append(b, part...)
+	}
+	return b
+}
+
+func TestDecodeDataLink(t *testing.T) {
+	payload := []byte("data")
+	p := DecodeDataLink(concat(ethernetVLAN, vlan100, vlan200, mplsStack, ipv4TCP, tcpSynAck, payload))
+	if p.Truncated {
+		t.Fatal("expected packet not to be truncated")
+	}
+	if p.Ethernet == nil || !bytes.Equal(p.Ethernet.Source, testMACSource) ||
+		!bytes.Equal(p.Ethernet.Destination, testMACDestination) || p.Ethernet.EtherType != EtherTypeVLAN {
+		t.Fatalf("unexpected ethernet header %+v", p.Ethernet)
+	}
+	if len(p.VLANs) != 2 {
+		t.Fatalf("expected 2 VLAN tags, got %+v", p.VLANs)
+	}
+	if vlan := p.VLANs[0]; vlan.ID != 100 || vlan.Priority != 5 || vlan.DropEligible || vlan.EtherType != EtherTypeQinQ {
+		t.Fatalf("unexpected outer VLAN tag %+v", vlan)
+	}
+	if vlan := p.VLANs[1]; vlan.ID != 200 || vlan.EtherType != EtherTypeMPLS {
+		t.Fatalf("unexpected inner VLAN tag %+v", vlan)
+	}
+	want := []MPLS{{Label: 16, TTL: 64}, {Label: 17, BottomOfStack: true, TTL: 63}}
+	if len(p.MPLS) != 2 || p.MPLS[0] != want[0] || p.MPLS[1] != want[1] {
+		t.Fatalf("expected MPLS stack %+v, got %+v", want, p.MPLS)
+	}
+	if p.IPv4 == nil || p.IPv4.TTL != 64 || p.IPv4.Protocol != ProtocolTCP || p.IPv4.Flags != 2 || p.IPv4.ID != 0x1234 ||
+		!p.IPv4.Source.Equal(net.IP{192, 0, 2, 1}) || !p.IPv4.Destination.Equal(net.IP{198, 51, 100, 1}) {
+		t.Fatalf("unexpected IPv4 header %+v", p.IPv4)
+	}
+	if p.TCP == nil || p.TCP.SourcePort != 49152 || p.TCP.DestinationPort != 443 ||
+		p.TCP.Flags != TCPFlagSYN|TCPFlagACK || p.TCP.Sequence != 1000 || p.TCP.Acknowledgment != 1 {
+		t.Fatalf("unexpected TCP header %+v", p.TCP)
+	}
+	if !bytes.Equal(p.Payload, payload) {
+		t.Fatalf("unexpected payload %q", p.Payload)
+	}
+
+	s := "00:00:5e:00:53:01 > 00:00:5e:00:53:02, vlan 100, vlan 200, mpls 16, mpls 17, " +
+		"ipv4 ttl 64 len 44, tcp 192.0.2.1.49152 > 198.51.100.1.443 [S.] seq 1000 win 65535, 4 bytes payload"
+	if p.String() != s {
+		t.Fatalf("expected %q, got %q", s, p.String())
+	}
+}
+
+func TestDecodeIPv6(t *testing.T) {
+	p := DecodeIP(concat(ipv6HopByHopHeader, hopByHopOptions, firstFragment, udpDNS, []byte{1, 2, 3, 4}))
+	if p.Truncated {
+		t.Fatal("expected packet not to be truncated")
+	}
+	if p.IPv6 == nil || p.IPv6.HopLimit != 64 || p.IPv6.NextHeader != ipv6HopByHop || p.IPv6.PayloadLength != 32 ||
+		!p.IPv6.Source.Equal(net.ParseIP("2001:db8::1")) || !p.IPv6.Destination.Equal(net.ParseIP("2001:db8::2")) {
+		t.Fatalf("unexpected IPv6 header %+v", p.IPv6)
+	}
+	if p.UDP == nil || p.UDP.SourcePort != 53 || p.UDP.DestinationPort != 33000 || p.UDP.Length != 12 {
+		t.Fatalf("unexpected UDP header %+v", p.UDP)
+	}
+	if s := "ipv6 hlim 64 len 32, udp [2001:db8::1].53 > [2001:db8::2].33000 len 12, 4 bytes payload"; p.String() != s {
+		t.Fatalf("expected %q, got %q", s, p.String())
+	}
+
+	// Later fragments don't have the transport header
+	fragment := []byte{0x11, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x2a}
+	p = DecodeIP(concat(ipv6HopByHopHeader, hopByHopOptions, fragment, udpDNS))
+	if p.UDP != nil || !bytes.Equal(p.Payload, udpDNS) {
+		t.Fatalf("expected fragment to be kept as payload, got %+v", p)
+	}
+}
+
+func TestDecodeIPv4Fragment(t *testing.T) {
+	header := append([]byte(nil), ipv4TCP...)
+	header[6], header[7] = 0x00, 0x10 // fragment offset 16
+	p := DecodeIP(concat(header, tcpSynAck))
+	if p.IPv4 == nil || p.IPv4.FragmentOffset != 16 || p.TCP != nil || !bytes.Equal(p.Payload, tcpSynAck) {
+		t.Fatalf("expected fragment to be kept as payload, got %+v", p)
+	}
+	if s := "ipv4 ttl 64 len 44, 192.0.2.1 > 198.51.100.1, 20 bytes payload"; p.String() != s {
+		t.Fatalf("expected %q, got %q", s, p.String())
+	}
+}
+
+func TestDecodePayload(t *testing.T) {
+	p := DecodePayload(ProtocolICMP, icmpEcho)
+	if p.ICMP == nil || p.ICMP.Type != 8 || p.ICMP.Code != 0 || p.ICMP.Checksum != 0xf7ff {
+		t.Fatalf("unexpected ICMP header %+v", p.ICMP)
+	}
+	if s := "icmp type 8 code 0"; p.String() != s {
+		t.Fatalf("expected %q, got %q", s, p.String())
+	}
+	header := append([]byte(nil), ipv4TCP...)
+	header[9] = ProtocolICMP
+	p = DecodeIP(concat(header, icmpEcho))
+	if s := "ipv4 ttl 64 len 44, icmp 192.0.2.1 > 198.51.100.1 type 8 code 0"; p.String() != s {
+		t.Fatalf("expected %q, got %q", s, p.String())
+	}
+
+	p = DecodePayload(ProtocolTCP, tcpSynAck)
+	if s := "tcp 49152 > 443 [S.] seq 1000 win 65535"; p.String() != s {
+		t.Fatalf("expected %q, got %q", s, p.String())
+	}
+
+	p = DecodePayload(ProtocolUDP, udpDNS)
+	if p.UDP == nil || p.UDP.DestinationPort != 33000 || len(p.Payload) != 0 {
+		t.Fatalf("unexpected UDP header %+v", p.UDP)
+	}
+
+	// Unknown protocols are kept as payload
+	p = DecodePayload(47, []byte{1, 2, 3})
+	if p.TCP != nil || p.UDP != nil || p.ICMP != nil || len(p.Payload) != 3 {
+		t.Fatalf("expected unknown protocol to be kept as payload, got %+v", p)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		packet *Packet
+		want   string
+	}{
+		{"ethernet", DecodeDataLink(ethernetVLAN[:10]), "10 bytes payload, truncated"},
+		{"vlan", DecodeDataLink(concat(ethernetVLAN, vlan100[:2])),
+			"00:00:5e:00:53:01 > 00:00:5e:00:53:02, 2 bytes payload, truncated"},
+		{"mpls", DecodeDataLink(concat(ethernetVLAN, vlan100, vlan200, mplsStack[:6])),
+			"00:00:5e:00:53:01 > 00:00:5e:00:53:02, vlan 100, vlan 200, mpls 16, 2 bytes payload, truncated"},
+		{"ipv4", DecodeIP(ipv4TCP[:12]), "12 bytes payload, truncated"},
+		{"ipv4 options", DecodeIP(concat([]byte{0x46}, ipv4TCP[1:])),
+			"ipv4 ttl 64 len 44, 192.0.2.1 > 198.51.100.1, truncated"},
+		{"ipv6", DecodeIP(ipv6HopByHopHeader[:30]), "30 bytes payload, truncated"},
+		{"ipv6 extension header", DecodeIP(concat(ipv6HopByHopHeader, hopByHopOptions[:4])),
+			"ipv6 hlim 64 len 32, [2001:db8::1] > [2001:db8::2], 4 bytes payload, truncated"},
+		{"ipv6 fragment header", DecodeIP(concat(ipv6HopByHopHeader, hopByHopOptions, firstFragment[:6])),
+			"ipv6 hlim 64 len 32, [2001:db8::1] > [2001:db8::2], 6 bytes payload, truncated"},
+		{"tcp", DecodeIP(concat(ipv4TCP, tcpSynAck[:16])),
+			"ipv4 ttl 64 len 44, 192.0.2.1 > 198.51.100.1, 16 bytes payload, truncated"},
+		{"tcp options", DecodePayload(ProtocolTCP, concat(tcpSynAck[:12], []byte{0x60}, tcpSynAck[13:])),
+			"tcp 49152 > 443 [S.] seq 1000 win 65535, truncated"},
+		{"udp", DecodePayload(ProtocolUDP, udpDNS[:4]), "4 bytes payload, truncated"},
+		{"icmp", DecodePayload(ProtocolICMP, icmpEcho[:2]), "2 bytes payload, truncated"},
+	} {
+		if !test.packet.Truncated {
+			t.Errorf("%s: expected packet to be truncated", test.name)
+		}
+		if s := test.packet.String(); s != test.want {
+			t.Errorf("%s: expected %q, got %q", test.name, test.want, s)
+		}
+	}
+}
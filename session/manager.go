@@ -0,0 +1,228 @@
+package session
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultExporterTimeout is the time after which exporters that have not sent
+// any packets are evicted by the Manager.
+const DefaultExporterTimeout = time.Hour
+
+// Exporter describes an exporter known to the Manager.
+type Exporter struct {
+	Addr      string
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// NetFlow, IPFIX or sFlow versions received from the exporter
+	Versions []uint16
+	// Number of templates in the session of the exporter
+	Templates int
+}
+
+// Manager owns the sessions of multiple exporters, typically identified by
+// their remote address. Exporters that are idle for longer than the
+// IdleTimeout are evicted along with their session, unless they are acquired
+// by an open connection. It's safe for concurrent use.
+type Manager struct {
+	// IdleTimeout is the time after which an exporter is evicted, a zero
+	// timeout disables eviction.
+	IdleTimeout time.Duration
+
+	// TemplateTimeout is the template timeout for new sessions, see
+	// Session.SetTemplateTimeout.
+	TemplateTimeout time.Duration
+
+	// PendingSize and PendingAge are the pending data buffer limits for new
+	// sessions, see Session.SetPendingLimits. A zero size disables the
+	// buffer, which is the default.
+	PendingSize int
+	PendingAge  time.Duration
+
+	// OnExporter is called when a packet from a new exporter is received, or
+	// when a new exporter is acquired.
+	OnExporter func(addr string)
+
+	// OnTemplate is called when a template of an exporter is added, changed,
+	// withdrawn or expired. Refreshing a template with the same layout is not
+	// reported.
+	OnTemplate func(addr string, event TemplateEvent, domain uint32, t Template)
+
+	// OnEvict is called when an idle exporter is evicted.
+	OnEvict func(addr string)
+
+	mutex     sync.Mutex
+	exporters map[string]*managedExporter
+}
+
+type managedExporter struct {
+	Exporter
+	session *basicSession
+	// Number of open connections, see Acquire
+	refs int
+}
+
+// NewManager returns a new session manager using the default timeouts.
+func NewManager() *Manager {
+	return &Manager{
+		IdleTimeout:     DefaultExporterTimeout,
+		TemplateTimeout: DefaultTemplateTimeout,
+		PendingAge:      DefaultPendingAge,
+		exporters:       make(map[string]*managedExporter),
+	}
+}
+
+// Session returns the session for the exporter and records that a packet
+// with the given version was received. A new session is created for unknown
+// exporters.
+func (m *Manager) Session(addr string, version uint16) Session {
+	m.mutex.Lock()
+	e, found := m.exporters[addr]
+	if !found {
+		e = m.add(addr)
+	}
+	e.LastSeen = time.Now()
+	if !hasVersion(e.Versions, version) {
+		e.Versions = append(e.Versions, version)
+	}
+	m.mutex.Unlock()
+
+	if !found && m.OnExporter != nil {
+		m.OnExporter(addr)
+	}
+	return e.session
+}
+
+// Lookup returns the session for a known exporter.
+func (m *Manager) Lookup(addr string) (Session, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if e, found := m.exporters[addr]; found {
+		return e.session, true
+	}
+	return nil, false
+}
+
+// Add returns the session for the exporter, a new session is created for
+// unknown exporters. Unlike Session, it doesn't record that a packet was
+// received, which is useful to restore sessions at startup.
+func (m *Manager) Add(addr string) Session {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if e, found := m.exporters[addr]; found {
+		return e.session
+	}
+	return m.add(addr).session
+}
+
+// Acquire returns the session for the exporter and keeps it from being
+// evicted until Release is called, for exporters connecting over a stream
+// transport which may stay quiet for longer than the idle timeout. A new
+// session is created for unknown exporters.
+func (m *Manager) Acquire(addr string) Session {
+	m.mutex.Lock()
+	e, found := m.exporters[addr]
+	if !found {
+		e = m.add(addr)
+	}
+	e.refs++
+	m.mutex.Unlock()
+
+	if !found && m.OnExporter != nil {
+		m.OnExporter(addr)
+	}
+	return e.session
+}
+
+// Release undoes an Acquire of the exporter, the exporter is evicted once it
+// has been idle for the idle timeout after its last connection closed.
+func (m *Manager) Release(addr string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if e, found := m.exporters[addr]; found && e.refs > 0 {
+		e.refs--
+		e.LastSeen = time.Now()
+	}
+}
+
+// add creates a new exporter, the caller must hold the lock.
+func (m *Manager) add(addr string) *managedExporter {
+	now := time.Now()
+	e := &managedExporter{
+		Exporter: Exporter{Addr: addr, FirstSeen: now, LastSeen: now},
+		session:  New(),
+	}
+	e.session.SetTemplateTimeout(m.TemplateTimeout)
+	e.session.SetPendingLimits(m.PendingSize, m.PendingAge)
+	e.session.onTemplate = func(event TemplateEvent, domain uint32, t Template) {
+		if m.OnTemplate != nil {
+			m.OnTemplate(addr, event, domain, t)
+		}
+	}
+	m.exporters[addr] = e
+	return e
+}
+
+// Exporters returns the known exporters, ordered by address.
+func (m *Manager) Exporters() []Exporter {
+	m.mutex.Lock()
+	exporters := make([]Exporter, 0, len(m.exporters))
+	for _, e := range m.exporters {
+		info := e.Exporter
+		info.Versions = append([]uint16(nil), e.Versions...)
+		info.Templates = e.session.TemplateStats().Active
+		exporters = append(exporters, info)
+	}
+	m.mutex.Unlock()
+
+	sort.Slice(exporters, func(i, j int) bool {
+		return exporters[i].Addr < exporters[j].Addr
+	})
+	return exporters
+}
+
+// Expire evicts the exporters that have been idle for longer than the idle
+// timeout and are not acquired, and expires the templates in the sessions of the remaining
+// exporters. The number of evicted exporters is returned.
+func (m *Manager) Expire() int {
+	var (
+		now     = time.Now()
+		evicted []string
+		active  []*basicSession
+	)
+	m.mutex.Lock()
+	for addr, e := range m.exporters {
+		if m.IdleTimeout > 0 && e.refs == 0 && now.Sub(e.LastSeen) >= m.IdleTimeout {
+			delete(m.exporters, addr)
+			evicted = append(evicted, addr)
+		} else {
+			active = append(active, e.session)
+		}
+	}
+	m.mutex.Unlock()
+
+	for _, s := range active {
+		s.ExpireTemplates()
+	}
+	if m.OnEvict != nil {
+		for _, addr := range evicted {
+			m.OnEvict(addr)
+		}
+	}
+	return len(evicted)
+}
+
+// Sweep runs Expire every interval in the background, until stop is called.
+func (m *Manager) Sweep(interval time.Duration) (stop func()) {
+	return every(interval, func() { m.Expire() })
+}
+
+func hasVersion(versions []uint16, version uint16) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package session
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testField struct{ typ, length uint16 }
+
+func (f testField) GetType() uint16   { return f.typ }
+func (f testField) GetLength() uint16 { return f.length }
+
+type testTemplate struct {
+	id     uint16
+	fields []TemplateFieldSpecifier
+}
+
+func (t *testTemplate) ID() uint16                          { return t.id }
+func (t *testTemplate) GetFields() []TemplateFieldSpecifier { return t.fields }
+
+func (t *testTemplate) Size() int {
+	var size int
+	for _, f := range t.fields {
+		size += int(f.GetLength())
+	}
+	return size
+}
+
+func newTestTemplate(id uint16, lengths ...uint16) *testTemplate {
+	t := &testTemplate{id: id}
+	for i, length := range lengths {
+		t.fields = append(t.fields, testField{uint16(i + 1), length})
+	}
+	return t
+}
+
+// testEvents records the callbacks of a Manager.
+type testEvents struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (e *testEvents) add(format string, v ...interface{}) {
+	e.mutex.Lock()
+	e.events = append(e.events, fmt.Sprintf(format, v...))
+	e.mutex.Unlock()
+}
+
+func (e *testEvents) take() []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	events := e.events
+	e.events = nil
+	return events
+}
+
+func testManager() (*Manager, *testEvents) {
+	var (
+		m      = NewManager()
+		events = new(testEvents)
+	)
+	m.OnExporter = func(addr string) { events.add("exporter %s", addr) }
+	m.OnTemplate = func(addr string, event TemplateEvent, domain uint32, t Template) {
+		events.add("template %s %s %d/%d", addr, event, domain, t.ID())
+	}
+	m.OnEvict = func(addr string) { events.add("evict %s", addr) }
+	return m, events
+}
+
+func TestManagerCallbacks(t *testing.T) {
+	m, events := testManager()
+	s := m.Session("192.0.2.1:2055", 9)
+	if s != m.Session("192.0.2.1:2055", 10) {
+		t.Fatal("expected the same session for the same exporter")
+	}
+	s.AddTemplate(1, newTestTemplate(256, 4, 4))
+	s.AddTemplate(1, newTestTemplate(256, 4, 4))
+	s.AddTemplate(1, newTestTemplate(256, 4, 2))
+	s.RemoveTemplate(1, 256)
+
+	want := []string{
+		"exporter 192.0.2.1:2055",
+		"template 192.0.2.1:2055 added 1/256",
+		"template 192.0.2.1:2055 changed 1/256",
+		"template 192.0.2.1:2055 withdrawn 1/256",
+	}
+	if got := events.take(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected events %q, got %q", want, got)
+	}
+
+	// Restored sessions are not reported as new exporters
+	m.Add("192.0.2.2:2055")
+	m.Session("192.0.2.2:2055", 9)
+	if got := events.take(); len(got) != 0 {
+		t.Fatalf("unexpected events %q", got)
+	}
+	if _, found := m.Lookup("192.0.2.3:2055"); found {
+		t.Fatal("expected unknown exporter not to be found")
+	}
+}
+
+func TestManagerExporters(t *testing.T) {
+	m := NewManager()
+	m.Session("192.0.2.2:2055", 9)
+	m.Session("192.0.2.1:2055", 10).AddTemplate(1, newTestTemplate(256, 4))
+	m.Session("192.0.2.1:2055", 9)
+	m.Session("192.0.2.1:2055", 10)
+	m.Add("192.0.2.3:2055")
+
+	exporters := m.Exporters()
+	if len(exporters) != 3 {
+		t.Fatalf("expected 3 exporters, got %d", len(exporters))
+	}
+	for i, addr := range []string{"192.0.2.1:2055", "192.0.2.2:2055", "192.0.2.3:2055"} {
+		if exporters[i].Addr != addr {
+			t.Fatalf("exporter %d: expected %s, got %s", i, addr, exporters[i].Addr)
+		}
+	}
+	if !reflect.DeepEqual(exporters[0].Versions, []uint16{10, 9}) || exporters[0].Templates != 1 {
+		t.Fatalf("unexpected exporter %+v", exporters[0])
+	}
+	if !reflect.DeepEqual(exporters[1].Versions, []uint16{9}) || exporters[1].Templates != 0 {
+		t.Fatalf("unexpected exporter %+v", exporters[1])
+	}
+	if len(exporters[2].Versions) != 0 {
+		t.Fatalf("unexpected exporter %+v", exporters[2])
+	}
+
+	// The returned versions are a copy
+	exporters[0].Versions[0] = 5
+	if m.Exporters()[0].Versions[0] != 10 {
+		t.Fatal("expected the versions to be copied")
+	}
+}
+
+func TestManagerExpire(t *testing.T) {
+	m, events := testManager()
+	m.IdleTimeout = 100 * time.Millisecond
+	m.TemplateTimeout = 20 * time.Millisecond
+	m.Session("192.0.2.1:2055", 9)
+	m.Session("192.0.2.2:2055", 9)
+	m.Acquire("192.0.2.3:2055")
+	events.take()
+
+	time.Sleep(50 * time.Millisecond)
+	m.Session("192.0.2.2:2055", 9)
+	if n := m.Expire(); n != 0 {
+		t.Fatalf("expected no exporters to be evicted, got %d", n)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if n := m.Expire(); n != 1 {
+		t.Fatalf("expected 1 exporter to be evicted, got %d", n)
+	}
+	want := []string{"evict 192.0.2.1:2055"}
+	if got := events.take(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected events %q, got %q", want, got)
+	}
+
+	// The templates of the remaining exporters are expired
+	s := m.Session("192.0.2.2:2055", 9)
+	s.AddTemplate(1, newTestTemplate(256, 4))
+	events.take()
+	time.Sleep(25 * time.Millisecond)
+	m.Session("192.0.2.2:2055", 9)
+	m.Expire()
+	want = []string{"template 192.0.2.2:2055 expired 1/256"}
+	if got := events.take(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected events %q, got %q", want, got)
+	}
+	if _, found := m.Lookup("192.0.2.3:2055"); !found {
+		t.Fatal("expected acquired exporter not to be evicted")
+	}
+
+	// Released exporters are evicted once they're idle
+	m.Release("192.0.2.3:2055")
+	if n := m.Expire(); n != 0 {
+		t.Fatalf("expected no exporters to be evicted, got %d", n)
+	}
+	time.Sleep(110 * time.Millisecond)
+	m.Session("192.0.2.2:2055", 9)
+	m.Expire()
+	want = []string{"evict 192.0.2.3:2055"}
+	if got := events.take(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected events %q, got %q", want, got)
+	}
+}
+
+func TestManagerAcquire(t *testing.T) {
+	m, events := testManager()
+	s := m.Acquire("192.0.2.1:2055")
+	if s != m.Acquire("192.0.2.1:2055") || s != m.Session("192.0.2.1:2055", 10) {
+		t.Fatal("expected the same session for the same exporter")
+	}
+	want := []string{"exporter 192.0.2.1:2055"}
+	if got := events.take(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected events %q, got %q", want, got)
+	}
+
+	// The exporter stays pinned until every connection is released
+	m.IdleTimeout = time.Nanosecond
+	m.Release("192.0.2.1:2055")
+	time.Sleep(time.Millisecond)
+	if n := m.Expire(); n != 0 {
+		t.Fatalf("expected no exporters to be evicted, got %d", n)
+	}
+	m.Release("192.0.2.1:2055")
+	m.Release("192.0.2.1:2055")
+	time.Sleep(time.Millisecond)
+	if n := m.Expire(); n != 1 {
+		t.Fatalf("expected 1 exporter to be evicted, got %d", n)
+	}
+	m.Release("192.0.2.1:2055")
+}
@@ -2,7 +2,10 @@
 // decoders that need to track templates bound to a session.
 package session
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 const (
 	SCOPE_SYSTEM = 1
@@ -20,6 +23,17 @@ var ScopeTypes = map[uint16]string{
 	SCOPE_TEMPLATE     : "Template",
 }
 
+// Kinds of templates, used to withdraw all templates of a kind.
+const (
+	TEMPLATE_KIND_DATA = 1
+	TEMPLATE_KIND_OPTIONS = 2
+)
+
+// DefaultTemplateTimeout is the template lifetime for sessions over UDP, three
+// times the default template refresh interval of 10 minutes (RFC 7011 section
+// 8.4).
+const DefaultTemplateTimeout = 30 * time.Minute
+
 const (
 	OPTION_SAMPLER_ID = 48
 	OPTION_SAMPLER_MODE = 49
@@ -37,6 +51,21 @@ type Template interface {
 	GetFields() []TemplateFieldSpecifier
 }
 
+// OptionsTemplate is a Template describing option records, the scope fields
+// precede the fields in the option records.
+type OptionsTemplate interface {
+	Template
+	GetScopeFields() []TemplateFieldSpecifier
+}
+
+// TemplateKind returns the kind of the template.
+func TemplateKind(t Template) uint8 {
+	if _, is_option := t.(OptionsTemplate); is_option {
+		return TEMPLATE_KIND_OPTIONS
+	}
+	return TEMPLATE_KIND_DATA
+}
+
 type Field interface {
 	GetType() uint16
 	GetLength() uint16
@@ -48,9 +77,12 @@ type TypeID struct {
 	Type uint16
 }
 
+// OptionScope is the scope of an option value, such as the index of the
+// interface for interface scoped options. The index is 32 bits wide, as
+// interface indexes are (ingressInterface and egressInterface).
 type OptionScope struct {
 	Type uint16
-	Index uint16
+	Index uint32
 }
 
 type Option struct {
@@ -62,73 +94,476 @@ type Option struct {
 	Bytes []byte
 }
 
+// InformationElement describes an Information Element learned from the
+// exporter, as announced in the Information Element Type Options (RFC 5610).
+type InformationElement struct {
+	EnterpriseNumber uint32
+	Type uint16
+	// Abstract data type, as defined in the IANA registry (RFC 5610 section 3.1)
+	DataType uint8
+	Name string
+	Semantics uint8
+	Units uint16
+}
+
+// Template events, as reported by the Manager.
+const (
+	TEMPLATE_ADDED     TemplateEvent = 1
+	TEMPLATE_CHANGED   TemplateEvent = 2
+	TEMPLATE_WITHDRAWN TemplateEvent = 3
+	TEMPLATE_EXPIRED   TemplateEvent = 4
+)
+
+// TemplateEvent is a change to the templates of a session.
+type TemplateEvent uint8
+
+var templateEventNames = map[TemplateEvent]string{
+	TEMPLATE_ADDED:     "added",
+	TEMPLATE_CHANGED:   "changed",
+	TEMPLATE_WITHDRAWN: "withdrawn",
+	TEMPLATE_EXPIRED:   "expired",
+}
+
+func (e TemplateEvent) String() string {
+	if name, ok := templateEventNames[e]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// SameTemplate checks if two templates describe the same record layout, that
+// is, the same field types and lengths.
+func SameTemplate(a, b Template) bool {
+	if a.ID() != b.ID() || TemplateKind(a) != TemplateKind(b) {
+		return false
+	}
+	if !sameFields(a.GetFields(), b.GetFields()) {
+		return false
+	}
+	if oa, ok := a.(OptionsTemplate); ok {
+		return sameFields(oa.GetScopeFields(), b.(OptionsTemplate).GetScopeFields())
+	}
+	return true
+}
+
+func sameFields(a, b []TemplateFieldSpecifier) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].GetType() != b[i].GetType() || a[i].GetLength() != b[i].GetLength() {
+			return false
+		}
+	}
+	return true
+}
+
+// Default limits of the pending data buffer, see Session.SetPendingLimits.
+const (
+	DefaultPendingSize = 1 << 20
+	DefaultPendingAge  = time.Minute
+)
+
+// maxPendingSets limits the number of sets in the pending data buffer, so
+// small sets can't grow it past the size limit with their bookkeeping.
+const maxPendingSets = 4096
+
+// PendingSet is a Data Set or Data FlowSet that arrived before its template.
+type PendingSet struct {
+	Domain     uint32
+	TemplateID uint16
+	Data       []byte
+	Received   time.Time
+}
+
+// TemplateStats contains the template statistics of a session.
+type TemplateStats struct {
+	// Number of templates in the session
+	Active int
+	// Number of templates added or refreshed
+	Added uint64
+	// Number of templates withdrawn by the exporter
+	Withdrawn uint64
+	// Number of templates expired because they were not refreshed in time
+	Expired uint64
+	// Number of sets waiting in the pending data buffer
+	Pending int
+	// Number of pending sets dropped because of the size or age limits
+	PendingDropped uint64
+}
+
+// TemplateKey identifies a template within a session. Template IDs are unique
+// per Observation Domain (IPFIX) or Source ID (NetFlow version 9).
+type TemplateKey struct {
+	Domain uint32
+	ID     uint16
+}
+
+// OptionKey identifies an option type within a session.
+type OptionKey struct {
+	Domain uint32
+	TypeID
+}
+
+// TemplateEntry is a template in a session, with the time it was last added.
+type TemplateEntry struct {
+	Domain   uint32
+	Template Template
+	Updated  time.Time
+}
+
+// OptionEntry is an option value in a session.
+type OptionEntry struct {
+	Domain uint32
+	Option *Option
+}
+
+// Templates, record sizes and options are kept per domain, which is the
+// Observation Domain ID for IPFIX and the Source ID for NetFlow version 9.
 type Session interface {
 	// To keep track of maximum record sizes per template
-	GetRecordSize(uint16) (size int, found bool)
-	SetRecordSize(uint16, int)
+	GetRecordSize(domain uint32, id uint16) (size int, found bool)
+	SetRecordSize(domain uint32, id uint16, size int)
 
 	// To keep track of templates
-	AddTemplate(Template)
-	GetTemplate(uint16) (t Template, found bool)
-	SetOption(uint32, uint16, *Option)
-	GetOption(uint32, uint16, uint16, uint16) *Option
+	AddTemplate(domain uint32, t Template)
+	GetTemplate(domain uint32, id uint16) (t Template, found bool)
+
+	// To withdraw a single template, or all templates of a kind
+	RemoveTemplate(domain uint32, id uint16)
+	RemoveTemplates(domain uint32, kind uint8)
+
+	// To expire templates that are not refreshed within the timeout, a zero
+	// timeout disables expiry
+	SetTemplateTimeout(time.Duration)
+	ExpireTemplates() int
+	TemplateStats() TemplateStats
+
+	// To save and restore the templates and options of a session, restored
+	// templates keep the time they were last added
+	Templates() []TemplateEntry
+	RestoreTemplate(TemplateEntry)
+	Options() []OptionEntry
+
+	// To buffer sets that arrive before their template, until the template
+	// is registered. A zero size disables the buffer, which is the default
+	SetPendingLimits(size int, age time.Duration)
+	AddPending(domain uint32, id uint16, data []byte) bool
+	TakePending(domain uint32, id uint16) []PendingSet
+
+	SetOption(domain uint32, enterprise_number uint32, field_id uint16, option *Option)
+	GetOption(domain uint32, enterprise_number uint32, field_id uint16, scope_type uint16, scope_index uint32) *Option
+
+	// To keep track of Information Elements learned from the exporter
+	AddInformationElement(*InformationElement)
+	GetInformationElement(uint32, uint16) (ie *InformationElement, found bool)
+	InformationElements() []*InformationElement
 }
 
 type basicSession struct {
 	templates_mutex sync.RWMutex
-	templates       map[uint16]Template
-	sizes           map[uint16]int
+	templates       map[TemplateKey]Template
+	updated         map[TemplateKey]time.Time
+	sizes           map[TemplateKey]int
+	timeout         time.Duration
+	stats           TemplateStats
+	onTemplate      func(TemplateEvent, uint32, Template)
+	pending_mutex   sync.Mutex
+	pending         []PendingSet
+	pending_bytes   int
+	pending_size    int
+	pending_age     time.Duration
+	pending_dropped uint64
 	options_mutex   sync.RWMutex
-	options         map[TypeID]map[OptionScope]*Option
+	options         map[OptionKey]map[OptionScope]*Option
+	elements_mutex  sync.RWMutex
+	elements        map[TypeID]*InformationElement
 }
 
 func New() *basicSession {
 	return &basicSession{
-		templates: make(map[uint16]Template, 65536),
-		sizes:     make(map[uint16]int, 65536),
-		options:   make(map[TypeID]map[OptionScope]*Option, 256),
+		templates: make(map[TemplateKey]Template, 256),
+		updated:   make(map[TemplateKey]time.Time, 256),
+		sizes:     make(map[TemplateKey]int, 256),
+		options:   make(map[OptionKey]map[OptionScope]*Option, 256),
+		elements:  make(map[TypeID]*InformationElement),
 	}
 }
 
-func (s *basicSession) GetRecordSize(tid uint16) (size int, found bool) {
-	size, found = s.sizes[tid]
+func (s *basicSession) GetRecordSize(domain uint32, tid uint16) (size int, found bool) {
+	s.templates_mutex.RLock()
+	size, found = s.sizes[TemplateKey{domain, tid}]
+	s.templates_mutex.RUnlock()
 	return
 }
 
-func (s *basicSession) SetRecordSize(tid uint16, size int) {
-	if s.sizes[tid] < size {
-		s.sizes[tid] = size
+func (s *basicSession) SetRecordSize(domain uint32, tid uint16, size int) {
+	key := TemplateKey{domain, tid}
+	s.templates_mutex.Lock()
+	if s.sizes[key] < size {
+		s.sizes[key] = size
 	}
+	s.templates_mutex.Unlock()
 }
 
-func (s *basicSession) AddTemplate(t Template) {
+func (s *basicSession) AddTemplate(domain uint32, t Template) {
+	key := TemplateKey{domain, t.ID()}
 	s.templates_mutex.Lock()
-	s.templates[t.ID()] = t
+	old, found := s.templates[key]
+	s.templates[key] = t
+	s.updated[key] = time.Now()
+	s.stats.Added++
 	s.templates_mutex.Unlock()
+
+	switch {
+	case !found:
+		s.notify(TEMPLATE_ADDED, domain, t)
+	case !SameTemplate(old, t):
+		s.notify(TEMPLATE_CHANGED, domain, t)
+	}
 }
 
-func (s *basicSession) GetTemplate(id uint16) (t Template, found bool) {
+// GetTemplate returns the template, templates that are not refreshed within
+// the template timeout are expired on access.
+func (s *basicSession) GetTemplate(domain uint32, id uint16) (t Template, found bool) {
+	key := TemplateKey{domain, id}
 	s.templates_mutex.RLock()
-	t, found = s.templates[id]
+	t, found = s.templates[key]
+	expired := found && s.isExpired(key, time.Now())
 	s.templates_mutex.RUnlock()
+
+	if expired {
+		s.templates_mutex.Lock()
+		// The template may have been refreshed in the mean time
+		expired = s.isExpired(key, time.Now())
+		if expired {
+			s.remove(key)
+			s.stats.Expired++
+		}
+		s.templates_mutex.Unlock()
+		if expired {
+			s.notify(TEMPLATE_EXPIRED, domain, t)
+		}
+		return nil, false
+	}
 	return
 }
 
-func (this *basicSession) SetOption(enterprise_number uint32, field_id uint16, option *Option) {
+func (s *basicSession) RemoveTemplate(domain uint32, id uint16) {
+	key := TemplateKey{domain, id}
+	s.templates_mutex.Lock()
+	t, found := s.templates[key]
+	if found {
+		s.remove(key)
+		s.stats.Withdrawn++
+	}
+	s.templates_mutex.Unlock()
+	if found {
+		s.notify(TEMPLATE_WITHDRAWN, domain, t)
+	}
+}
+
+func (s *basicSession) RemoveTemplates(domain uint32, kind uint8) {
+	var removed []Template
+	s.templates_mutex.Lock()
+	for key, t := range s.templates {
+		if key.Domain == domain && TemplateKind(t) == kind {
+			s.remove(key)
+			s.stats.Withdrawn++
+			removed = append(removed, t)
+		}
+	}
+	s.templates_mutex.Unlock()
+	for _, t := range removed {
+		s.notify(TEMPLATE_WITHDRAWN, domain, t)
+	}
+}
+
+func (s *basicSession) SetTemplateTimeout(timeout time.Duration) {
+	s.templates_mutex.Lock()
+	s.timeout = timeout
+	s.templates_mutex.Unlock()
+}
+
+// ExpireTemplates removes all templates that are not refreshed within the
+// template timeout and returns the number of expired templates.
+func (s *basicSession) ExpireTemplates() int {
+	var (
+		now     = time.Now()
+		expired = make(map[TemplateKey]Template)
+	)
+	s.templates_mutex.Lock()
+	for key, t := range s.templates {
+		if s.isExpired(key, now) {
+			s.remove(key)
+			expired[key] = t
+		}
+	}
+	s.stats.Expired += uint64(len(expired))
+	s.templates_mutex.Unlock()
+
+	s.pending_mutex.Lock()
+	s.expirePending(now)
+	s.pending_mutex.Unlock()
+
+	for key, t := range expired {
+		s.notify(TEMPLATE_EXPIRED, key.Domain, t)
+	}
+	return len(expired)
+}
+
+func (s *basicSession) TemplateStats() TemplateStats {
+	s.templates_mutex.RLock()
+	stats := s.stats
+	stats.Active = len(s.templates)
+	s.templates_mutex.RUnlock()
+
+	s.pending_mutex.Lock()
+	stats.Pending = len(s.pending)
+	stats.PendingDropped = s.pending_dropped
+	s.pending_mutex.Unlock()
+	return stats
+}
+
+// SetPendingLimits enables the pending data buffer, which holds at most size
+// bytes of sets for at most age. A zero age keeps sets until the buffer is
+// full.
+func (s *basicSession) SetPendingLimits(size int, age time.Duration) {
+	s.pending_mutex.Lock()
+	s.pending_size = size
+	s.pending_age = age
+	s.evictPending(0)
+	s.pending_mutex.Unlock()
+}
+
+// AddPending adds a set that arrived before its template to the pending data
+// buffer, the oldest sets are dropped to make room. If the buffer is disabled,
+// the set is empty or the set exceeds the buffer size, false is returned.
+func (s *basicSession) AddPending(domain uint32, id uint16, data []byte) bool {
+	s.pending_mutex.Lock()
+	defer s.pending_mutex.Unlock()
+
+	if s.pending_size <= 0 || len(data) == 0 {
+		return false
+	}
+	if len(data) > s.pending_size {
+		s.pending_dropped++
+		return false
+	}
+	now := time.Now()
+	s.expirePending(now)
+	s.evictPending(len(data))
+	if len(s.pending) >= maxPendingSets {
+		s.dropPending()
+	}
+	s.pending = append(s.pending, PendingSet{Domain: domain, TemplateID: id, Data: data, Received: now})
+	s.pending_bytes += len(data)
+	return true
+}
+
+// TakePending removes the pending sets for the template from the buffer and
+// returns them, in the order they were received.
+func (s *basicSession) TakePending(domain uint32, id uint16) []PendingSet {
+	s.pending_mutex.Lock()
+	defer s.pending_mutex.Unlock()
+
+	s.expirePending(time.Now())
+	var (
+		taken []PendingSet
+		kept  = s.pending[:0]
+	)
+	for _, set := range s.pending {
+		if set.Domain == domain && set.TemplateID == id {
+			taken = append(taken, set)
+			s.pending_bytes -= len(set.Data)
+		} else {
+			kept = append(kept, set)
+		}
+	}
+	s.pending = kept
+	return taken
+}
+
+// expirePending drops the sets older than the age limit, the caller must hold
+// the pending lock.
+func (s *basicSession) expirePending(now time.Time) {
+	if s.pending_age <= 0 {
+		return
+	}
+	for len(s.pending) > 0 && now.Sub(s.pending[0].Received) >= s.pending_age {
+		s.dropPending()
+	}
+}
+
+// evictPending drops the oldest sets until there is room for size bytes, the
+// caller must hold the pending lock.
+func (s *basicSession) evictPending(size int) {
+	for len(s.pending) > 0 && s.pending_bytes+size > s.pending_size {
+		s.dropPending()
+	}
+}
+
+// dropPending drops the oldest set, the caller must hold the pending lock.
+func (s *basicSession) dropPending() {
+	s.pending_bytes -= len(s.pending[0].Data)
+	s.pending = s.pending[1:]
+	s.pending_dropped++
+}
+
+func (s *basicSession) Templates() []TemplateEntry {
+	s.templates_mutex.RLock()
+	entries := make([]TemplateEntry, 0, len(s.templates))
+	for key, t := range s.templates {
+		entries = append(entries, TemplateEntry{Domain: key.Domain, Template: t, Updated: s.updated[key]})
+	}
+	s.templates_mutex.RUnlock()
+	return entries
+}
+
+func (s *basicSession) RestoreTemplate(entry TemplateEntry) {
+	key := TemplateKey{entry.Domain, entry.Template.ID()}
+	s.templates_mutex.Lock()
+	s.templates[key] = entry.Template
+	s.updated[key] = entry.Updated
+	s.templates_mutex.Unlock()
+}
+
+// isExpired checks if the template timed out, the caller must hold the
+// templates lock.
+func (s *basicSession) isExpired(key TemplateKey, now time.Time) bool {
+	return s.timeout > 0 && now.Sub(s.updated[key]) >= s.timeout
+}
+
+// notify calls the template event handler, if any. It must be called without
+// holding the templates lock, so the handler can use the session.
+func (s *basicSession) notify(event TemplateEvent, domain uint32, t Template) {
+	if s.onTemplate != nil {
+		s.onTemplate(event, domain, t)
+	}
+}
+
+// remove removes a template, the caller must hold the templates lock.
+func (s *basicSession) remove(key TemplateKey) {
+	delete(s.templates, key)
+	delete(s.updated, key)
+	delete(s.sizes, key)
+}
+
+func (this *basicSession) SetOption(domain uint32, enterprise_number uint32, field_id uint16, option *Option) {
 	this.options_mutex.Lock()
-	type_id := TypeID{enterprise_number, field_id}
-	options, found := this.options[type_id]
+	key := OptionKey{domain, TypeID{enterprise_number, field_id}}
+	options, found := this.options[key]
 	if(!found) {
 		options = make(map[OptionScope]*Option, 256)
-		this.options[type_id] = options
+		this.options[key] = options
 	}
 	options[option.Scope] = option
 	this.options_mutex.Unlock()
 }
 
-func (this *basicSession) GetOption(enterprise_number uint32, field_id uint16, scope_type uint16, scope_index uint16) (*Option) {
+func (this *basicSession) GetOption(domain uint32, enterprise_number uint32, field_id uint16, scope_type uint16, scope_index uint32) (*Option) {
 	this.options_mutex.RLock()
-	options, found := this.options[TypeID{enterprise_number, field_id}]
+	options, found := this.options[OptionKey{domain, TypeID{enterprise_number, field_id}}]
 	if(!found) {
 		this.options_mutex.RUnlock()
 		return nil
@@ -146,6 +581,70 @@ func (this *basicSession) GetOption(enterprise_number uint32, field_id uint16, s
 	return option
 }
 
+func (this *basicSession) Options() []OptionEntry {
+	var entries []OptionEntry
+	this.options_mutex.RLock()
+	for key, options := range this.options {
+		for _, option := range options {
+			entries = append(entries, OptionEntry{Domain: key.Domain, Option: option})
+		}
+	}
+	this.options_mutex.RUnlock()
+	return entries
+}
+
+func (s *basicSession) AddInformationElement(ie *InformationElement) {
+	s.elements_mutex.Lock()
+	s.elements[TypeID{ie.EnterpriseNumber, ie.Type}] = ie
+	s.elements_mutex.Unlock()
+}
+
+func (s *basicSession) GetInformationElement(enterprise_number uint32, field_id uint16) (ie *InformationElement, found bool) {
+	s.elements_mutex.RLock()
+	ie, found = s.elements[TypeID{enterprise_number, field_id}]
+	s.elements_mutex.RUnlock()
+	return
+}
+
+func (s *basicSession) InformationElements() []*InformationElement {
+	s.elements_mutex.RLock()
+	elements := make([]*InformationElement, 0, len(s.elements))
+	for _, ie := range s.elements {
+		elements = append(elements, ie)
+	}
+	s.elements_mutex.RUnlock()
+	return elements
+}
+
+// Sweep expires the templates in the session every interval in the background,
+// until stop is called.
+func Sweep(s Session, interval time.Duration) (stop func()) {
+	return every(interval, func() { s.ExpireTemplates() })
+}
+
+// every calls fn every interval in the background, until stop is called.
+func every(interval time.Duration, fn func()) (stop func()) {
+	var (
+		ticker = time.NewTicker(interval)
+		done   = make(chan struct{})
+		once   sync.Once
+	)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
 // Test if basicSession is compliant
 var _ Session = (*basicSession)(nil)
 
@@ -0,0 +1,196 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPendingDisabled(t *testing.T) {
+	s := New()
+	if s.AddPending(1, 256, []byte{1, 2, 3, 4}) {
+		t.Fatal("expected set to be refused by the disabled buffer")
+	}
+	if s.AddPending(1, 256, nil) {
+		t.Fatal("expected empty set to be refused by the disabled buffer")
+	}
+
+	s.SetPendingLimits(16, 0)
+	if s.AddPending(1, 256, nil) {
+		t.Fatal("expected empty set to be refused")
+	}
+	if stats := s.TemplateStats(); stats.Pending != 0 || stats.PendingDropped != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestPendingSize(t *testing.T) {
+	s := New()
+	s.SetPendingLimits(10, 0)
+	if s.AddPending(1, 256, make([]byte, 11)) {
+		t.Fatal("expected set exceeding the buffer size to be refused")
+	}
+	for i := byte(0); i < 3; i++ {
+		if !s.AddPending(1, 256+uint16(i), []byte{i, i, i, i}) {
+			t.Fatalf("set %d: expected set to be added", i)
+		}
+	}
+
+	// The third set evicted the first one
+	if sets := s.TakePending(1, 256); len(sets) != 0 {
+		t.Fatalf("expected the oldest set to be evicted, got %+v", sets)
+	}
+	sets := s.TakePending(1, 257)
+	if len(sets) != 1 || sets[0].Data[0] != 1 {
+		t.Fatalf("unexpected pending sets %+v", sets)
+	}
+	if stats := s.TemplateStats(); stats.Pending != 1 || stats.PendingDropped != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// Shrinking the buffer evicts the sets that no longer fit
+	s.SetPendingLimits(2, 0)
+	if stats := s.TemplateStats(); stats.Pending != 0 || stats.PendingDropped != 3 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestPendingSets(t *testing.T) {
+	s := New()
+	s.SetPendingLimits(DefaultPendingSize, 0)
+	for i := 0; i < maxPendingSets+10; i++ {
+		if !s.AddPending(1, 256, []byte{byte(i)}) {
+			t.Fatalf("set %d: expected set to be added", i)
+		}
+	}
+	if stats := s.TemplateStats(); stats.Pending != maxPendingSets || stats.PendingDropped != 10 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if sets := s.TakePending(1, 256); sets[0].Data[0] != 10 {
+		t.Fatalf("expected the oldest sets to be evicted, got %+v", sets[0])
+	}
+}
+
+func TestPendingAge(t *testing.T) {
+	s := New()
+	s.SetPendingLimits(DefaultPendingSize, 20*time.Millisecond)
+	if !s.AddPending(1, 256, []byte{1, 2, 3, 4}) {
+		t.Fatal("expected set to be added")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if !s.AddPending(1, 257, []byte{5, 6, 7, 8}) {
+		t.Fatal("expected set to be added")
+	}
+
+	if sets := s.TakePending(1, 256); len(sets) != 0 {
+		t.Fatalf("expected the set to be expired, got %+v", sets)
+	}
+	if stats := s.TemplateStats(); stats.Pending != 1 || stats.PendingDropped != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// Sweeping expires the sets without new sets arriving
+	time.Sleep(30 * time.Millisecond)
+	s.ExpireTemplates()
+	if stats := s.TemplateStats(); stats.Pending != 0 || stats.PendingDropped != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestTemplateExpire(t *testing.T) {
+	var expired []uint16
+	s := New()
+	s.onTemplate = func(event TemplateEvent, domain uint32, t Template) {
+		if event == TEMPLATE_EXPIRED {
+			expired = append(expired, t.ID())
+		}
+	}
+	s.SetTemplateTimeout(40 * time.Millisecond)
+	s.AddTemplate(1, newTestTemplate(256, 4))
+	s.SetRecordSize(1, 256, 4)
+	s.AddTemplate(1, newTestTemplate(257, 4))
+	if _, found := s.GetTemplate(1, 256); !found {
+		t.Fatal("expected template 256 to be found")
+	}
+
+	// Refreshing a template restarts its timeout
+	time.Sleep(30 * time.Millisecond)
+	s.AddTemplate(1, newTestTemplate(257, 4))
+	time.Sleep(20 * time.Millisecond)
+
+	if _, found := s.GetTemplate(1, 256); found {
+		t.Fatal("expected template 256 to be expired on access")
+	}
+	if _, found := s.GetTemplate(1, 257); !found {
+		t.Fatal("expected refreshed template 257 to be found")
+	}
+	if len(expired) != 1 || expired[0] != 256 {
+		t.Fatalf("unexpected expired templates %v", expired)
+	}
+	if _, found := s.GetRecordSize(1, 256); found {
+		t.Fatal("expected the record size of the expired template to be removed")
+	}
+}
+
+func TestTemplateSweep(t *testing.T) {
+	s := New()
+	s.SetTemplateTimeout(10 * time.Millisecond)
+	s.AddTemplate(1, newTestTemplate(256, 4))
+	s.AddTemplate(2, newTestTemplate(256, 4))
+
+	stop := Sweep(s, 5*time.Millisecond)
+	defer stop()
+	for deadline := time.Now().Add(time.Second); s.TemplateStats().Active > 0; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the templates to be expired by the sweep")
+		}
+	}
+	stop()
+	stop()
+
+	if stats := s.TemplateStats(); stats.Expired != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestTemplateStats(t *testing.T) {
+	s := New()
+	s.SetTemplateTimeout(10 * time.Millisecond)
+	s.AddTemplate(1, newTestTemplate(256, 4))
+	s.AddTemplate(1, newTestTemplate(256, 4))
+	s.AddTemplate(1, newTestTemplate(257, 4))
+	s.AddTemplate(1, newTestTemplate(258, 4))
+	s.RemoveTemplate(1, 257)
+	s.RemoveTemplate(1, 259)
+
+	want := TemplateStats{Active: 2, Added: 4, Withdrawn: 1}
+	if stats := s.TemplateStats(); stats != want {
+		t.Fatalf("expected stats %+v, got %+v", want, stats)
+	}
+
+	time.Sleep(15 * time.Millisecond)
+	if n := s.ExpireTemplates(); n != 2 {
+		t.Fatalf("expected 2 templates to be expired, got %d", n)
+	}
+	want = TemplateStats{Active: 0, Added: 4, Withdrawn: 1, Expired: 2}
+	if stats := s.TemplateStats(); stats != want {
+		t.Fatalf("expected stats %+v, got %+v", want, stats)
+	}
+}
+
+func TestTemplateTimeoutDisabled(t *testing.T) {
+	s := New()
+	s.SetTemplateTimeout(10 * time.Millisecond)
+	s.AddTemplate(1, newTestTemplate(256, 4))
+	s.SetTemplateTimeout(0)
+	time.Sleep(15 * time.Millisecond)
+
+	if n := s.ExpireTemplates(); n != 0 {
+		t.Fatalf("expected no templates to be expired, got %d", n)
+	}
+	if _, found := s.GetTemplate(1, 256); !found {
+		t.Fatal("expected template 256 to be found")
+	}
+	if stats := s.TemplateStats(); stats.Active != 1 || stats.Expired != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,10 @@
+package sflow
+
+import "io"
+
+// Read a single sFlow datagram from the provided reader and decode all the
+// samples.
+func Read(r io.Reader) (*Datagram, error) {
+	d := new(Datagram)
+	return d, d.Unmarshal(r)
+}
@@ -0,0 +1,13 @@
+/*
+Package sflow contains decoders for the sFlow version 5 protocol.
+
+About
+
+sFlow is a sampling technology for monitoring traffic in switched and routed
+networks. An sFlow Agent embedded in the network device exports datagrams
+containing randomly sampled packet headers (flow samples) and periodic
+interface counters (counter samples) to an sFlow Collector.
+
+The datagram format is specified at http://sflow.org/sflow_version_5.txt
+*/
+package sflow
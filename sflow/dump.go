@@ -0,0 +1,108 @@
+package sflow
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+func Dump(d *Datagram) {
+	fmt.Println("sFlow version 5 datagram", d.Header)
+	fmt.Printf("  %d samples:\n", len(d.Samples))
+	for i, s := range d.Samples {
+		switch s := s.(type) {
+		case *FlowSample:
+			if s.Expanded {
+				fmt.Printf("    sample %d (expanded flow sample):\n", i)
+			} else {
+				fmt.Printf("    sample %d (flow sample):\n", i)
+			}
+			fmt.Println("      sequence:", s.SequenceNumber)
+			fmt.Println("      source:  ", s.SourceIDType, s.SourceIDIndex)
+			fmt.Println("      rate:    ", s.SamplingRate)
+			fmt.Println("      pool:    ", s.SamplePool)
+			fmt.Println("      drops:   ", s.Drops)
+			fmt.Println("      input:   ", s.InputFormat, s.Input)
+			fmt.Println("      output:  ", s.OutputFormat, s.Output)
+			for j, r := range s.Records {
+				switch r := r.(type) {
+				case *RawPacketHeader:
+					fmt.Printf("      record %d (raw packet header):\n", j)
+					fmt.Println("        protocol:", r.HeaderProtocol)
+					fmt.Println("        length:  ", r.FrameLength)
+					fmt.Println("        stripped:", r.Stripped)
+					fmt.Printf("        %d header bytes:\n", len(r.Header))
+					fmt.Println(hex.Dump(r.Header))
+
+				case *ExtendedSwitch:
+					fmt.Printf("      record %d (extended switch):\n", j)
+					fmt.Println("        srcVlan: ", r.SrcVLAN)
+					fmt.Println("        srcPrio: ", r.SrcPriority)
+					fmt.Println("        dstVlan: ", r.DstVLAN)
+					fmt.Println("        dstPrio: ", r.DstPriority)
+
+				case *UnknownRecord:
+					fmt.Printf("      record %d (format %d), %d raw bytes:\n", j, r.DataFormat, len(r.Bytes))
+					fmt.Println(hex.Dump(r.Bytes))
+				}
+			}
+
+		case *CounterSample:
+			if s.Expanded {
+				fmt.Printf("    sample %d (expanded counter sample):\n", i)
+			} else {
+				fmt.Printf("    sample %d (counter sample):\n", i)
+			}
+			fmt.Println("      sequence:", s.SequenceNumber)
+			fmt.Println("      source:  ", s.SourceIDType, s.SourceIDIndex)
+			for j, r := range s.Records {
+				switch r := r.(type) {
+				case *GenericInterfaceCounters:
+					fmt.Printf("      record %d (generic interface counters):\n", j)
+					fmt.Println("        ifIndex:      ", r.IfIndex)
+					fmt.Println("        ifType:       ", r.IfType)
+					fmt.Println("        ifSpeed:      ", r.IfSpeed)
+					fmt.Println("        ifDirection:  ", r.IfDirection)
+					fmt.Println("        ifStatus:     ", r.IfStatus)
+					fmt.Println("        ifInOctets:   ", r.IfInOctets)
+					fmt.Println("        ifInUcast:    ", r.IfInUcastPkts)
+					fmt.Println("        ifInMcast:    ", r.IfInMulticastPkts)
+					fmt.Println("        ifInBcast:    ", r.IfInBroadcastPkts)
+					fmt.Println("        ifInDiscards: ", r.IfInDiscards)
+					fmt.Println("        ifInErrors:   ", r.IfInErrors)
+					fmt.Println("        ifInUnknown:  ", r.IfInUnknownProtos)
+					fmt.Println("        ifOutOctets:  ", r.IfOutOctets)
+					fmt.Println("        ifOutUcast:   ", r.IfOutUcastPkts)
+					fmt.Println("        ifOutMcast:   ", r.IfOutMulticastPkts)
+					fmt.Println("        ifOutBcast:   ", r.IfOutBroadcastPkts)
+					fmt.Println("        ifOutDiscards:", r.IfOutDiscards)
+					fmt.Println("        ifOutErrors:  ", r.IfOutErrors)
+					fmt.Println("        ifPromisc:    ", r.IfPromiscuousMode)
+
+				case *EthernetInterfaceCounters:
+					fmt.Printf("      record %d (ethernet interface counters):\n", j)
+					fmt.Println("        alignmentErrors:   ", r.Dot3StatsAlignmentErrors)
+					fmt.Println("        fcsErrors:         ", r.Dot3StatsFCSErrors)
+					fmt.Println("        singleCollisions:  ", r.Dot3StatsSingleCollisionFrames)
+					fmt.Println("        multipleCollisions:", r.Dot3StatsMultipleCollisionFrames)
+					fmt.Println("        sqeTestErrors:     ", r.Dot3StatsSQETestErrors)
+					fmt.Println("        deferred:          ", r.Dot3StatsDeferredTransmissions)
+					fmt.Println("        lateCollisions:    ", r.Dot3StatsLateCollisions)
+					fmt.Println("        excessiveCollision:", r.Dot3StatsExcessiveCollisions)
+					fmt.Println("        macTransmitErrors: ", r.Dot3StatsInternalMacTransmitErrors)
+					fmt.Println("        carrierSenseErrors:", r.Dot3StatsCarrierSenseErrors)
+					fmt.Println("        frameTooLongs:     ", r.Dot3StatsFrameTooLongs)
+					fmt.Println("        macReceiveErrors:  ", r.Dot3StatsInternalMacReceiveErrors)
+					fmt.Println("        symbolErrors:      ", r.Dot3StatsSymbolErrors)
+
+				case *UnknownRecord:
+					fmt.Printf("      record %d (format %d), %d raw bytes:\n", j, r.DataFormat, len(r.Bytes))
+					fmt.Println(hex.Dump(r.Bytes))
+				}
+			}
+
+		case *UnknownSample:
+			fmt.Printf("    sample %d (format %d), %d raw bytes:\n", i, s.DataFormat, len(s.Bytes))
+			fmt.Println(hex.Dump(s.Bytes))
+		}
+	}
+}
@@ -0,0 +1,577 @@
+package sflow
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/tehmaze/netflow/read"
+)
+
+const (
+	// Version word in the Datagram Header
+	Version uint32 = 0x00000005
+
+	// maxLength is the maximum length of a sample or record, anything larger
+	// can not be transported in a single UDP datagram.
+	maxLength = 65535
+)
+
+// Agent address types
+const (
+	AddressIPv4 uint32 = 1
+	AddressIPv6 uint32 = 2
+)
+
+// Sample formats (enterprise 0)
+const (
+	FormatFlowSample            uint32 = 1
+	FormatCounterSample         uint32 = 2
+	FormatExpandedFlowSample    uint32 = 3
+	FormatExpandedCounterSample uint32 = 4
+)
+
+// Flow record formats (enterprise 0)
+const (
+	FormatRawPacketHeader uint32 = 1
+	FormatExtendedSwitch  uint32 = 1001
+)
+
+// Counter record formats (enterprise 0)
+const (
+	FormatGenericInterfaceCounters  uint32 = 1
+	FormatEthernetInterfaceCounters uint32 = 2
+)
+
+// Header protocols used in the Raw Packet Header flow record
+const (
+	HeaderProtocolEthernet uint32 = 1
+	HeaderProtocolIPv4     uint32 = 11
+	HeaderProtocolIPv6     uint32 = 12
+)
+
+func errProtocol(f string, v ...interface{}) error {
+	return fmt.Errorf("protocol error: "+f, v...)
+}
+
+// Datagram is an sFlow version 5 datagram, it consists of a Datagram Header
+// followed by the samples.
+type Datagram struct {
+	Header  DatagramHeader
+	Samples []Sample
+}
+
+func (d *Datagram) Unmarshal(r io.Reader) error {
+	if err := d.Header.Unmarshal(r); err != nil {
+		return err
+	}
+	d.Samples = make([]Sample, 0)
+	for i := uint32(0); i < d.Header.SamplesCount; i++ {
+		var (
+			header SampleHeader
+			data   []byte
+			err    error
+		)
+		if data, err = header.Unmarshal(r); err != nil {
+			return err
+		}
+
+		var s Sample
+		switch header.DataFormat {
+		case FormatFlowSample, FormatExpandedFlowSample:
+			s = &FlowSample{Expanded: header.DataFormat == FormatExpandedFlowSample}
+		case FormatCounterSample, FormatExpandedCounterSample:
+			s = &CounterSample{Expanded: header.DataFormat == FormatExpandedCounterSample}
+		default:
+			s = &UnknownSample{DataFormat: header.DataFormat}
+		}
+		if err = s.Unmarshal(bytes.NewBuffer(data)); err != nil {
+			return err
+		}
+		d.Samples = append(d.Samples, s)
+	}
+	return nil
+}
+
+// DatagramHeader is the sFlow version 5 Datagram Header
+type DatagramHeader struct {
+	Version          uint32
+	AgentAddressType uint32
+	AgentAddress     net.IP
+	SubAgentID       uint32
+	SequenceNumber   uint32
+	Uptime           time.Duration // 32 bit milliseconds
+	SamplesCount     uint32
+}
+
+func (h DatagramHeader) String() string {
+	return fmt.Sprintf("v=%d, agent=%s, sub agent=%d, seq=%d, uptime=%s, samples=%d",
+		h.Version, h.AgentAddress, h.SubAgentID, h.SequenceNumber, h.Uptime, h.SamplesCount)
+}
+
+func (h *DatagramHeader) Unmarshal(r io.Reader) error {
+	if err := read.Uint32(&h.Version, r); err != nil {
+		return err
+	}
+	if h.Version != Version {
+		return fmt.Errorf("version %d is not a valid sFlow datagram version", h.Version)
+	}
+	if err := read.Uint32(&h.AgentAddressType, r); err != nil {
+		return err
+	}
+	switch h.AgentAddressType {
+	case AddressIPv4:
+		h.AgentAddress = make(net.IP, 4)
+	case AddressIPv6:
+		h.AgentAddress = make(net.IP, 16)
+	default:
+		return errProtocol("unknown agent address type %d", h.AgentAddressType)
+	}
+	if _, err := io.ReadFull(r, h.AgentAddress); err != nil {
+		return err
+	}
+	if err := read.Uint32(&h.SubAgentID, r); err != nil {
+		return err
+	}
+	if err := read.Uint32(&h.SequenceNumber, r); err != nil {
+		return err
+	}
+	var u uint32
+	if err := read.Uint32(&u, r); err != nil {
+		return err
+	}
+	h.Uptime = time.Duration(u) * time.Millisecond
+	if err := read.Uint32(&h.SamplesCount, r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SampleHeader precedes every sample and record, it contains the data format
+// and the length of the data that follows.
+type SampleHeader struct {
+	// DataFormat is the 20 bit enterprise number and 12 bit format
+	DataFormat uint32
+	Length     uint32
+}
+
+// Enterprise is the enterprise number of the data format.
+func (h SampleHeader) Enterprise() uint32 {
+	return h.DataFormat >> 12
+}
+
+// Format is the enterprise specific format number of the data format.
+func (h SampleHeader) Format() uint32 {
+	return h.DataFormat & 0x0fff
+}
+
+// Unmarshal the header and returns the data described by the header.
+func (h *SampleHeader) Unmarshal(r io.Reader) ([]byte, error) {
+	if err := read.Uint32(&h.DataFormat, r); err != nil {
+		return nil, err
+	}
+	if err := read.Uint32(&h.Length, r); err != nil {
+		return nil, err
+	}
+	if h.Length > maxLength {
+		return nil, errProtocol("data length %d out of bounds", h.Length)
+	}
+	data := make([]byte, h.Length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Sample is a flow sample, counter sample or a sample of an unknown format.
+type Sample interface {
+	Unmarshal(io.Reader) error
+}
+
+// Interface formats, as found in the two most significant bits of the input
+// and output interface of a FlowSample.
+const (
+	InterfaceSingle    uint32 = 0
+	InterfaceDiscarded uint32 = 1
+	InterfaceMultiple  uint32 = 2
+)
+
+// FlowSample is a (expanded) flow sample, the fields of the compact format
+// are expanded upon decoding.
+type FlowSample struct {
+	Expanded       bool
+	SequenceNumber uint32
+	SourceIDType   uint32
+	SourceIDIndex  uint32
+	SamplingRate   uint32
+	SamplePool     uint32
+	Drops          uint32
+	InputFormat    uint32
+	Input          uint32
+	OutputFormat   uint32
+	Output         uint32
+	Records        []FlowRecord
+}
+
+func (s *FlowSample) Unmarshal(r io.Reader) error {
+	if err := read.Uint32(&s.SequenceNumber, r); err != nil {
+		return err
+	}
+	if s.Expanded {
+		if err := read.Uint32(&s.SourceIDType, r); err != nil {
+			return err
+		}
+		if err := read.Uint32(&s.SourceIDIndex, r); err != nil {
+			return err
+		}
+	} else {
+		var u uint32
+		if err := read.Uint32(&u, r); err != nil {
+			return err
+		}
+		s.SourceIDType, s.SourceIDIndex = u>>24, u&0x00ffffff
+	}
+	if err := read.Uint32(&s.SamplingRate, r); err != nil {
+		return err
+	}
+	if err := read.Uint32(&s.SamplePool, r); err != nil {
+		return err
+	}
+	if err := read.Uint32(&s.Drops, r); err != nil {
+		return err
+	}
+	if s.Expanded {
+		if err := read.Uint32(&s.InputFormat, r); err != nil {
+			return err
+		}
+		if err := read.Uint32(&s.Input, r); err != nil {
+			return err
+		}
+		if err := read.Uint32(&s.OutputFormat, r); err != nil {
+			return err
+		}
+		if err := read.Uint32(&s.Output, r); err != nil {
+			return err
+		}
+	} else {
+		var u uint32
+		if err := read.Uint32(&u, r); err != nil {
+			return err
+		}
+		s.InputFormat, s.Input = u>>30, u&0x3fffffff
+		if err := read.Uint32(&u, r); err != nil {
+			return err
+		}
+		s.OutputFormat, s.Output = u>>30, u&0x3fffffff
+	}
+
+	var count uint32
+	if err := read.Uint32(&count, r); err != nil {
+		return err
+	}
+	s.Records = make([]FlowRecord, 0)
+	for i := uint32(0); i < count; i++ {
+		var (
+			header SampleHeader
+			data   []byte
+			err    error
+		)
+		if data, err = header.Unmarshal(r); err != nil {
+			return err
+		}
+
+		var record FlowRecord
+		switch header.DataFormat {
+		case FormatRawPacketHeader:
+			record = new(RawPacketHeader)
+		case FormatExtendedSwitch:
+			record = new(ExtendedSwitch)
+		default:
+			record = &UnknownRecord{DataFormat: header.DataFormat}
+		}
+		if err = record.Unmarshal(bytes.NewBuffer(data)); err != nil {
+			return err
+		}
+		s.Records = append(s.Records, record)
+	}
+	return nil
+}
+
+// FlowRecord is a record contained in a FlowSample.
+type FlowRecord interface {
+	Unmarshal(io.Reader) error
+}
+
+// RawPacketHeader is a flow record containing the (truncated) header of the
+// sampled packet.
+type RawPacketHeader struct {
+	// HeaderProtocol is the format of the header, such as Ethernet or IPv4
+	HeaderProtocol uint32
+	// FrameLength is the original length of the packet before sampling
+	FrameLength uint32
+	// Stripped is the number of octets removed from the packet
+	Stripped uint32
+	// Header are the header bytes of the sampled packet
+	Header []byte
+}
+
+func (h *RawPacketHeader) Unmarshal(r io.Reader) error {
+	if err := read.Uint32(&h.HeaderProtocol, r); err != nil {
+		return err
+	}
+	if err := read.Uint32(&h.FrameLength, r); err != nil {
+		return err
+	}
+	if err := read.Uint32(&h.Stripped, r); err != nil {
+		return err
+	}
+	var l uint32
+	if err := read.Uint32(&l, r); err != nil {
+		return err
+	}
+	if l > maxLength {
+		return errProtocol("header length %d out of bounds", l)
+	}
+	h.Header = make([]byte, l)
+	if _, err := io.ReadFull(r, h.Header); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ExtendedSwitch is a flow record containing the layer 2 switching
+// information of the sampled packet.
+type ExtendedSwitch struct {
+	SrcVLAN     uint32
+	SrcPriority uint32
+	DstVLAN     uint32
+	DstPriority uint32
+}
+
+func (s *ExtendedSwitch) Unmarshal(r io.Reader) error {
+	if err := read.Uint32(&s.SrcVLAN, r); err != nil {
+		return err
+	}
+	if err := read.Uint32(&s.SrcPriority, r); err != nil {
+		return err
+	}
+	if err := read.Uint32(&s.DstVLAN, r); err != nil {
+		return err
+	}
+	if err := read.Uint32(&s.DstPriority, r); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CounterSample is a (expanded) counter sample, the fields of the compact
+// format are expanded upon decoding.
+type CounterSample struct {
+	Expanded       bool
+	SequenceNumber uint32
+	SourceIDType   uint32
+	SourceIDIndex  uint32
+	Records        []CounterRecord
+}
+
+func (s *CounterSample) Unmarshal(r io.Reader) error {
+	if err := read.Uint32(&s.SequenceNumber, r); err != nil {
+		return err
+	}
+	if s.Expanded {
+		if err := read.Uint32(&s.SourceIDType, r); err != nil {
+			return err
+		}
+		if err := read.Uint32(&s.SourceIDIndex, r); err != nil {
+			return err
+		}
+	} else {
+		var u uint32
+		if err := read.Uint32(&u, r); err != nil {
+			return err
+		}
+		s.SourceIDType, s.SourceIDIndex = u>>24, u&0x00ffffff
+	}
+
+	var count uint32
+	if err := read.Uint32(&count, r); err != nil {
+		return err
+	}
+	s.Records = make([]CounterRecord, 0)
+	for i := uint32(0); i < count; i++ {
+		var (
+			header SampleHeader
+			data   []byte
+			err    error
+		)
+		if data, err = header.Unmarshal(r); err != nil {
+			return err
+		}
+
+		var record CounterRecord
+		switch header.DataFormat {
+		case FormatGenericInterfaceCounters:
+			record = new(GenericInterfaceCounters)
+		case FormatEthernetInterfaceCounters:
+			record = new(EthernetInterfaceCounters)
+		default:
+			record = &UnknownRecord{DataFormat: header.DataFormat}
+		}
+		if err = record.Unmarshal(bytes.NewBuffer(data)); err != nil {
+			return err
+		}
+		s.Records = append(s.Records, record)
+	}
+	return nil
+}
+
+// CounterRecord is a record contained in a CounterSample.
+type CounterRecord interface {
+	Unmarshal(io.Reader) error
+}
+
+// GenericInterfaceCounters are the generic interface counters (RFC 2233)
+type GenericInterfaceCounters struct {
+	IfIndex            uint32
+	IfType             uint32
+	IfSpeed            uint64
+	IfDirection        uint32
+	IfStatus           uint32
+	IfInOctets         uint64
+	IfInUcastPkts      uint32
+	IfInMulticastPkts  uint32
+	IfInBroadcastPkts  uint32
+	IfInDiscards       uint32
+	IfInErrors         uint32
+	IfInUnknownProtos  uint32
+	IfOutOctets        uint64
+	IfOutUcastPkts     uint32
+	IfOutMulticastPkts uint32
+	IfOutBroadcastPkts uint32
+	IfOutDiscards      uint32
+	IfOutErrors        uint32
+	IfPromiscuousMode  uint32
+}
+
+func (c *GenericInterfaceCounters) Unmarshal(r io.Reader) error {
+	if err := read.Uint32(&c.IfIndex, r); err != nil {
+		return err
+	}
+	if err := read.Uint32(&c.IfType, r); err != nil {
+		return err
+	}
+	if err := read.Uint64(&c.IfSpeed, r); err != nil {
+		return err
+	}
+	if err := read.Uint32(&c.IfDirection, r); err != nil {
+		return err
+	}
+	if err := read.Uint32(&c.IfStatus, r); err != nil {
+		return err
+	}
+	if err := read.Uint64(&c.IfInOctets, r); err != nil {
+		return err
+	}
+	for _, v := range []*uint32{
+		&c.IfInUcastPkts,
+		&c.IfInMulticastPkts,
+		&c.IfInBroadcastPkts,
+		&c.IfInDiscards,
+		&c.IfInErrors,
+		&c.IfInUnknownProtos,
+	} {
+		if err := read.Uint32(v, r); err != nil {
+			return err
+		}
+	}
+	if err := read.Uint64(&c.IfOutOctets, r); err != nil {
+		return err
+	}
+	for _, v := range []*uint32{
+		&c.IfOutUcastPkts,
+		&c.IfOutMulticastPkts,
+		&c.IfOutBroadcastPkts,
+		&c.IfOutDiscards,
+		&c.IfOutErrors,
+		&c.IfPromiscuousMode,
+	} {
+		if err := read.Uint32(v, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EthernetInterfaceCounters are the Ethernet interface counters (RFC 2358)
+type EthernetInterfaceCounters struct {
+	Dot3StatsAlignmentErrors           uint32
+	Dot3StatsFCSErrors                 uint32
+	Dot3StatsSingleCollisionFrames     uint32
+	Dot3StatsMultipleCollisionFrames   uint32
+	Dot3StatsSQETestErrors             uint32
+	Dot3StatsDeferredTransmissions     uint32
+	Dot3StatsLateCollisions            uint32
+	Dot3StatsExcessiveCollisions       uint32
+	Dot3StatsInternalMacTransmitErrors uint32
+	Dot3StatsCarrierSenseErrors        uint32
+	Dot3StatsFrameTooLongs             uint32
+	Dot3StatsInternalMacReceiveErrors  uint32
+	Dot3StatsSymbolErrors              uint32
+}
+
+func (c *EthernetInterfaceCounters) Unmarshal(r io.Reader) error {
+	for _, v := range []*uint32{
+		&c.Dot3StatsAlignmentErrors,
+		&c.Dot3StatsFCSErrors,
+		&c.Dot3StatsSingleCollisionFrames,
+		&c.Dot3StatsMultipleCollisionFrames,
+		&c.Dot3StatsSQETestErrors,
+		&c.Dot3StatsDeferredTransmissions,
+		&c.Dot3StatsLateCollisions,
+		&c.Dot3StatsExcessiveCollisions,
+		&c.Dot3StatsInternalMacTransmitErrors,
+		&c.Dot3StatsCarrierSenseErrors,
+		&c.Dot3StatsFrameTooLongs,
+		&c.Dot3StatsInternalMacReceiveErrors,
+		&c.Dot3StatsSymbolErrors,
+	} {
+		if err := read.Uint32(v, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnknownSample is a sample of a format we can't decode, the raw bytes are
+// stored in stead.
+type UnknownSample struct {
+	DataFormat uint32
+	Bytes      []byte
+}
+
+func (s *UnknownSample) Unmarshal(r io.Reader) error {
+	buffer := new(bytes.Buffer)
+	if _, err := buffer.ReadFrom(r); err != nil {
+		return err
+	}
+	s.Bytes = buffer.Bytes()
+	return nil
+}
+
+// UnknownRecord is a flow or counter record of a format we can't decode, the
+// raw bytes are stored in stead.
+type UnknownRecord struct {
+	DataFormat uint32
+	Bytes      []byte
+}
+
+func (u *UnknownRecord) Unmarshal(r io.Reader) error {
+	buffer := new(bytes.Buffer)
+	if _, err := buffer.ReadFrom(r); err != nil {
+		return err
+	}
+	u.Bytes = buffer.Bytes()
+	return nil
+}
@@ -0,0 +1,153 @@
+package sflow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// words encodes the values as big endian 32 bit words.
+func words(v ...uint32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, u := range v {
+		binary.BigEndian.PutUint32(b[4*i:], u)
+	}
+	return b
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func testDatagramHeader(samples uint32) []byte {
+	return join(
+		words(Version, AddressIPv4),
+		[]byte{192, 0, 2, 1},
+		words(7, 1000, 60000, samples),
+	)
+}
+
+func testFlowSample() []byte {
+	header := []byte{0x45, 0x00, 0x00, 0x54}
+	raw := join(words(HeaderProtocolIPv4, 84, 4, uint32(len(header))), header)
+	body := join(
+		words(42, 3<<24|5, 256, 1024, 0, 1, 2, 2),
+		words(FormatRawPacketHeader, uint32(len(raw))), raw,
+		words(FormatExtendedSwitch, 16, 10, 0, 20, 1),
+	)
+	return join(words(FormatFlowSample, uint32(len(body))), body)
+}
+
+func testCounterSample() []byte {
+	generic := words(
+		5, 6, 0, 1000000000, 1, 3,
+		0, 123456,
+		1, 2, 3, 4, 5, 6,
+		0, 654321,
+		7, 8, 9, 10, 11, 0,
+	)
+	body := join(
+		words(43, 0<<24|5, 1),
+		words(FormatGenericInterfaceCounters, uint32(len(generic))), generic,
+	)
+	return join(words(FormatCounterSample, uint32(len(body))), body)
+}
+
+func TestDatagramUnmarshal(t *testing.T) {
+	data := join(
+		testDatagramHeader(3),
+		testFlowSample(),
+		testCounterSample(),
+		words(1<<12|1, 4, 0xdeadbeef),
+	)
+
+	d, err := Read(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := DatagramHeader{
+		Version:          Version,
+		AgentAddressType: AddressIPv4,
+		AgentAddress:     net.IP{192, 0, 2, 1},
+		SubAgentID:       7,
+		SequenceNumber:   1000,
+		Uptime:           time.Minute,
+		SamplesCount:     3,
+	}
+	if !reflect.DeepEqual(d.Header, expect) {
+		t.Fatalf("expected %+v, got %+v", expect, d.Header)
+	}
+	if len(d.Samples) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(d.Samples))
+	}
+
+	fs, ok := d.Samples[0].(*FlowSample)
+	if !ok {
+		t.Fatalf("expected flow sample, got %T", d.Samples[0])
+	}
+	if fs.SourceIDType != 3 || fs.SourceIDIndex != 5 || fs.SamplingRate != 256 || fs.Input != 1 {
+		t.Fatalf("unexpected flow sample %+v", fs)
+	}
+	if fs.OutputFormat != InterfaceSingle || fs.Output != 2 {
+		t.Fatalf("unexpected output interface %d/%d", fs.OutputFormat, fs.Output)
+	}
+	if len(fs.Records) != 2 {
+		t.Fatalf("expected 2 flow records, got %d", len(fs.Records))
+	}
+	raw, ok := fs.Records[0].(*RawPacketHeader)
+	if !ok || raw.HeaderProtocol != HeaderProtocolIPv4 || raw.FrameLength != 84 || !bytes.Equal(raw.Header, []byte{0x45, 0x00, 0x00, 0x54}) {
+		t.Fatalf("unexpected raw packet header %+v", fs.Records[0])
+	}
+	sw, ok := fs.Records[1].(*ExtendedSwitch)
+	if !ok || sw.SrcVLAN != 10 || sw.DstVLAN != 20 || sw.DstPriority != 1 {
+		t.Fatalf("unexpected extended switch %+v", fs.Records[1])
+	}
+
+	cs, ok := d.Samples[1].(*CounterSample)
+	if !ok {
+		t.Fatalf("expected counter sample, got %T", d.Samples[1])
+	}
+	if len(cs.Records) != 1 {
+		t.Fatalf("expected 1 counter record, got %d", len(cs.Records))
+	}
+	gc, ok := cs.Records[0].(*GenericInterfaceCounters)
+	if !ok || gc.IfIndex != 5 || gc.IfSpeed != 1000000000 || gc.IfInOctets != 123456 || gc.IfOutOctets != 654321 || gc.IfOutErrors != 11 {
+		t.Fatalf("unexpected generic interface counters %+v", cs.Records[0])
+	}
+
+	us, ok := d.Samples[2].(*UnknownSample)
+	if !ok || us.DataFormat != 1<<12|1 || !bytes.Equal(us.Bytes, words(0xdeadbeef)) {
+		t.Fatalf("unexpected sample %+v", d.Samples[2])
+	}
+}
+
+func TestDatagramSamplesCount(t *testing.T) {
+	// A hostile samples count must not be trusted for allocation, decoding
+	// stops once the datagram is exhausted.
+	data := join(testDatagramHeader(0xffffffff), testFlowSample())
+	d, err := Read(bytes.NewBuffer(data))
+	if err == nil {
+		t.Fatal("expected error for truncated datagram")
+	}
+	if len(d.Samples) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(d.Samples))
+	}
+}
+
+func TestDatagramInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"version":      join(words(4, AddressIPv4), []byte{192, 0, 2, 1}, words(0, 0, 0, 0)),
+		"address type": join(words(Version, 3), []byte{192, 0, 2, 1}, words(0, 0, 0, 0)),
+		"length":       join(testDatagramHeader(1), words(FormatFlowSample, maxLength+1)),
+		"truncated":    testDatagramHeader(1)[:20],
+	}
+	for name, data := range tests {
+		if _, err := Read(bytes.NewBuffer(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
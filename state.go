@@ -0,0 +1,304 @@
+package netflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/netflow9"
+	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/translate"
+)
+
+// State contains the templates, options and learned Information Elements of
+// the sessions of a collector, so they survive a restart of the collector.
+// It's serialized as JSON.
+type State struct {
+	Exporters map[string]*SessionState `json:"exporters"`
+}
+
+// SessionState contains the templates, options and learned Information
+// Elements of a session.
+type SessionState struct {
+	Templates []TemplateState `json:"templates"`
+	Options   []OptionState   `json:"options,omitempty"`
+	Elements  []ElementState  `json:"elements,omitempty"`
+}
+
+// TemplateState is a NetFlow version 9 or IPFIX (Options) Template.
+type TemplateState struct {
+	Version     uint16       `json:"version"`
+	Domain      uint32       `json:"domain"`
+	ID          uint16       `json:"id"`
+	Options     bool         `json:"options,omitempty"`
+	ScopeFields []FieldState `json:"scope_fields,omitempty"`
+	Fields      []FieldState `json:"fields"`
+	Updated     time.Time    `json:"updated"`
+}
+
+// FieldState is a field specifier in a template.
+type FieldState struct {
+	Type             uint16 `json:"type"`
+	Length           uint16 `json:"length"`
+	EnterpriseBit    bool   `json:"enterprise_bit,omitempty"`
+	EnterpriseNumber uint32 `json:"enterprise_number,omitempty"`
+}
+
+// OptionState is an option value, the value is translated again when the
+// option is restored.
+type OptionState struct {
+	Version          uint16 `json:"version"`
+	Domain           uint32 `json:"domain"`
+	TemplateID       uint16 `json:"template_id"`
+	ScopeType        uint16 `json:"scope_type"`
+	ScopeIndex       uint32 `json:"scope_index"`
+	EnterpriseNumber uint32 `json:"enterprise_number,omitempty"`
+	Type             uint16 `json:"type"`
+	Bytes            []byte `json:"bytes"`
+}
+
+// ElementState is an Information Element learned from the Information Element
+// Type Options of an IPFIX exporter (RFC 5610).
+type ElementState struct {
+	EnterpriseNumber uint32 `json:"enterprise_number,omitempty"`
+	Type             uint16 `json:"type"`
+	DataType         uint8  `json:"data_type"`
+	Name             string `json:"name,omitempty"`
+	Semantics        uint8  `json:"semantics,omitempty"`
+	Units            uint16 `json:"units,omitempty"`
+}
+
+// NewSessionState returns the state of the session. Templates that are not
+// NetFlow version 9 or IPFIX templates are skipped.
+func NewSessionState(s session.Session) *SessionState {
+	var (
+		state    = new(SessionState)
+		versions = make(map[session.TemplateKey]uint16)
+	)
+	for _, entry := range s.Templates() {
+		t, ok := templateState(entry.Template)
+		if !ok {
+			continue
+		}
+		t.Domain = entry.Domain
+		t.Updated = entry.Updated
+		state.Templates = append(state.Templates, t)
+		versions[session.TemplateKey{Domain: t.Domain, ID: t.ID}] = t.Version
+	}
+	for _, entry := range s.Options() {
+		option := entry.Option
+		version, ok := versions[session.TemplateKey{Domain: entry.Domain, ID: option.TemplateID}]
+		if !ok {
+			// The template is gone, so the option is stale
+			continue
+		}
+		state.Options = append(state.Options, OptionState{
+			Version:          version,
+			Domain:           entry.Domain,
+			TemplateID:       option.TemplateID,
+			ScopeType:        option.Scope.Type,
+			ScopeIndex:       option.Scope.Index,
+			EnterpriseNumber: option.EnterpriseNumber,
+			Type:             option.Type,
+			Bytes:            option.Bytes,
+		})
+	}
+	for _, ie := range s.InformationElements() {
+		state.Elements = append(state.Elements, ElementState{
+			EnterpriseNumber: ie.EnterpriseNumber,
+			Type:             ie.Type,
+			DataType:         ie.DataType,
+			Name:             ie.Name,
+			Semantics:        ie.Semantics,
+			Units:            ie.Units,
+		})
+	}
+	return state
+}
+
+// Restore adds the templates, options and Information Elements to the session.
+// The Information Elements are restored first, so the options are translated
+// using them.
+func (state *SessionState) Restore(s session.Session) error {
+	for _, e := range state.Elements {
+		s.AddInformationElement(&session.InformationElement{
+			EnterpriseNumber: e.EnterpriseNumber,
+			Type:             e.Type,
+			DataType:         e.DataType,
+			Name:             e.Name,
+			Semantics:        e.Semantics,
+			Units:            e.Units,
+		})
+	}
+	for _, t := range state.Templates {
+		template, err := t.template()
+		if err != nil {
+			return err
+		}
+		s.RestoreTemplate(session.TemplateEntry{Domain: t.Domain, Template: template, Updated: t.Updated})
+	}
+
+	tr := translate.NewTranslate(s)
+	for _, o := range state.Options {
+		key := translate.Key{EnterpriseID: o.EnterpriseNumber, FieldID: o.Type}
+		if o.Version == netflow9.Version {
+			key = translate.NetFlow9Key(o.Type)
+		}
+		option := &session.Option{
+			TemplateID:       o.TemplateID,
+			Scope:            session.OptionScope{Type: o.ScopeType, Index: o.ScopeIndex},
+			EnterpriseNumber: o.EnterpriseNumber,
+			Type:             o.Type,
+			Bytes:            o.Bytes,
+		}
+		if element, ok := tr.Key(key); ok {
+			option.Value = translate.Bytes(o.Bytes, element.Type)
+		}
+		s.SetOption(o.Domain, o.EnterpriseNumber, o.Type, option)
+	}
+	return nil
+}
+
+// NewState returns the state of all exporters known to the session manager,
+// exporters without templates or learned Information Elements are skipped.
+func NewState(m *session.Manager) *State {
+	state := &State{Exporters: make(map[string]*SessionState)}
+	for _, e := range m.Exporters() {
+		if s, ok := m.Lookup(e.Addr); ok {
+			if es := NewSessionState(s); len(es.Templates) > 0 || len(es.Elements) > 0 {
+				state.Exporters[e.Addr] = es
+			}
+		}
+	}
+	return state
+}
+
+// Restore adds the sessions of the exporters to the session manager.
+func (state *State) Restore(m *session.Manager) error {
+	for addr, s := range state.Exporters {
+		if err := s.Restore(m.Add(addr)); err != nil {
+			return fmt.Errorf("netflow: error restoring %s: %v", addr, err)
+		}
+	}
+	return nil
+}
+
+// WriteState writes the state of the session manager to w.
+func WriteState(w io.Writer, m *session.Manager) error {
+	return json.NewEncoder(w).Encode(NewState(m))
+}
+
+// ReadState reads the state from r and restores it in the session manager.
+func ReadState(r io.Reader, m *session.Manager) error {
+	state := new(State)
+	if err := json.NewDecoder(r).Decode(state); err != nil {
+		return err
+	}
+	return state.Restore(m)
+}
+
+func templateState(t session.Template) (TemplateState, bool) {
+	state := TemplateState{ID: t.ID()}
+	switch t := t.(type) {
+	case *ipfix.TemplateRecord:
+		state.Version = ipfix.Version
+		state.Fields = ipfixFieldStates(t.Fields)
+	case *ipfix.OptionsTemplateRecord:
+		state.Version = ipfix.Version
+		state.Options = true
+		state.ScopeFields = ipfixFieldStates(t.ScopeFields)
+		state.Fields = ipfixFieldStates(t.Fields)
+	case *netflow9.TemplateRecord:
+		state.Version = netflow9.Version
+		for _, f := range t.Fields {
+			state.Fields = append(state.Fields, FieldState{Type: f.Type, Length: f.Length})
+		}
+	case *netflow9.OptionTemplateRecord:
+		state.Version = netflow9.Version
+		state.Options = true
+		for _, f := range t.Scopes {
+			state.ScopeFields = append(state.ScopeFields, FieldState{Type: f.Type, Length: f.Length})
+		}
+		for _, f := range t.Options {
+			state.Fields = append(state.Fields, FieldState{Type: f.Type, Length: f.Length})
+		}
+	default:
+		return state, false
+	}
+	return state, true
+}
+
+func ipfixFieldStates(fss ipfix.FieldSpecifiers) []FieldState {
+	states := make([]FieldState, len(fss))
+	for i, fs := range fss {
+		states[i] = FieldState{
+			Type:             fs.InformationElementID,
+			Length:           fs.Length,
+			EnterpriseBit:    fs.EnterpriseBitSet,
+			EnterpriseNumber: fs.EnterpriseNumber,
+		}
+	}
+	return states
+}
+
+func ipfixFieldSpecifiers(states []FieldState) ipfix.FieldSpecifiers {
+	fss := make(ipfix.FieldSpecifiers, len(states))
+	for i, f := range states {
+		fss[i] = ipfix.FieldSpecifier{
+			InformationElementID: f.Type,
+			Length:               f.Length,
+			EnterpriseNumber:     f.EnterpriseNumber,
+			// State files written before the enterprise bit was saved only
+			// have the number, which is only present with the bit set
+			EnterpriseBitSet: f.EnterpriseBit || f.EnterpriseNumber != 0,
+		}
+	}
+	return fss
+}
+
+// template converts the state back to a template record.
+func (t TemplateState) template() (session.Template, error) {
+	switch {
+	case t.Version == ipfix.Version && !t.Options:
+		fields := ipfixFieldSpecifiers(t.Fields)
+		return &ipfix.TemplateRecord{
+			TemplateID: t.ID,
+			FieldCount: uint16(len(fields)),
+			Fields:     fields,
+		}, nil
+
+	case t.Version == ipfix.Version:
+		scopes, fields := ipfixFieldSpecifiers(t.ScopeFields), ipfixFieldSpecifiers(t.Fields)
+		return &ipfix.OptionsTemplateRecord{
+			TemplateID:      t.ID,
+			FieldCount:      uint16(len(scopes) + len(fields)),
+			Fields:          fields,
+			ScopeFieldCount: uint16(len(scopes)),
+			ScopeFields:     scopes,
+		}, nil
+
+	case t.Version == netflow9.Version && !t.Options:
+		template := &netflow9.TemplateRecord{TemplateID: t.ID, FieldCount: uint16(len(t.Fields))}
+		for _, f := range t.Fields {
+			template.Fields = append(template.Fields, netflow9.FieldSpecifier{Type: f.Type, Length: f.Length})
+		}
+		return template, nil
+
+	case t.Version == netflow9.Version:
+		template := &netflow9.OptionTemplateRecord{
+			TemplateID:    t.ID,
+			ScopeLength:   uint16(4 * len(t.ScopeFields)),
+			OptionsLength: uint16(4 * len(t.Fields)),
+		}
+		for _, f := range t.ScopeFields {
+			template.Scopes = append(template.Scopes, netflow9.ScopeSpecifier{Type: f.Type, Length: f.Length})
+		}
+		for _, f := range t.Fields {
+			template.Options = append(template.Options, netflow9.FieldSpecifier{Type: f.Type, Length: f.Length})
+		}
+		return template, nil
+	}
+	return nil, fmt.Errorf("netflow: unsupported template version %d", t.Version)
+}
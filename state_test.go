@@ -0,0 +1,81 @@
+package netflow
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/session"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	m := session.NewManager()
+	s := m.Add("192.0.2.1:4739")
+	s.AddInformationElement(&session.InformationElement{
+		EnterpriseNumber: 29305,
+		Type:             1000,
+		DataType:         3, // unsigned32
+		Name:             "exampleCounter",
+		Units:            1,
+	})
+	s.AddTemplate(7, &ipfix.TemplateRecord{TemplateID: 300, FieldCount: 3, Fields: ipfix.FieldSpecifiers{
+		{InformationElementID: 8, Length: 4},
+		{InformationElementID: 1000, Length: 4, EnterpriseBitSet: true, EnterpriseNumber: 29305},
+		// Enterprise bit with a zero Private Enterprise Number
+		{InformationElementID: 5, Length: 2, EnterpriseBitSet: true},
+	}})
+	s.AddTemplate(7, &ipfix.OptionsTemplateRecord{TemplateID: 301, FieldCount: 2, ScopeFieldCount: 1,
+		ScopeFields: ipfix.FieldSpecifiers{{InformationElementID: 10, Length: 4}},
+		Fields:      ipfix.FieldSpecifiers{{InformationElementID: 1000, Length: 4, EnterpriseBitSet: true, EnterpriseNumber: 29305}},
+	})
+	s.SetOption(7, 29305, 1000, &session.Option{
+		TemplateID:       301,
+		Scope:            session.OptionScope{Type: session.SCOPE_INTERFACE, Index: 3},
+		EnterpriseNumber: 29305,
+		Type:             1000,
+		Bytes:            []byte{0, 0, 0x01, 0x00},
+	})
+
+	var buf bytes.Buffer
+	if err := WriteState(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	restored := session.NewManager()
+	if err := ReadState(&buf, restored); err != nil {
+		t.Fatal(err)
+	}
+	r, ok := restored.Lookup("192.0.2.1:4739")
+	if !ok {
+		t.Fatal("expected exporter to be restored")
+	}
+
+	ie, ok := r.GetInformationElement(29305, 1000)
+	if !ok || ie.Name != "exampleCounter" || ie.DataType != 3 || ie.Units != 1 {
+		t.Fatalf("unexpected information element %+v", ie)
+	}
+	for _, id := range []uint16{300, 301} {
+		expect, _ := s.GetTemplate(7, id)
+		template, ok := r.GetTemplate(7, id)
+		if !ok || !session.SameTemplate(expect, template) {
+			t.Fatalf("template %d: expected %v, got %v", id, expect, template)
+		}
+	}
+	template, _ := r.GetTemplate(7, 300)
+	if fs := template.(*ipfix.TemplateRecord).Fields[2]; !fs.EnterpriseBitSet || fs.EnterpriseNumber != 0 {
+		t.Fatalf("expected enterprise bit without number, got %+v", fs)
+	}
+
+	// The option is translated with the learned Information Element
+	option := r.GetOption(7, 29305, 1000, session.SCOPE_INTERFACE, 3)
+	if option == nil || option.Value != uint32(256) {
+		t.Fatalf("unexpected option %+v", option)
+	}
+}
+
+func TestStateElementsOnly(t *testing.T) {
+	m := session.NewManager()
+	m.Add("192.0.2.1:4739").AddInformationElement(&session.InformationElement{Type: 1000, EnterpriseNumber: 29305})
+	if state := NewState(m); len(state.Exporters) != 1 {
+		t.Fatalf("expected exporter with learned elements to be saved, got %d exporters", len(state.Exporters))
+	}
+}
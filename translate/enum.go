@@ -0,0 +1,40 @@
+package translate
+
+// Dictionary of enumerated values of information elements
+var enums = make(map[Key]map[uint64]string)
+
+// Enum returns the name of an enumerated value of the information element.
+func Enum(k Key, v uint64) (string, bool) {
+	values, ok := enums[k]
+	if !ok {
+		return "", false
+	}
+	name, ok := values[v]
+	return name, ok
+}
+
+// EnumName returns the name of an enumerated value of the information element,
+// the value is as returned by Bytes.
+func EnumName(k Key, value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case uint8:
+		return Enum(k, uint64(v))
+	case uint16:
+		return Enum(k, uint64(v))
+	case uint32:
+		return Enum(k, uint64(v))
+	case uint64:
+		return Enum(k, v)
+	}
+	return "", false
+}
+
+// Description returns a description of the value of the information element,
+// the name of an enumerated value or the decoded Juniper commonPropertiesId.
+// The value is as returned by Bytes for the raw bytes bs.
+func Description(k Key, value interface{}, bs []byte) (string, bool) {
+	if k == (Key{2636, 137}) {
+		return juniperCommonPropertiesDescription(bs)
+	}
+	return EnumName(k, value)
+}
@@ -0,0 +1,72 @@
+package translate
+
+import "fmt"
+
+// Juniper common properties ID types, the type is stored in the upper 6 bits
+// of the Juniper commonPropertiesId.
+const (
+	JuniperCPIDForwardingClass         = 0x01
+	JuniperCPIDForwardingException     = 0x02
+	JuniperCPIDForwardingNexthop       = 0x03
+	JuniperCPIDEgressLogicalInterface  = 0x04
+	JuniperCPIDIngressLogicalInterface = 0x05
+)
+
+var juniperCPIDNames = map[uint8]string{
+	JuniperCPIDForwardingClass:         "forwarding class and drop priority",
+	JuniperCPIDForwardingException:     "forwarding exception details",
+	JuniperCPIDForwardingNexthop:       "forwarding nexthop details",
+	JuniperCPIDEgressLogicalInterface:  "egress logical interface",
+	JuniperCPIDIngressLogicalInterface: "ingress logical interface",
+}
+
+func init() {
+	// Juniper Networks parameters, exported by MX series inline J-Flow. Most
+	// releases export the same bit layout in the IANA commonPropertiesId (137).
+	builtin[Key{2636, 137}] = InformationElementEntry{FieldID: 137, Name: "juniperCommonPropertiesId", Type: FieldTypes["unsigned64"]}
+}
+
+// JuniperCommonProperties splits a Juniper commonPropertiesId, which is either
+// 4 or 8 bytes, in the type stored in the upper 6 bits and its value, such as
+// the index of the ingress logical interface.
+func JuniperCommonProperties(bs []byte) (cpid uint8, value uint64, ok bool) {
+	if len(bs) != 4 && len(bs) != 8 {
+		return 0, 0, false
+	}
+	v, _ := Unsigned(bs)
+	bits := uint(len(bs) * 8)
+	cpid = uint8(v >> (bits - 6))
+	value = v & (1<<(bits-6) - 1)
+	return cpid, value, true
+}
+
+// JuniperCommonPropertiesName returns the name of the common properties ID
+// type.
+func JuniperCommonPropertiesName(cpid uint8) (string, bool) {
+	name, ok := juniperCPIDNames[cpid]
+	return name, ok
+}
+
+// JuniperForwardingClass splits the value of a forwarding class common
+// properties ID in the forwarding class and the drop priority.
+func JuniperForwardingClass(value uint64) (class, dropPriority uint8) {
+	return uint8(value >> 8), uint8(value)
+}
+
+// juniperCommonPropertiesDescription describes a Juniper commonPropertiesId,
+// such as "ingress logical interface 300".
+func juniperCommonPropertiesDescription(bs []byte) (string, bool) {
+	cpid, value, ok := JuniperCommonProperties(bs)
+	if !ok {
+		return "", false
+	}
+	name, ok := JuniperCommonPropertiesName(cpid)
+	if !ok {
+		return fmt.Sprintf("unknown type %d value %d", cpid, value), true
+	}
+	if cpid == JuniperCPIDForwardingClass {
+		class, dropPriority := JuniperForwardingClass(value)
+		return fmt.Sprintf("%s %d/%d", name, class, dropPriority), true
+	}
+	return fmt.Sprintf("%s %d", name, value), true
+}
@@ -0,0 +1,193 @@
+package translate
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// VariableLength is the field length indicating a variable length field.
+const VariableLength = 0xffff
+
+func errMarshal(v interface{}, t FieldType) error {
+	return fmt.Errorf("can't encode %v (%T) as field type %d", v, v, t)
+}
+
+// Marshal translates a go native type to a byte string, it is the reverse of
+// Bytes. Integers are encoded using reduced size encoding if length is smaller
+// than the size of the field type. If length is VariableLength, the natural
+// size of the field type or value is used.
+func Marshal(v interface{}, t FieldType, length uint16) ([]byte, error) {
+	var size int
+	switch t {
+	case Uint8, Int8, Boolean:
+		size = 1
+	case Uint16, Int16:
+		size = 2
+	case Uint32, Int32, Float32, DateTimeSeconds, Ipv4Address:
+		size = 4
+	case Uint64, Int64, Float64, DateTimeMilliseconds, DateTimeMicroseconds, DateTimeNanoseconds:
+		size = 8
+	case MacAddress:
+		size = 6
+	case Ipv6Address:
+		size = 16
+	}
+	if length != VariableLength && size > 0 {
+		if int(length) > size {
+			return nil, fmt.Errorf("field length %d exceeds size %d of field type %d", length, size, t)
+		}
+		size = int(length)
+	}
+
+	switch t {
+	case Uint8, Uint16, Uint32, Uint64:
+		u, ok := unsignedValue(v)
+		if !ok || (size < 8 && u>>uint(size*8) != 0) {
+			return nil, errMarshal(v, t)
+		}
+		return putUint(u, size), nil
+
+	case Int8, Int16, Int32, Int64:
+		i, ok := signedValue(v)
+		if !ok {
+			return nil, errMarshal(v, t)
+		}
+		if bits := uint(size * 8); bits < 64 && (i < -1<<(bits-1) || i >= 1<<(bits-1)) {
+			return nil, errMarshal(v, t)
+		}
+		return putUint(uint64(i), size), nil
+
+	case Float32, Float64:
+		var f float64
+		switch value := v.(type) {
+		case float32:
+			f = float64(value)
+		case float64:
+			f = value
+		default:
+			return nil, errMarshal(v, t)
+		}
+		if size == 4 {
+			return putUint(uint64(math.Float32bits(float32(f))), 4), nil
+		}
+		return putUint(math.Float64bits(f), 8), nil
+
+	case Boolean:
+		// RFC 7011 section 6.1.5, true is encoded as 1 and false as 2
+		value, ok := v.(bool)
+		if !ok {
+			return nil, errMarshal(v, t)
+		}
+		if value {
+			return []byte{1}, nil
+		}
+		return []byte{2}, nil
+
+	case DateTimeSeconds, DateTimeMilliseconds, DateTimeMicroseconds, DateTimeNanoseconds:
+		value, ok := v.(time.Time)
+		if !ok || size < 4 || (t != DateTimeSeconds && size < 8) {
+			return nil, errMarshal(v, t)
+		}
+		switch t {
+		case DateTimeSeconds:
+			return putUint(uint64(value.Unix()), 4), nil
+		case DateTimeMilliseconds:
+			return putUint(uint64(value.UnixNano()/int64(time.Millisecond)), 8), nil
+		case DateTimeMicroseconds:
+			return putUint(uint64(value.UnixNano()/int64(time.Microsecond)), 8), nil
+		default:
+			return putUint(uint64(value.UnixNano()), 8), nil
+		}
+
+	case Ipv4Address, Ipv6Address:
+		value, ok := v.(net.IP)
+		if ok && t == Ipv4Address {
+			value = value.To4()
+		} else if ok {
+			value = value.To16()
+		}
+		if !ok || value == nil || len(value) != size {
+			return nil, errMarshal(v, t)
+		}
+		return []byte(value), nil
+
+	case MacAddress:
+		value, ok := v.(net.HardwareAddr)
+		if !ok || len(value) != size {
+			return nil, errMarshal(v, t)
+		}
+		return []byte(value), nil
+
+	case String:
+		value, ok := v.(string)
+		if !ok {
+			return nil, errMarshal(v, t)
+		}
+		return padBytes([]byte(value), length)
+	}
+
+	// Octet arrays, structured data and unknown field types are encoded as-is
+	value, ok := v.([]byte)
+	if !ok {
+		return nil, errMarshal(v, t)
+	}
+	return padBytes(value, length)
+}
+
+// unsignedValue converts any integer type to an unsigned 64 bit integer.
+func unsignedValue(v interface{}) (uint64, bool) {
+	switch value := v.(type) {
+	case uint8:
+		return uint64(value), true
+	case uint16:
+		return uint64(value), true
+	case uint32:
+		return uint64(value), true
+	case uint64:
+		return value, true
+	case uint:
+		return uint64(value), true
+	}
+	if i, ok := signedValue(v); ok && i >= 0 {
+		return uint64(i), true
+	}
+	return 0, false
+}
+
+// signedValue converts any signed integer type to a signed 64 bit integer.
+func signedValue(v interface{}) (int64, bool) {
+	switch value := v.(type) {
+	case int8:
+		return int64(value), true
+	case int16:
+		return int64(value), true
+	case int32:
+		return int64(value), true
+	case int64:
+		return value, true
+	case int:
+		return int64(value), true
+	}
+	return 0, false
+}
+
+// putUint encodes the lower size bytes of u in network byte order.
+func putUint(u uint64, size int) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], u)
+	return append([]byte(nil), b[8-size:]...)
+}
+
+// padBytes pads a byte string with NUL bytes to the field length.
+func padBytes(b []byte, length uint16) ([]byte, error) {
+	if length == VariableLength {
+		return b, nil
+	}
+	if len(b) > int(length) {
+		return nil, fmt.Errorf("value of %d bytes exceeds field length %d", len(b), length)
+	}
+	return append(append([]byte(nil), b...), make([]byte, int(length)-len(b))...), nil
+}
@@ -0,0 +1,69 @@
+package translate
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	now := time.Unix(1500000000, 123000000)
+	tests := []struct {
+		value  interface{}
+		ft     FieldType
+		length uint16
+		expect interface{}
+		buf    []byte
+	}{
+		{uint8(0xff), Uint8, 1, uint8(0xff), []byte{0xff}},
+		{1500, Uint16, 2, uint16(1500), []byte{0x05, 0xdc}},
+		{uint32(300), Uint32, 2, uint32(300), []byte{0x01, 0x2c}},
+		{uint64(1) << 40, Uint64, 8, uint64(1) << 40, []byte{0, 0, 1, 0, 0, 0, 0, 0}},
+		{-2, Int16, 2, int16(-2), []byte{0xff, 0xfe}},
+		{int64(-1), Int64, 4, int64(-1), []byte{0xff, 0xff, 0xff, 0xff}},
+		{float32(1.5), Float32, 4, float32(1.5), []byte{0x3f, 0xc0, 0x00, 0x00}},
+		{true, Boolean, 1, true, []byte{1}},
+		{false, Boolean, 1, false, []byte{2}},
+		{net.IP{192, 0, 2, 1}, Ipv4Address, 4, net.IP{192, 0, 2, 1}, []byte{192, 0, 2, 1}},
+		{net.ParseIP("2001:db8::1"), Ipv6Address, 16, net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::1")},
+		{net.HardwareAddr{0, 1, 2, 3, 4, 5}, MacAddress, 6, net.HardwareAddr{0, 1, 2, 3, 4, 5}, []byte{0, 1, 2, 3, 4, 5}},
+		{now, DateTimeSeconds, 4, time.Unix(1500000000, 0), []byte{0x59, 0x68, 0x2f, 0x00}},
+		{now, DateTimeMilliseconds, 8, now, []byte{0, 0, 0x01, 0x5d, 0x3e, 0xf7, 0x98, 0x7b}},
+		{"eth0", String, VariableLength, "eth0", []byte("eth0")},
+		{[]byte{1, 2}, OctetArray, 4, []byte{1, 2, 0, 0}, []byte{1, 2, 0, 0}},
+	}
+	for _, test := range tests {
+		buf, err := Marshal(test.value, test.ft, test.length)
+		if err != nil {
+			t.Fatalf("%v (%T): %v", test.value, test.value, err)
+		}
+		if !bytes.Equal(buf, test.buf) {
+			t.Fatalf("%v (%T): expected %x, got %x", test.value, test.value, test.buf, buf)
+		}
+		assertMatch(t, test.ft, buf, test.expect)
+	}
+}
+
+func TestMarshalInvalid(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		ft     FieldType
+		length uint16
+	}{
+		{256, Uint8, 1},
+		{uint32(70000), Uint32, 2},
+		{-1, Uint16, 2},
+		{128, Int8, 1},
+		{"1", Uint32, 4},
+		{net.ParseIP("2001:db8::1"), Ipv4Address, 4},
+		{time.Now(), DateTimeMilliseconds, 4},
+		{"too long", String, 4},
+		{uint32(1), Uint32, 8},
+	}
+	for _, test := range tests {
+		if _, err := Marshal(test.value, test.ft, test.length); err == nil {
+			t.Fatalf("expected %v (%T) as field type %d length %d to fail", test.value, test.value, test.ft, test.length)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package translate
+
+func init() {
+	// NAT events, see RFC 8158 section 4.1 and the IANA natEvent registry.
+	// Values 1 and 2 are the historic events as used by Cisco NEL.
+	enums[Key{0, 230}] = map[uint64]string{
+		1:  "NAT translation create",
+		2:  "NAT translation delete",
+		3:  "NAT addresses exhausted",
+		4:  "NAT44 session create",
+		5:  "NAT44 session delete",
+		6:  "NAT64 session create",
+		7:  "NAT64 session delete",
+		8:  "NAT44 BIB create",
+		9:  "NAT44 BIB delete",
+		10: "NAT64 BIB create",
+		11: "NAT64 BIB delete",
+		12: "NAT ports exhausted",
+		13: "quota exceeded",
+		14: "address binding create",
+		15: "address binding delete",
+		16: "port block allocation",
+		17: "port block de-allocation",
+		18: "threshold reached",
+	}
+
+	// NAT originating address realm (RFC 8158 section 4.1)
+	enums[Key{0, 229}] = map[uint64]string{
+		1: "private",
+		2: "public",
+	}
+
+	// NAT quota exceeded events (RFC 8158 section 4.2)
+	enums[Key{0, 466}] = map[uint64]string{
+		1: "maximum session entries",
+		2: "maximum BIB entries",
+		3: "maximum entries per user",
+		4: "maximum active hosts or subscribers",
+		5: "maximum fragments pending reassembly",
+	}
+
+	// NAT threshold events (RFC 8158 section 4.3)
+	enums[Key{0, 467}] = map[uint64]string{
+		1: "address pool high threshold",
+		2: "address pool low threshold",
+		3: "address and port mapping high threshold",
+		4: "address and port mapping per user high threshold",
+		5: "global address mapping high threshold",
+	}
+}
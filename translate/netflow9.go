@@ -0,0 +1,22 @@
+package translate
+
+// NetFlow version 9 field types that are not IANA assigned information
+// elements, mapped to their vendor specific information element.
+var netflow9Keys = make(map[uint16]Key)
+
+// NetFlow9Key returns the Key for a NetFlow version 9 field type. Field types
+// are IANA assigned information elements, unless the field type is a known
+// vendor specific extension.
+func NetFlow9Key(fieldType uint16) Key {
+	if k, ok := netflow9Keys[fieldType]; ok {
+		return k
+	}
+	return Key{0, fieldType}
+}
+
+// AddNetFlow9Key maps a vendor specific NetFlow version 9 field type to an
+// Information Element. It's not safe to call this while decoding, so it's
+// typically called from an init function.
+func AddNetFlow9Key(fieldType uint16, k Key) {
+	netflow9Keys[fieldType] = k
+}
@@ -0,0 +1,43 @@
+package translate
+
+func init() {
+	// Cisco ASA NetFlow Security Event Logging (NSEL), see the NetFlow
+	// Implementation Note for Cisco ASA. The NSEL specific field types are
+	// only used in NetFlow version 9, and are mapped to Cisco's PEN, unless
+	// there is an equivalent IANA assigned information element.
+	builtin[Key{9, 33000}] = InformationElementEntry{FieldID: 33000, Name: "ingressAclId", Type: FieldTypes["octetArray"]}
+	builtin[Key{9, 33001}] = InformationElementEntry{FieldID: 33001, Name: "egressAclId", Type: FieldTypes["octetArray"]}
+	builtin[Key{9, 33002}] = InformationElementEntry{FieldID: 33002, Name: "firewallExtendedEvent", Type: FieldTypes["unsigned16"]}
+
+	for _, fieldType := range []uint16{33000, 33001, 33002} {
+		netflow9Keys[fieldType] = Key{9, fieldType}
+	}
+
+	// Older ASA releases export these field types, they carry the same values
+	// as the IANA assigned information elements
+	netflow9Keys[40000] = Key{0, 371} // userName
+	netflow9Keys[40001] = Key{0, 225} // postNATSourceIPv4Address
+	netflow9Keys[40002] = Key{0, 226} // postNATDestinationIPv4Address
+	netflow9Keys[40003] = Key{0, 227} // postNAPTSourceTransportPort
+	netflow9Keys[40004] = Key{0, 228} // postNAPTDestinationTransportPort
+	netflow9Keys[40005] = Key{0, 233} // firewallEvent
+
+	// Firewall events, as used by the IANA firewallEvent and NSEL
+	enums[Key{0, 233}] = map[uint64]string{
+		0: "ignore",
+		1: "flow created",
+		2: "flow deleted",
+		3: "flow denied",
+		4: "flow alert",
+		5: "flow update",
+	}
+
+	// Extended firewall events, these further describe denied flows
+	enums[Key{9, 33002}] = map[uint64]string{
+		0:    "ignore",
+		1001: "flow denied by ingress ACL",
+		1002: "flow denied by egress ACL",
+		1003: "flow denied by connection attempt to interface",
+		1004: "flow denied because first TCP packet was not SYN",
+	}
+}
@@ -0,0 +1,215 @@
+package translate
+
+// Base of the ntop nProbe field types in NetFlow version 9, the IPFIX element
+// ID in PEN 35632 is the NetFlow version 9 field type minus the base.
+const ntopBaseID = 57472
+
+func init() {
+	// ntop nProbe parameters, see the output of "nprobe -H"
+	builtin[Key{35632, 80}] = InformationElementEntry{FieldID: 80, Name: "SRC_FRAGMENTS", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 81}] = InformationElementEntry{FieldID: 81, Name: "DST_FRAGMENTS", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 82}] = InformationElementEntry{FieldID: 82, Name: "SRC_TO_DST_MAX_THROUGHPUT", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 83}] = InformationElementEntry{FieldID: 83, Name: "SRC_TO_DST_MIN_THROUGHPUT", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 84}] = InformationElementEntry{FieldID: 84, Name: "SRC_TO_DST_AVG_THROUGHPUT", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 85}] = InformationElementEntry{FieldID: 85, Name: "DST_TO_SRC_MAX_THROUGHPUT", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 86}] = InformationElementEntry{FieldID: 86, Name: "DST_TO_SRC_MIN_THROUGHPUT", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 87}] = InformationElementEntry{FieldID: 87, Name: "DST_TO_SRC_AVG_THROUGHPUT", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 88}] = InformationElementEntry{FieldID: 88, Name: "NUM_PKTS_UP_TO_128_BYTES", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 89}] = InformationElementEntry{FieldID: 89, Name: "NUM_PKTS_128_TO_256_BYTES", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 90}] = InformationElementEntry{FieldID: 90, Name: "NUM_PKTS_256_TO_512_BYTES", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 91}] = InformationElementEntry{FieldID: 91, Name: "NUM_PKTS_512_TO_1024_BYTES", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 92}] = InformationElementEntry{FieldID: 92, Name: "NUM_PKTS_1024_TO_1514_BYTES", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 93}] = InformationElementEntry{FieldID: 93, Name: "NUM_PKTS_OVER_1514_BYTES", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 98}] = InformationElementEntry{FieldID: 98, Name: "CUMULATIVE_ICMP_TYPE", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 101}] = InformationElementEntry{FieldID: 101, Name: "SRC_IP_COUNTRY", Type: FieldTypes["string"]}
+	builtin[Key{35632, 102}] = InformationElementEntry{FieldID: 102, Name: "SRC_IP_CITY", Type: FieldTypes["string"]}
+	builtin[Key{35632, 103}] = InformationElementEntry{FieldID: 103, Name: "DST_IP_COUNTRY", Type: FieldTypes["string"]}
+	builtin[Key{35632, 104}] = InformationElementEntry{FieldID: 104, Name: "DST_IP_CITY", Type: FieldTypes["string"]}
+	builtin[Key{35632, 105}] = InformationElementEntry{FieldID: 105, Name: "FLOW_PROTO_PORT", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 106}] = InformationElementEntry{FieldID: 106, Name: "UPSTREAM_TUNNEL_ID", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 107}] = InformationElementEntry{FieldID: 107, Name: "LONGEST_FLOW_PKT", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 108}] = InformationElementEntry{FieldID: 108, Name: "SHORTEST_FLOW_PKT", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 109}] = InformationElementEntry{FieldID: 109, Name: "RETRANSMITTED_IN_PKTS", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 110}] = InformationElementEntry{FieldID: 110, Name: "RETRANSMITTED_OUT_PKTS", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 111}] = InformationElementEntry{FieldID: 111, Name: "OOORDER_IN_PKTS", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 112}] = InformationElementEntry{FieldID: 112, Name: "OOORDER_OUT_PKTS", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 113}] = InformationElementEntry{FieldID: 113, Name: "UNTUNNELED_PROTOCOL", Type: FieldTypes["unsigned8"]}
+	builtin[Key{35632, 114}] = InformationElementEntry{FieldID: 114, Name: "UNTUNNELED_IPV4_SRC_ADDR", Type: FieldTypes["ipv4Address"]}
+	builtin[Key{35632, 115}] = InformationElementEntry{FieldID: 115, Name: "UNTUNNELED_L4_SRC_PORT", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 116}] = InformationElementEntry{FieldID: 116, Name: "UNTUNNELED_IPV4_DST_ADDR", Type: FieldTypes["ipv4Address"]}
+	builtin[Key{35632, 117}] = InformationElementEntry{FieldID: 117, Name: "UNTUNNELED_L4_DST_PORT", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 118}] = InformationElementEntry{FieldID: 118, Name: "L7_PROTO", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 119}] = InformationElementEntry{FieldID: 119, Name: "L7_PROTO_NAME", Type: FieldTypes["string"]}
+	builtin[Key{35632, 120}] = InformationElementEntry{FieldID: 120, Name: "DOWNSTREAM_TUNNEL_ID", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 121}] = InformationElementEntry{FieldID: 121, Name: "FLOW_USER_NAME", Type: FieldTypes["string"]}
+	builtin[Key{35632, 122}] = InformationElementEntry{FieldID: 122, Name: "FLOW_SERVER_NAME", Type: FieldTypes["string"]}
+	builtin[Key{35632, 123}] = InformationElementEntry{FieldID: 123, Name: "CLIENT_NW_LATENCY_MS", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 124}] = InformationElementEntry{FieldID: 124, Name: "SERVER_NW_LATENCY_MS", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 125}] = InformationElementEntry{FieldID: 125, Name: "APPL_LATENCY_MS", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 126}] = InformationElementEntry{FieldID: 126, Name: "PLUGIN_NAME", Type: FieldTypes["string"]}
+	builtin[Key{35632, 127}] = InformationElementEntry{FieldID: 127, Name: "RETRANSMITTED_IN_BYTES", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 128}] = InformationElementEntry{FieldID: 128, Name: "RETRANSMITTED_OUT_BYTES", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 130}] = InformationElementEntry{FieldID: 130, Name: "SIP_CALL_ID", Type: FieldTypes["string"]}
+	builtin[Key{35632, 131}] = InformationElementEntry{FieldID: 131, Name: "SIP_CALLING_PARTY", Type: FieldTypes["string"]}
+	builtin[Key{35632, 132}] = InformationElementEntry{FieldID: 132, Name: "SIP_CALLED_PARTY", Type: FieldTypes["string"]}
+	builtin[Key{35632, 133}] = InformationElementEntry{FieldID: 133, Name: "SIP_RTP_CODECS", Type: FieldTypes["string"]}
+	builtin[Key{35632, 134}] = InformationElementEntry{FieldID: 134, Name: "SIP_INVITE_TIME", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 135}] = InformationElementEntry{FieldID: 135, Name: "SIP_TRYING_TIME", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 136}] = InformationElementEntry{FieldID: 136, Name: "SIP_RINGING_TIME", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 137}] = InformationElementEntry{FieldID: 137, Name: "SIP_INVITE_OK_TIME", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 138}] = InformationElementEntry{FieldID: 138, Name: "SIP_INVITE_FAILURE_TIME", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 139}] = InformationElementEntry{FieldID: 139, Name: "SIP_BYE_TIME", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 140}] = InformationElementEntry{FieldID: 140, Name: "SIP_BYE_OK_TIME", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 141}] = InformationElementEntry{FieldID: 141, Name: "SIP_CANCEL_TIME", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 142}] = InformationElementEntry{FieldID: 142, Name: "SIP_CANCEL_OK_TIME", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 143}] = InformationElementEntry{FieldID: 143, Name: "SIP_RTP_IPV4_SRC_ADDR", Type: FieldTypes["ipv4Address"]}
+	builtin[Key{35632, 144}] = InformationElementEntry{FieldID: 144, Name: "SIP_RTP_L4_SRC_PORT", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 145}] = InformationElementEntry{FieldID: 145, Name: "SIP_RTP_IPV4_DST_ADDR", Type: FieldTypes["ipv4Address"]}
+	builtin[Key{35632, 146}] = InformationElementEntry{FieldID: 146, Name: "SIP_RTP_L4_DST_PORT", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 147}] = InformationElementEntry{FieldID: 147, Name: "SIP_RESPONSE_CODE", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 148}] = InformationElementEntry{FieldID: 148, Name: "SIP_REASON_CAUSE", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 150}] = InformationElementEntry{FieldID: 150, Name: "RTP_FIRST_SEQ", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 151}] = InformationElementEntry{FieldID: 151, Name: "RTP_FIRST_TS", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 152}] = InformationElementEntry{FieldID: 152, Name: "RTP_LAST_SEQ", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 153}] = InformationElementEntry{FieldID: 153, Name: "RTP_LAST_TS", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 154}] = InformationElementEntry{FieldID: 154, Name: "RTP_IN_JITTER", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 155}] = InformationElementEntry{FieldID: 155, Name: "RTP_OUT_JITTER", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 156}] = InformationElementEntry{FieldID: 156, Name: "RTP_IN_PKT_LOST", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 157}] = InformationElementEntry{FieldID: 157, Name: "RTP_OUT_PKT_LOST", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 159}] = InformationElementEntry{FieldID: 159, Name: "RTP_OUT_PAYLOAD_TYPE", Type: FieldTypes["unsigned8"]}
+	builtin[Key{35632, 160}] = InformationElementEntry{FieldID: 160, Name: "RTP_IN_MAX_DELTA", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 161}] = InformationElementEntry{FieldID: 161, Name: "RTP_OUT_MAX_DELTA", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 162}] = InformationElementEntry{FieldID: 162, Name: "RTP_IN_PAYLOAD_TYPE", Type: FieldTypes["unsigned8"]}
+	builtin[Key{35632, 180}] = InformationElementEntry{FieldID: 180, Name: "HTTP_URL", Type: FieldTypes["string"]}
+	builtin[Key{35632, 181}] = InformationElementEntry{FieldID: 181, Name: "HTTP_RET_CODE", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 182}] = InformationElementEntry{FieldID: 182, Name: "HTTP_REFERER", Type: FieldTypes["string"]}
+	builtin[Key{35632, 183}] = InformationElementEntry{FieldID: 183, Name: "HTTP_UA", Type: FieldTypes["string"]}
+	builtin[Key{35632, 184}] = InformationElementEntry{FieldID: 184, Name: "HTTP_MIME", Type: FieldTypes["string"]}
+	builtin[Key{35632, 185}] = InformationElementEntry{FieldID: 185, Name: "SMTP_MAIL_FROM", Type: FieldTypes["string"]}
+	builtin[Key{35632, 186}] = InformationElementEntry{FieldID: 186, Name: "SMTP_RCPT_TO", Type: FieldTypes["string"]}
+	builtin[Key{35632, 187}] = InformationElementEntry{FieldID: 187, Name: "HTTP_HOST", Type: FieldTypes["string"]}
+	builtin[Key{35632, 188}] = InformationElementEntry{FieldID: 188, Name: "SSL_SERVER_NAME", Type: FieldTypes["string"]}
+	builtin[Key{35632, 189}] = InformationElementEntry{FieldID: 189, Name: "BITTORRENT_HASH", Type: FieldTypes["string"]}
+	builtin[Key{35632, 192}] = InformationElementEntry{FieldID: 192, Name: "CLIENT_TCP_FLAGS", Type: FieldTypes["unsigned8"]}
+	builtin[Key{35632, 193}] = InformationElementEntry{FieldID: 193, Name: "SERVER_TCP_FLAGS", Type: FieldTypes["unsigned8"]}
+	builtin[Key{35632, 205}] = InformationElementEntry{FieldID: 205, Name: "DNS_QUERY", Type: FieldTypes["string"]}
+	builtin[Key{35632, 206}] = InformationElementEntry{FieldID: 206, Name: "DNS_QUERY_ID", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 207}] = InformationElementEntry{FieldID: 207, Name: "DNS_QUERY_TYPE", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 208}] = InformationElementEntry{FieldID: 208, Name: "DNS_RET_CODE", Type: FieldTypes["unsigned8"]}
+	builtin[Key{35632, 209}] = InformationElementEntry{FieldID: 209, Name: "DNS_NUM_ANSWERS", Type: FieldTypes["unsigned8"]}
+	builtin[Key{35632, 334}] = InformationElementEntry{FieldID: 334, Name: "NUM_PKTS_TTL_5_32", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 335}] = InformationElementEntry{FieldID: 335, Name: "NUM_PKTS_TTL_32_64", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 336}] = InformationElementEntry{FieldID: 336, Name: "NUM_PKTS_TTL_64_96", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 337}] = InformationElementEntry{FieldID: 337, Name: "NUM_PKTS_TTL_96_128", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 338}] = InformationElementEntry{FieldID: 338, Name: "NUM_PKTS_TTL_128_160", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 339}] = InformationElementEntry{FieldID: 339, Name: "NUM_PKTS_TTL_160_192", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 340}] = InformationElementEntry{FieldID: 340, Name: "NUM_PKTS_TTL_192_224", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 341}] = InformationElementEntry{FieldID: 341, Name: "NUM_PKTS_TTL_224_255", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 346}] = InformationElementEntry{FieldID: 346, Name: "NUM_PKTS_TTL_2_5", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 347}] = InformationElementEntry{FieldID: 347, Name: "NUM_PKTS_TTL_EQ_1", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 349}] = InformationElementEntry{FieldID: 349, Name: "IN_SRC_OSI_SAP", Type: FieldTypes["unsigned8"]}
+	builtin[Key{35632, 350}] = InformationElementEntry{FieldID: 350, Name: "OUT_DST_OSI_SAP", Type: FieldTypes["unsigned8"]}
+	builtin[Key{35632, 352}] = InformationElementEntry{FieldID: 352, Name: "DNS_TTL_ANSWER", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 360}] = InformationElementEntry{FieldID: 360, Name: "HTTP_METHOD", Type: FieldTypes["string"]}
+	builtin[Key{35632, 361}] = InformationElementEntry{FieldID: 361, Name: "HTTP_SITE", Type: FieldTypes["string"]}
+	builtin[Key{35632, 391}] = InformationElementEntry{FieldID: 391, Name: "DURATION_IN", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 392}] = InformationElementEntry{FieldID: 392, Name: "DURATION_OUT", Type: FieldTypes["unsigned32"]}
+	builtin[Key{35632, 396}] = InformationElementEntry{FieldID: 396, Name: "UNTUNNELED_IPV6_SRC_ADDR", Type: FieldTypes["ipv6Address"]}
+	builtin[Key{35632, 397}] = InformationElementEntry{FieldID: 397, Name: "UNTUNNELED_IPV6_DST_ADDR", Type: FieldTypes["ipv6Address"]}
+	builtin[Key{35632, 415}] = InformationElementEntry{FieldID: 415, Name: "TCP_WIN_MIN_IN", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 416}] = InformationElementEntry{FieldID: 416, Name: "TCP_WIN_MAX_IN", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 417}] = InformationElementEntry{FieldID: 417, Name: "TCP_WIN_MSS_IN", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 418}] = InformationElementEntry{FieldID: 418, Name: "TCP_WIN_SCALE_IN", Type: FieldTypes["unsigned8"]}
+	builtin[Key{35632, 419}] = InformationElementEntry{FieldID: 419, Name: "TCP_WIN_MIN_OUT", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 420}] = InformationElementEntry{FieldID: 420, Name: "TCP_WIN_MAX_OUT", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 421}] = InformationElementEntry{FieldID: 421, Name: "TCP_WIN_MSS_OUT", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 422}] = InformationElementEntry{FieldID: 422, Name: "TCP_WIN_SCALE_OUT", Type: FieldTypes["unsigned8"]}
+	builtin[Key{35632, 446}] = InformationElementEntry{FieldID: 446, Name: "UPSTREAM_SESSION_ID", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 447}] = InformationElementEntry{FieldID: 447, Name: "DOWNSTREAM_SESSION_ID", Type: FieldTypes["unsigned16"]}
+	builtin[Key{35632, 460}] = InformationElementEntry{FieldID: 460, Name: "HTTP_X_FORWARDED_FOR", Type: FieldTypes["string"]}
+	builtin[Key{35632, 461}] = InformationElementEntry{FieldID: 461, Name: "HTTP_VIA", Type: FieldTypes["string"]}
+	builtin[Key{35632, 471}] = InformationElementEntry{FieldID: 471, Name: "NPROBE_IPV4_ADDRESS", Type: FieldTypes["ipv4Address"]}
+
+	for k := range builtin {
+		if k.EnterpriseID == 35632 {
+			netflow9Keys[ntopBaseID+k.FieldID] = k
+		}
+	}
+
+	// nDPI protocol identifiers, as used in L7_PROTO
+	enums[Key{35632, 118}] = map[uint64]string{
+		0:   "Unknown",
+		1:   "FTP_CONTROL",
+		2:   "POP3",
+		3:   "SMTP",
+		4:   "IMAP",
+		5:   "DNS",
+		6:   "IPP",
+		7:   "HTTP",
+		8:   "MDNS",
+		9:   "NTP",
+		10:  "NetBIOS",
+		11:  "NFS",
+		12:  "SSDP",
+		13:  "BGP",
+		14:  "SNMP",
+		15:  "XDMCP",
+		16:  "SMBv1",
+		17:  "Syslog",
+		18:  "DHCP",
+		19:  "PostgreSQL",
+		20:  "MySQL",
+		23:  "POPS",
+		29:  "SMTPS",
+		37:  "BitTorrent",
+		51:  "IMAPS",
+		77:  "Telnet",
+		78:  "STUN",
+		79:  "IPsec",
+		80:  "GRE",
+		81:  "ICMP",
+		82:  "IGMP",
+		84:  "SCTP",
+		85:  "OSPF",
+		87:  "RTP",
+		88:  "RDP",
+		89:  "VNC",
+		91:  "TLS",
+		92:  "SSH",
+		96:  "TFTP",
+		100: "SIP",
+		102: "ICMPV6",
+		103: "DHCPV6",
+		111: "Kerberos",
+		112: "LDAP",
+		114: "MsSQL-TDS",
+		115: "PPTP",
+		119: "Facebook",
+		120: "Twitter",
+		121: "Dropbox",
+		122: "GMail",
+		124: "YouTube",
+		125: "Skype",
+		126: "Google",
+		127: "DCE_RPC",
+		128: "NetFlow",
+		129: "sFlow",
+		130: "HTTP_Connect",
+		131: "HTTP_Proxy",
+		133: "Netflix",
+		140: "Apple",
+		142: "WhatsApp",
+		146: "Radius",
+		148: "TeamViewer",
+		156: "Spotify",
+		159: "OpenVPN",
+		163: "Tor",
+		166: "RSYNC",
+		172: "SOCKS",
+		175: "FTP_DATA",
+		178: "Amazon",
+		182: "Redis",
+		185: "Telegram",
+		188: "QUIC",
+	}
+}
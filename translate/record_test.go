@@ -0,0 +1,109 @@
+package translate_test
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tehmaze/netflow/ipfix"
+	"github.com/tehmaze/netflow/session"
+	"github.com/tehmaze/netflow/translate"
+)
+
+// Juniper MX inline J-Flow message with a template carrying the ingress
+// interface and the Juniper commonPropertiesId, followed by a data record.
+var juniperMessage = []byte{
+	// Message header, length 52, domain 1
+	0x00, 0x0a, 0x00, 0x34, 0x5f, 0x5e, 0x10, 0x00,
+	0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+	// Template set, template 256 with 2 fields
+	0x00, 0x02, 0x00, 0x14, 0x01, 0x00, 0x00, 0x02,
+	0x00, 0x0a, 0x00, 0x04, // ingressInterface
+	0x80, 0x89, 0x00, 0x08, 0x00, 0x00, 0x0a, 0x4c, // 2636/137
+	// Data set for template 256
+	0x01, 0x00, 0x00, 0x10,
+	0x00, 0x00, 0x02, 0x05,
+	0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x2c,
+}
+
+func TestJuniperRecord(t *testing.T) {
+	m, err := ipfix.NewDecoder(nil, session.New()).Decode(juniperMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.DataSets) != 1 || len(m.DataSets[0].Records) != 1 {
+		t.Fatalf("expected one data record, got %+v", m.DataSets)
+	}
+
+	fields := m.DataSets[0].Records[0].Fields
+	if f := fields[0].Translated; f == nil || f.Name != "ingressInterface" || f.Value != uint32(517) {
+		t.Fatalf("unexpected ingress interface %+v", f)
+	}
+	f := fields[1].Translated
+	if f == nil || f.Name != "juniperCommonPropertiesId" || f.EnterpriseNumber != 2636 {
+		t.Fatalf("unexpected common properties ID %+v", f)
+	}
+	cpid, value, ok := translate.JuniperCommonProperties(fields[1].Bytes)
+	if !ok || cpid != translate.JuniperCPIDIngressLogicalInterface || value != 300 {
+		t.Fatalf("expected ingress logical interface 300, got type %d value %d", cpid, value)
+	}
+}
+
+// captureStdout returns what fn writes to the standard output.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- string(b)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestJuniperDump(t *testing.T) {
+	m, err := ipfix.NewDecoder(nil, session.New()).Decode(juniperMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := captureStdout(t, func() { ipfix.Dump(m) })
+	for _, expect := range []string{
+		"ingressInterface: 517",
+		"juniperCommonPropertiesId: 1441151880758559020 (ingress logical interface 300)",
+	} {
+		if !strings.Contains(out, expect) {
+			t.Fatalf("expected %q in dump:\n%s", expect, out)
+		}
+	}
+}
+
+func TestJuniperCommonPropertiesDescription(t *testing.T) {
+	key := translate.Key{EnterpriseID: 2636, FieldID: 137}
+	tests := []struct {
+		bs     []byte
+		expect string
+	}{
+		{[]byte{0x14, 0x00, 0x01, 0x2c}, "ingress logical interface 300"},
+		{[]byte{0x10, 0x00, 0x00, 0x2a}, "egress logical interface 42"},
+		{[]byte{0x04, 0x00, 0x03, 0x01}, "forwarding class and drop priority 3/1"},
+		{[]byte{0xfc, 0, 0, 0, 0, 0, 0, 0x07}, "unknown type 63 value 7"},
+	}
+	for _, test := range tests {
+		if d, ok := translate.Description(key, nil, test.bs); !ok || d != test.expect {
+			t.Fatalf("%x: expected %q, got %q", test.bs, test.expect, d)
+		}
+	}
+	if _, ok := translate.Description(key, nil, []byte{1, 2}); ok {
+		t.Fatal("expected invalid length to have no description")
+	}
+}
@@ -26,16 +26,38 @@ func NewTranslate(s session.Session) *Translate {
 	return &Translate{s, builtin}
 }
 
-// Key retrieves the Information Element entry for the given Key.
+// AddElement adds a vendor specific Information Element to the builtin
+// dictionary, shared by all translators. It's not safe to call this while
+// decoding, so it's typically called from an init function.
+func AddElement(e InformationElementEntry) {
+	builtin[Key{e.EnterpriseID, e.FieldID}] = e
+}
+
+// Key retrieves the Information Element entry for the given Key. If the
+// Information Element is not in the builtin dictionary, the Information
+// Elements learned by the session are used.
 func (t *Translate) Key(k Key) (InformationElementEntry, bool) {
-	i, ok := t.elements[k]
-	return i, ok
+	if i, ok := t.elements[k]; ok {
+		return i, ok
+	}
+	if t.Session != nil {
+		if ie, ok := t.Session.GetInformationElement(k.EnterpriseID, k.FieldID); ok {
+			return InformationElementEntry{
+				Name:         ie.Name,
+				FieldID:      ie.Type,
+				EnterpriseID: ie.EnterpriseNumber,
+				Type:         DataTypes[ie.DataType],
+			}, true
+		}
+	}
+	return InformationElementEntry{}, false
 }
 
 // FieldType is the IPFIX type of an Information Element ("Field").
 type FieldType uint8
 
-// The available field types as defined by RFC 5102.
+// The available field types as defined by RFC 5102, and the structured data
+// types as defined by RFC 6313.
 const (
 	Unknown FieldType = iota
 	Uint8
@@ -58,6 +80,9 @@ const (
 	DateTimeNanoseconds
 	Ipv4Address
 	Ipv6Address
+	BasicList
+	SubTemplateList
+	SubTemplateMultiList
 )
 
 // FieldTypes are used in the InformationElementEntries map
@@ -82,6 +107,37 @@ var FieldTypes = map[string]FieldType{
 	"dateTimeNanoseconds":  DateTimeNanoseconds,
 	"ipv4Address":          Ipv4Address,
 	"ipv6Address":          Ipv6Address,
+	"basicList":            BasicList,
+	"subTemplateList":      SubTemplateList,
+	"subTemplateMultiList": SubTemplateMultiList,
+}
+
+// DataTypes maps the IANA abstract data type codes, as used in the Information
+// Element Type Options (RFC 5610 section 3.1), to field types.
+var DataTypes = map[uint8]FieldType{
+	0:  OctetArray,
+	1:  Uint8,
+	2:  Uint16,
+	3:  Uint32,
+	4:  Uint64,
+	5:  Int8,
+	6:  Int16,
+	7:  Int32,
+	8:  Int64,
+	9:  Float32,
+	10: Float64,
+	11: Boolean,
+	12: MacAddress,
+	13: String,
+	14: DateTimeSeconds,
+	15: DateTimeMilliseconds,
+	16: DateTimeMicroseconds,
+	17: DateTimeNanoseconds,
+	18: Ipv4Address,
+	19: Ipv6Address,
+	20: BasicList,
+	21: SubTemplateList,
+	22: SubTemplateMultiList,
 }
 
 // minLength is the minimum length of a field of the given type, in bytes.
@@ -137,26 +193,31 @@ type informationElements map[Key]InformationElementEntry
 var reducedSizeErr error = errors.New("Unable to read reduced size encoding: size not implemented")
 var tooManyBitsErr error = errors.New("Unable to read reduced size encoding: too many bits")
 
+// Unsigned decodes a big endian unsigned integer of one up to eight octets,
+// such as a reduced size encoded unsigned field. Longer values don't fit in 64
+// bits and are rejected.
+func Unsigned(bs []byte) (uint64, error) {
+	if len(bs) == 0 {
+		return 0, reducedSizeErr
+	}
+	if len(bs) > 8 {
+		return 0, tooManyBitsErr
+	}
+
+	var value uint64
+	for _, b := range bs {
+		value = value<<8 | uint64(b)
+	}
+	return value, nil
+}
+
 // Helper method to read an unsigned reduced size field
 func reducedSizeReadUnsigned(bs []byte, maxBits int) (uint64, error) {
 	// Exit if `bs` has more bits than we can store
 	if len(bs)*8 > maxBits {
 		return 0, tooManyBitsErr
 	}
-
-	switch len(bs) {
-	case 1:
-		return uint64(bs[0]), nil
-	case 2:
-		return uint64(binary.BigEndian.Uint16(bs)), nil
-	case 3:
-		return uint64(uint32(bs[0])<<16 + uint32(bs[1])<<8 + uint32(bs[2])), nil
-	case 4:
-		return uint64(binary.BigEndian.Uint32(bs)), nil
-	case 8:
-		return binary.BigEndian.Uint64(bs), nil
-	}
-	return 0, reducedSizeErr
+	return Unsigned(bs)
 }
 
 // Helper method to read a signed reduced size field
@@ -269,6 +330,10 @@ func Bytes(bs []byte, t FieldType) interface{} {
 		return bs[0] == 1
 	case Unknown, OctetArray:
 		return bs
+	case BasicList, SubTemplateList, SubTemplateMultiList:
+		// Structured data needs the templates to decode, which is left to
+		// the protocol implementation.
+		return bs
 	case String:
 		return string(bs)
 	case MacAddress:
@@ -250,3 +250,30 @@ func TestReducedSizeReadUnsigned(t *testing.T) {
 		t.Fatal("Expected reducedSizeReadUnsigned() to fail with large byte slice")
 	}
 }
+
+func TestUnsigned(t *testing.T) {
+	tests := []struct {
+		bs    []byte
+		value uint64
+	}{
+		{[]byte{0x2a}, 42},
+		{[]byte{0x01, 0x00, 0x00}, 65536},
+		{[]byte{0x01, 0x00, 0x00, 0x00, 0x00}, 1 << 32},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, 1<<64 - 1},
+	}
+	for _, test := range tests {
+		value, err := Unsigned(test.bs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != test.value {
+			t.Fatalf("%x: expected %d, got %d", test.bs, test.value, value)
+		}
+	}
+
+	for _, bs := range [][]byte{nil, make([]byte, 9)} {
+		if _, err := Unsigned(bs); err == nil {
+			t.Fatalf("expected Unsigned() to fail with %d bytes", len(bs))
+		}
+	}
+}
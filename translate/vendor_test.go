@@ -0,0 +1,93 @@
+package translate
+
+import (
+	"testing"
+)
+
+func TestJuniperCommonPropertiesID(t *testing.T) {
+	e, ok := NewTranslate(nil).Key(Key{2636, 137})
+	if !ok {
+		t.Fatal("no Juniper commonPropertiesId in the builtin dictionary")
+	}
+
+	// Ingress logical interface 300, as exported by an MX router
+	buf := []byte{0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x2c}
+	assertMatch(t, e.Type, buf, uint64(0x140000000000012c))
+
+	cpid, value, ok := JuniperCommonProperties(buf)
+	if !ok || cpid != JuniperCPIDIngressLogicalInterface || value != 300 {
+		t.Fatalf("expected ingress logical interface 300, got type %d value %d", cpid, value)
+	}
+	if name, _ := JuniperCommonPropertiesName(cpid); name != "ingress logical interface" {
+		t.Fatalf("unexpected name %q", name)
+	}
+}
+
+func TestJuniperCommonPropertiesIDReducedSize(t *testing.T) {
+	// Forwarding class 2 with drop priority 1, in a 4 byte field
+	buf := []byte{0x04, 0x00, 0x02, 0x01}
+	cpid, value, ok := JuniperCommonProperties(buf)
+	if !ok || cpid != JuniperCPIDForwardingClass || value != 0x0201 {
+		t.Fatalf("expected forwarding class, got type %d value %#x", cpid, value)
+	}
+	if class, dp := JuniperForwardingClass(value); class != 2 || dp != 1 {
+		t.Fatalf("expected forwarding class 2 drop priority 1, got %d %d", class, dp)
+	}
+
+	if _, _, ok = JuniperCommonProperties(buf[:3]); ok {
+		t.Fatal("expected 3 byte common properties ID to be rejected")
+	}
+}
+
+func TestNetFlow9VendorKeys(t *testing.T) {
+	tests := map[uint16]Key{
+		8:     {0, 8},       // IANA sourceIPv4Address
+		33002: {9, 33002},   // Cisco NSEL firewallExtendedEvent
+		40001: {0, 225},     // Cisco NSEL legacy postNATSourceIPv4Address
+		40005: {0, 233},     // Cisco NSEL legacy firewallEvent
+		57590: {35632, 118}, // ntop L7_PROTO
+		57652: {35632, 180}, // ntop HTTP_URL
+	}
+	for fieldType, expected := range tests {
+		if k := NetFlow9Key(fieldType); k != expected {
+			t.Fatalf("field type %d: expected %v, got %v", fieldType, expected, k)
+		}
+	}
+}
+
+func TestNtopNetFlow9Fields(t *testing.T) {
+	tr := NewTranslate(nil)
+	tests := []struct {
+		fieldType uint16
+		name      string
+		buf       []byte
+		value     interface{}
+	}{
+		{57595, "CLIENT_NW_LATENCY_MS", []byte{0x00, 0x00, 0x00, 0x2a}, uint32(42)},
+		{57599, "RETRANSMITTED_IN_BYTES", []byte{0x00, 0x00, 0x05, 0xdc}, uint32(1500)},
+		{57664, "CLIENT_TCP_FLAGS", []byte{0x1b}, uint8(0x1b)},
+		{57824, "DNS_TTL_ANSWER", []byte{0x00, 0x00, 0x0e, 0x10}, uint32(3600)},
+		{57832, "HTTP_METHOD", []byte("GET"), "GET"},
+	}
+	for _, test := range tests {
+		e, ok := tr.Key(NetFlow9Key(test.fieldType))
+		if !ok || e.Name != test.name {
+			t.Fatalf("field type %d: expected %s, got %v", test.fieldType, test.name, e)
+		}
+		assertMatch(t, e.Type, test.buf, test.value)
+	}
+}
+
+func TestAddElement(t *testing.T) {
+	// Huawei NetStream private field, registered by the application
+	AddElement(InformationElementEntry{EnterpriseID: 2011, FieldID: 1, Name: "testElement", Type: Uint32})
+	AddNetFlow9Key(40100, Key{2011, 1})
+	defer delete(builtin, Key{2011, 1})
+	defer delete(netflow9Keys, 40100)
+
+	e, ok := NewTranslate(nil).Key(NetFlow9Key(40100))
+	if !ok || e.Name != "testElement" {
+		t.Fatalf("expected registered element, got %v", e)
+	}
+	assertMatch(t, e.Type, []byte{0x00, 0x00, 0x01, 0x00}, uint32(256))
+}
@@ -0,0 +1,17 @@
+package translate
+
+func init() {
+	// VMware vSphere Distributed Switch and NSX, the tenant fields describe
+	// the inner flow of VXLAN or Geneve encapsulated traffic.
+	builtin[Key{6876, 880}] = InformationElementEntry{FieldID: 880, Name: "tenantProtocol", Type: FieldTypes["unsigned8"]}
+	builtin[Key{6876, 881}] = InformationElementEntry{FieldID: 881, Name: "tenantSourceIPv4", Type: FieldTypes["ipv4Address"]}
+	builtin[Key{6876, 882}] = InformationElementEntry{FieldID: 882, Name: "tenantDestIPv4", Type: FieldTypes["ipv4Address"]}
+	builtin[Key{6876, 883}] = InformationElementEntry{FieldID: 883, Name: "tenantSourceIPv6", Type: FieldTypes["ipv6Address"]}
+	builtin[Key{6876, 884}] = InformationElementEntry{FieldID: 884, Name: "tenantDestIPv6", Type: FieldTypes["ipv6Address"]}
+	builtin[Key{6876, 886}] = InformationElementEntry{FieldID: 886, Name: "tenantSourcePort", Type: FieldTypes["unsigned16"]}
+	builtin[Key{6876, 887}] = InformationElementEntry{FieldID: 887, Name: "tenantDestPort", Type: FieldTypes["unsigned16"]}
+	builtin[Key{6876, 888}] = InformationElementEntry{FieldID: 888, Name: "egressInterfaceAttr", Type: FieldTypes["unsigned16"]}
+	builtin[Key{6876, 889}] = InformationElementEntry{FieldID: 889, Name: "vxlanExportRole", Type: FieldTypes["unsigned8"]}
+	builtin[Key{6876, 890}] = InformationElementEntry{FieldID: 890, Name: "ingressInterfaceAttr", Type: FieldTypes["unsigned16"]}
+	builtin[Key{6876, 891}] = InformationElementEntry{FieldID: 891, Name: "virtualObsID", Type: FieldTypes["string"]}
+}